@@ -1,13 +1,20 @@
 #version 450
 
 uniform sampler2D tex;
+uniform sampler2DArray texArray;
+uniform bool layered;
 
-in vec2 fragData;
+in vec3 fragData;
 in vec4 sunlight;
 
 out vec4 outputColor;
 
 void main() {
-	vec4 vTex = texture(tex, fragData);
+	vec4 vTex;
+	if (layered) {
+		vTex = texture(texArray, fragData);
+	} else {
+		vTex = texture(tex, fragData.xy);
+	}
 	outputColor = vTex*sunlight;
 }
\ No newline at end of file
@@ -1,13 +1,19 @@
 #version 450
 
 uniform sampler2D tex;
+uniform bool textured;
+uniform vec4 diffuseColor;
 
 in vec2 fragData;
 in vec4 sunlight;
+in vec3 highlight;
 
 out vec4 outputColor;
 
 void main() {
-	vec4 vTex = texture(tex, fragData);
-	outputColor = vTex*sunlight;
+	vec4 vTex = diffuseColor;
+	if (textured) {
+		vTex *= texture(tex, fragData);
+	}
+	outputColor = vec4(vTex.rgb*sunlight.rgb + highlight, vTex.a);
 }
\ No newline at end of file
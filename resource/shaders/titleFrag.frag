@@ -8,6 +8,6 @@ in vec2 fragData;
 out vec4 outputColor;
 
 void main() {
-	vec4 vTexColor = texture2D(tex, fragData);
+	vec4 vTexColor = texture(tex, fragData);
 	outputColor = vTexColor*vColor;
 }
\ No newline at end of file
@@ -0,0 +1,150 @@
+package Block
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io/ioutil"
+)
+
+const BlockFile string = "resource/blocks/blocks.json"
+
+const (
+	// Block ids the engine relies on, every other block is looked up by name
+	Empty uint8 = iota
+	SkyBox
+)
+
+const (
+	// Cube faces in the order they appear in cube.obj
+	Bottom = iota
+	Top
+	East
+	South
+	West
+	North
+)
+
+// Definition describes one block type. Textures are atlas names, one per
+// face in the order above, see Faces for the forms blocks.json may use.
+// Light is the level of light the block emits and Hardness scales how long it
+// takes to break. Color is an "#rrggbb" colour standing for the block where
+// it cannot be textured, such as in .vox files.
+type Definition struct {
+	ID          uint8   `json:"id"`
+	Name        string  `json:"name"`
+	Textures    Faces   `json:"textures"`
+	Solid       bool    `json:"solid"`
+	Transparent bool    `json:"transparent"`
+	Light       uint8   `json:"light"`
+	Hardness    float32 `json:"hardness"`
+	Color       string  `json:"color"`
+}
+
+// registry is indexed by block id, ids without a definition are nil.
+var registry []*Definition
+var names = map[string]*Definition{}
+
+func InitBlocks() {
+	if err := LoadBlocks(BlockFile); err != nil {
+		panic(err)
+	}
+}
+
+// LoadBlocks replaces the registry with the definitions in file. Ids and
+// names must be unique and the Empty and SkyBox ids must be defined.
+func LoadBlocks(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	definitions := []*Definition{}
+	if err = json.Unmarshal(data, &definitions); err != nil {
+		return fmt.Errorf("%v: %v", file, err)
+	}
+
+	loaded := []*Definition{}
+	loadedNames := map[string]*Definition{}
+	for _, definition := range definitions {
+		if definition.Name == "" {
+			return fmt.Errorf("%v: block %v has no name", file, definition.ID)
+		}
+		for int(definition.ID) >= len(loaded) {
+			loaded = append(loaded, nil)
+		}
+		if loaded[definition.ID] != nil {
+			return fmt.Errorf("%v: blocks %v and %v share id %v", file, loaded[definition.ID].Name, definition.Name, definition.ID)
+		}
+		if definition.ID != Empty && definition.Textures.missing() >= 0 {
+			return fmt.Errorf("%v: block %v has no texture for face %v", file, definition.Name, faceNames[definition.Textures.missing()])
+		}
+		if _, ok := definition.RGBA(); definition.Color != "" && !ok {
+			return fmt.Errorf("%v: block %v has colour %q, expected #rrggbb", file, definition.Name, definition.Color)
+		}
+		if _, ok := loadedNames[definition.Name]; ok {
+			return fmt.Errorf("%v: block name %v is used twice", file, definition.Name)
+		}
+		loaded[definition.ID] = definition
+		loadedNames[definition.Name] = definition
+	}
+	for _, id := range []uint8{Empty, SkyBox} {
+		if int(id) >= len(loaded) || loaded[id] == nil {
+			return fmt.Errorf("%v: block id %v is not defined", file, id)
+		}
+	}
+
+	registry, names = loaded, loadedNames
+	return nil
+}
+
+// RGBA parses Color, reporting false if the block has none.
+func (definition *Definition) RGBA() (color.RGBA, bool) {
+	c := color.RGBA{A: 0xff}
+	if _, err := fmt.Sscanf(definition.Color, "#%02x%02x%02x", &c.R, &c.G, &c.B); err != nil {
+		return c, false
+	}
+	return c, true
+}
+
+// Get returns the definition of a block id, or nil if there is none.
+func Get(id int) *Definition {
+	if id < 0 || id >= len(registry) {
+		return nil
+	}
+	return registry[id]
+}
+
+// ID returns the id of the named block and panics if there is no such block,
+// which is meant for resolving the blocks code depends on at start up.
+func ID(name string) uint8 {
+	definition, ok := names[name]
+	if !ok {
+		panic(fmt.Errorf("block %v is not defined", name))
+	}
+	return definition.ID
+}
+
+func ByName(name string) (*Definition, bool) {
+	definition, ok := names[name]
+	return definition, ok
+}
+
+// Count returns one more than the highest block id, the length a table
+// indexed by block id needs.
+func Count() int {
+	return len(registry)
+}
+
+// IsSolid reports whether a block id blocks movement. Unknown ids are not
+// solid.
+func IsSolid(id int) bool {
+	definition := Get(id)
+	return definition != nil && definition.Solid
+}
+
+// IsTransparent reports whether the faces behind a block id can be seen.
+// Unknown ids are treated as transparent so nothing next to them is hidden.
+func IsTransparent(id int) bool {
+	definition := Get(id)
+	return definition == nil || definition.Transparent
+}
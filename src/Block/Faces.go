@@ -0,0 +1,72 @@
+package Block
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+var faceNames = [6]string{"bottom", "top", "east", "south", "west", "north"}
+
+// Faces holds the texture of each face, indexed by the face constants.
+type Faces [6]string
+
+// UnmarshalJSON reads either an array of six textures in face order or an
+// object naming them. In the object "all" sets every face, "sides" the four
+// side faces, and "top", "bottom", "north", "south", "east" and "west" set a
+// single face. More specific keys win, so
+//
+//	{"all": "dirt/dirt", "top": "grass/grass"}
+//
+// is dirt with a grass top.
+func (faces *Faces) UnmarshalJSON(data []byte) error {
+	list := []string{}
+	if err := json.Unmarshal(data, &list); err == nil {
+		if len(list) != len(faces) {
+			return fmt.Errorf("textures list has %v faces, expected %v", len(list), len(faces))
+		}
+		copy(faces[:], list)
+		return nil
+	}
+
+	named := map[string]string{}
+	if err := json.Unmarshal(data, &named); err != nil {
+		return fmt.Errorf("textures must be a list of six faces or an object of named faces: %v", err)
+	}
+	for key := range named {
+		if key != "all" && key != "sides" && faceIndex(key) < 0 {
+			return fmt.Errorf("unknown face %v", key)
+		}
+	}
+	for i := range faces {
+		faces[i] = named["all"]
+	}
+	if sides, ok := named["sides"]; ok {
+		faces[East], faces[South], faces[West], faces[North] = sides, sides, sides, sides
+	}
+	for key, texture := range named {
+		if i := faceIndex(key); i >= 0 {
+			faces[i] = texture
+		}
+	}
+	return nil
+}
+
+// missing returns the first face without a texture, or -1 if every face has
+// one.
+func (faces Faces) missing() int {
+	for i, texture := range faces {
+		if texture == "" {
+			return i
+		}
+	}
+	return -1
+}
+
+func faceIndex(name string) int {
+	for i, face := range faceNames {
+		if face == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,101 @@
+package Camera
+
+import (
+	m "math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	// Camera Modes
+	FirstPerson = iota
+	ThirdPerson
+	FreeCam
+)
+
+const (
+	maxPitch      float32 = 89.0
+	orbitDistance float32 = 4.0
+)
+
+type Camera struct {
+	Position       mgl32.Vec3
+	Yaw, Pitch     float32
+	FOV, Near, Far float32
+	Aspect         float32
+	Mode           int
+	Distance       float32
+	target         mgl32.Vec3
+}
+
+func CreateCamera(fov, aspect, near, far float32) *Camera {
+	return &Camera{FOV: fov, Aspect: aspect, Near: near, Far: far, Mode: FirstPerson, Distance: orbitDistance}
+}
+
+// Orientation is built from yaw around the world Y axis followed by pitch
+// around the camera's X axis. A yaw and pitch of zero looks down -Z.
+func (camera *Camera) Orientation() mgl32.Quat {
+	yaw := mgl32.QuatRotate(mgl32.DegToRad(-camera.Yaw), mgl32.Vec3{0, 1, 0})
+	pitch := mgl32.QuatRotate(mgl32.DegToRad(camera.Pitch), mgl32.Vec3{1, 0, 0})
+	return yaw.Mul(pitch)
+}
+
+func (camera *Camera) Forward() mgl32.Vec3 {
+	return camera.Orientation().Rotate(mgl32.Vec3{0, 0, -1})
+}
+
+func (camera *Camera) Right() mgl32.Vec3 {
+	return camera.Orientation().Rotate(mgl32.Vec3{1, 0, 0})
+}
+
+func (camera *Camera) Up() mgl32.Vec3 {
+	return camera.Orientation().Rotate(mgl32.Vec3{0, 1, 0})
+}
+
+func (camera *Camera) Rotate(yaw, pitch float32) {
+	camera.Yaw = float32(m.Mod(float64(camera.Yaw+yaw), 360))
+	camera.Pitch = mgl32.Clamp(camera.Pitch+pitch, -maxPitch, maxPitch)
+}
+
+// Follow moves the point the camera is attached to. In first person this is
+// the eye position, in third person it is the point being orbited. A free
+// camera ignores it and keeps its own position.
+func (camera *Camera) Follow(x, y, z float32) {
+	camera.target = mgl32.Vec3{x, y, z}
+	switch camera.Mode {
+	case FirstPerson:
+		camera.Position = camera.target
+	case ThirdPerson:
+		camera.Position = camera.target.Sub(camera.Forward().Mul(camera.Distance))
+	}
+}
+
+// Move translates a free camera along its own forward, right and up axes.
+func (camera *Camera) Move(forward, right, up float32) {
+	if camera.Mode != FreeCam {
+		return
+	}
+	camera.Position = camera.Position.
+		Add(camera.Forward().Mul(forward)).
+		Add(camera.Right().Mul(right)).
+		Add(mgl32.Vec3{0, up, 0})
+}
+
+func (camera *Camera) NextMode() {
+	camera.Mode = (camera.Mode + 1) % (FreeCam + 1)
+	camera.Follow(camera.target.X(), camera.target.Y(), camera.target.Z())
+}
+
+func (camera *Camera) IsDetached() bool {
+	return camera.Mode == FreeCam
+}
+
+func (camera *Camera) ViewMatrix() mgl32.Mat4 {
+	eye := camera.Position
+	rotation := camera.Orientation().Inverse().Mat4()
+	return rotation.Mul4(mgl32.Translate3D(-eye.X(), -eye.Y(), -eye.Z()))
+}
+
+func (camera *Camera) ProjectionMatrix() mgl32.Mat4 {
+	return mgl32.Perspective(mgl32.DegToRad(camera.FOV), camera.Aspect, camera.Near, camera.Far)
+}
@@ -0,0 +1,153 @@
+package Camera
+
+import (
+	m "math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const sin45 float32 = 0.70710678
+
+func near(a, b mgl32.Vec3) bool {
+	for i := range a {
+		if m.Abs(float64(a[i]-b[i])) > 1e-4 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestForward(t *testing.T) {
+	tests := []struct {
+		name       string
+		yaw, pitch float32
+		want       mgl32.Vec3
+	}{
+		{"straight ahead", 0, 0, mgl32.Vec3{0, 0, -1}},
+		{"turned right", 90, 0, mgl32.Vec3{1, 0, 0}},
+		{"turned around", 180, 0, mgl32.Vec3{0, 0, 1}},
+		{"turned left", 270, 0, mgl32.Vec3{-1, 0, 0}},
+		{"looking up", 0, 45, mgl32.Vec3{0, sin45, -sin45}},
+		{"looking down to the right", 90, -45, mgl32.Vec3{sin45, -sin45, 0}},
+	}
+	for _, test := range tests {
+		camera := CreateCamera(90, 1, 0.1, 100)
+		camera.Rotate(test.yaw, test.pitch)
+		if forward := camera.Forward(); !near(forward, test.want) {
+			t.Errorf("%v: Forward() = %v, want %v", test.name, forward, test.want)
+		}
+		if up := camera.Up(); m.Abs(float64(up.Dot(camera.Forward()))) > 1e-4 {
+			t.Errorf("%v: Up() = %v is not square to Forward()", test.name, up)
+		}
+	}
+}
+
+func TestRotate(t *testing.T) {
+	tests := []struct {
+		name       string
+		yaw, pitch float32
+		wantYaw    float32
+		wantPitch  float32
+	}{
+		{"inside the limits", 30, 20, 30, 20},
+		{"past a full turn", 400, 0, 40, 0},
+		{"past straight up", 0, 120, 0, maxPitch},
+		{"past straight down", 0, -120, 0, -maxPitch},
+	}
+	for _, test := range tests {
+		camera := CreateCamera(90, 1, 0.1, 100)
+		camera.Rotate(test.yaw, test.pitch)
+		if camera.Yaw != test.wantYaw || camera.Pitch != test.wantPitch {
+			t.Errorf("%v: yaw %v pitch %v, want %v and %v", test.name, camera.Yaw, camera.Pitch, test.wantYaw, test.wantPitch)
+		}
+	}
+}
+
+func TestModes(t *testing.T) {
+	target := mgl32.Vec3{10, 5, -3}
+	tests := []struct {
+		name     string
+		mode     int
+		position mgl32.Vec3
+		// Where the followed point and a point one ahead of the camera end up
+		// in view space.
+		viewTarget, viewAhead mgl32.Vec3
+	}{
+		{"first person", FirstPerson, target, mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 0, -1}},
+		{"third person", ThirdPerson, mgl32.Vec3{10 - orbitDistance, 5, -3}, mgl32.Vec3{0, 0, -orbitDistance}, mgl32.Vec3{0, 0, -1}},
+		{"free camera", FreeCam, mgl32.Vec3{1, 2, 3}, mgl32.Vec3{-6, 3, -9}, mgl32.Vec3{0, 0, -1}},
+	}
+	for _, test := range tests {
+		camera := CreateCamera(90, 1, 0.1, 100)
+		camera.Mode = test.mode
+		camera.Position = mgl32.Vec3{1, 2, 3}
+		// Turned right, so the camera looks down +X.
+		camera.Rotate(90, 0)
+		camera.Follow(target.X(), target.Y(), target.Z())
+
+		if !near(camera.Position, test.position) {
+			t.Errorf("%v: position %v, want %v", test.name, camera.Position, test.position)
+		}
+		if camera.IsDetached() != (test.mode == FreeCam) {
+			t.Errorf("%v: IsDetached() = %v", test.name, camera.IsDetached())
+		}
+		view := camera.ViewMatrix()
+		if got := view.Mul4x1(target.Vec4(1)).Vec3(); !near(got, test.viewTarget) {
+			t.Errorf("%v: followed point at %v in view space, want %v", test.name, got, test.viewTarget)
+		}
+		ahead := camera.Position.Add(camera.Forward())
+		if got := view.Mul4x1(ahead.Vec4(1)).Vec3(); !near(got, test.viewAhead) {
+			t.Errorf("%v: point ahead at %v in view space, want %v", test.name, got, test.viewAhead)
+		}
+	}
+}
+
+func TestMove(t *testing.T) {
+	tests := []struct {
+		name string
+		mode int
+		want mgl32.Vec3
+	}{
+		{"first person", FirstPerson, mgl32.Vec3{1, 2, 3}},
+		{"third person", ThirdPerson, mgl32.Vec3{1, 2, 3}},
+		// Turned right, forward is +X and right is +Z.
+		{"free camera", FreeCam, mgl32.Vec3{3, 5, 2}},
+	}
+	for _, test := range tests {
+		camera := CreateCamera(90, 1, 0.1, 100)
+		camera.Mode = test.mode
+		camera.Position = mgl32.Vec3{1, 2, 3}
+		camera.Rotate(90, 0)
+		camera.Move(2, -1, 3)
+		if !near(camera.Position, test.want) {
+			t.Errorf("%v: position %v, want %v", test.name, camera.Position, test.want)
+		}
+	}
+}
+
+func TestNextMode(t *testing.T) {
+	camera := CreateCamera(90, 1, 0.1, 100)
+	camera.Follow(0, 10, 0)
+
+	// A free camera stays where the third person camera left it, and first
+	// person snaps back onto the followed point.
+	steps := []struct {
+		mode     int
+		position mgl32.Vec3
+	}{
+		{ThirdPerson, mgl32.Vec3{0, 10, orbitDistance}},
+		{FreeCam, mgl32.Vec3{0, 10, orbitDistance}},
+		{FirstPerson, mgl32.Vec3{0, 10, 0}},
+		{ThirdPerson, mgl32.Vec3{0, 10, orbitDistance}},
+	}
+	for i, step := range steps {
+		camera.NextMode()
+		if camera.Mode != step.mode {
+			t.Fatalf("step %v: mode %v, want %v", i, camera.Mode, step.mode)
+		}
+		if !near(camera.Position, step.position) {
+			t.Errorf("step %v: position %v, want %v", i, camera.Position, step.position)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package Camera
+
+import "github.com/go-gl/mathgl/mgl32"
+
+const (
+	// Frustum Planes
+	leftPlane = iota
+	rightPlane
+	bottomPlane
+	topPlane
+	nearPlane
+	farPlane
+)
+
+type Plane struct {
+	Normal   mgl32.Vec3
+	Distance float32
+}
+
+type Frustum [6]Plane
+
+// ExtractFrustum pulls the six clipping planes out of a combined
+// projection*camera matrix. Plane normals point into the frustum.
+func ExtractFrustum(matrix mgl32.Mat4) Frustum {
+	var frustum Frustum
+	w := matrix.Row(3)
+	for i := 0; i < 3; i++ {
+		row := matrix.Row(i)
+		frustum[i*2] = createPlane(w.Add(row))
+		frustum[(i*2)+1] = createPlane(w.Sub(row))
+	}
+	return frustum
+}
+
+func createPlane(coefficients mgl32.Vec4) Plane {
+	normal := coefficients.Vec3()
+	length := normal.Len()
+	if length == 0 {
+		return Plane{normal, coefficients.W()}
+	}
+	return Plane{normal.Mul(1 / length), coefficients.W() / length}
+}
+
+func (camera *Camera) Frustum() Frustum {
+	return ExtractFrustum(camera.ProjectionMatrix().Mul4(camera.ViewMatrix()))
+}
+
+// IntersectsBox reports whether any part of the axis aligned box lies inside
+// the frustum. For each plane only the corner furthest along the normal is
+// tested; if even that corner is behind the plane the whole box is.
+func (frustum Frustum) IntersectsBox(min, max mgl32.Vec3) bool {
+	for _, plane := range frustum {
+		corner := min
+		if plane.Normal.X() >= 0 {
+			corner[0] = max.X()
+		}
+		if plane.Normal.Y() >= 0 {
+			corner[1] = max.Y()
+		}
+		if plane.Normal.Z() >= 0 {
+			corner[2] = max.Z()
+		}
+		if plane.Normal.Dot(corner)+plane.Distance < 0 {
+			return false
+		}
+	}
+	return true
+}
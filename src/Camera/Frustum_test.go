@@ -0,0 +1,45 @@
+package Camera
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func TestIntersectsBox(t *testing.T) {
+	camera := CreateCamera(90, 1, 0.1, 100)
+	frustum := camera.Frustum()
+
+	tests := []struct {
+		name     string
+		min, max mgl32.Vec3
+		want     bool
+	}{
+		{"ahead", mgl32.Vec3{-1, -1, -11}, mgl32.Vec3{1, 1, -9}, true},
+		{"around the eye", mgl32.Vec3{-1, -1, -1}, mgl32.Vec3{1, 1, 1}, true},
+		{"behind", mgl32.Vec3{-1, -1, 9}, mgl32.Vec3{1, 1, 11}, false},
+		{"left of the view", mgl32.Vec3{-30, -1, -11}, mgl32.Vec3{-20, 1, -9}, false},
+		{"right of the view", mgl32.Vec3{20, -1, -11}, mgl32.Vec3{30, 1, -9}, false},
+		{"above the view", mgl32.Vec3{-1, 20, -11}, mgl32.Vec3{1, 30, -9}, false},
+		{"past the far plane", mgl32.Vec3{-1, -1, -120}, mgl32.Vec3{1, 1, -110}, false},
+		{"across the left plane", mgl32.Vec3{-15, -1, -11}, mgl32.Vec3{-5, 1, -9}, true},
+	}
+	for _, test := range tests {
+		if got := frustum.IntersectsBox(test.min, test.max); got != test.want {
+			t.Errorf("%v: IntersectsBox(%v, %v) = %v, want %v", test.name, test.min, test.max, got, test.want)
+		}
+	}
+}
+
+func TestFrustumFollowsYaw(t *testing.T) {
+	camera := CreateCamera(90, 1, 0.1, 100)
+	camera.Rotate(90, 0)
+	frustum := camera.Frustum()
+
+	if !frustum.IntersectsBox(mgl32.Vec3{9, -1, -1}, mgl32.Vec3{11, 1, 1}) {
+		t.Error("box on +X not visible after turning right")
+	}
+	if frustum.IntersectsBox(mgl32.Vec3{-1, -1, -11}, mgl32.Vec3{1, 1, -9}) {
+		t.Error("box on -Z still visible after turning right")
+	}
+}
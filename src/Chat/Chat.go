@@ -0,0 +1,175 @@
+package Chat
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/allanks/Voxel-Engine/src/Graphics"
+	"github.com/allanks/Voxel-Engine/src/Network"
+	"github.com/allanks/Voxel-Engine/src/Server/DataType"
+	"github.com/allanks/Voxel-Engine/src/glText45"
+	"github.com/go-gl/glfw/v3.1/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const fontFile string = "resource/fonts/Arial/arial.ttf"
+
+// Lines fade after lineLifetime unless the chat is open, which shows the
+// last maxShown lines however old they are.
+const (
+	fontScale    int32   = 12
+	lineLifetime         = 10 * time.Second
+	maxShown     int     = 10
+	maxLines     int     = 100
+	maxDraft     int     = 256
+	margin       float32 = 4
+	// The font has glyphs for printable ASCII
+	firstGlyph, lastGlyph rune = 32, 126
+)
+
+var (
+	textColor  = mgl32.Vec4{1, 1, 1, 1}
+	draftColor = mgl32.Vec4{1, 1, 0.6, 1}
+)
+
+var (
+	lines    []line
+	lineLock sync.Mutex
+	typing   bool
+	skipChar bool
+	draft    []rune
+	overlay  *glText45.Overlay
+	// frameHeight is the height of the frame the overlay draws over
+	frameHeight float32
+)
+
+type line struct {
+	text string
+	at   time.Time
+}
+
+// Listen registers the handler for the lines the server sends. It has to
+// be called before connecting.
+func Listen() {
+	Network.Handle(DataType.Text, func(message *DataType.Message) {
+		addLines(message.Text)
+	})
+	Network.Handle(DataType.Disconnect, func(message *DataType.Message) {
+		addLines("Disconnected: " + message.Text)
+	})
+}
+
+func addLines(text string) {
+	lineLock.Lock()
+	defer lineLock.Unlock()
+	for _, text := range strings.Split(text, "\n") {
+		fmt.Println(text)
+		lines = append(lines, line{text, time.Now()})
+	}
+	if len(lines) > maxLines {
+		lines = lines[len(lines)-maxLines:]
+	}
+}
+
+// InitOverlay loads the font the chat is drawn with over a frame of width
+// by height pixels. Without the font the chat is only printed.
+func InitOverlay(control Graphics.OpenGLControl, width, height int) {
+	ttf, err := os.Open(fontFile)
+	if err != nil {
+		fmt.Printf("Chat is only printed, %v\n", err)
+		return
+	}
+	defer ttf.Close()
+	if overlay, err = glText45.CreateOverlay(control, ttf, fontScale, width, height); err != nil {
+		panic(err)
+	}
+	frameHeight = float32(height)
+}
+
+// IsTyping reports whether keys go to the chat instead of the player.
+func IsTyping() bool {
+	return typing
+}
+
+// OnKey opens the chat on T, or on / to type a command, and takes every key
+// while it is open. It reports whether it took the key.
+func OnKey(window *glfw.Window, k glfw.Key, s int, action glfw.Action, mods glfw.ModifierKey) bool {
+	if !typing {
+		if action != glfw.Press || (k != glfw.KeyT && k != glfw.KeySlash) {
+			return false
+		}
+		// The / is typed into the draft by its character, the T is not
+		typing, skipChar, draft = true, k == glfw.KeyT, nil
+		return true
+	}
+	if action == glfw.Release {
+		return true
+	}
+	switch k {
+	case glfw.KeyEscape:
+		typing = false
+	case glfw.KeyEnter:
+		typing = false
+		if text := strings.TrimSpace(string(draft)); text != "" {
+			Network.Send(&DataType.Message{Type: DataType.Text, Text: text})
+		}
+	case glfw.KeyBackspace:
+		if len(draft) > 0 {
+			draft = draft[:len(draft)-1]
+		}
+	}
+	return true
+}
+
+// OnChar adds typed characters to the draft while the chat is open.
+func OnChar(window *glfw.Window, char rune) {
+	if !typing {
+		return
+	}
+	if skipChar {
+		skipChar = false
+		if char == 't' || char == 'T' {
+			return
+		}
+	}
+	if len(draft) < maxDraft {
+		draft = append(draft, char)
+	}
+}
+
+// Render draws the recent lines above the draft at the bottom left.
+func Render() {
+	if overlay == nil {
+		return
+	}
+	shown := []glText45.Line{}
+	y := frameHeight - margin
+	if typing {
+		y -= overlay.LineHeight()
+		shown = append(shown, glText45.Line{X: margin, Y: y, Color: draftColor, Text: printable("> " + string(draft) + "_")})
+	}
+
+	lineLock.Lock()
+	defer lineLock.Unlock()
+	for i := len(lines) - 1; i >= 0 && len(shown) < maxShown; i-- {
+		if !typing && time.Since(lines[i].at) > lineLifetime {
+			break
+		}
+		y -= overlay.LineHeight()
+		shown = append(shown, glText45.Line{X: margin, Y: y, Color: textColor, Text: printable(lines[i].text)})
+	}
+	overlay.Draw(shown)
+}
+
+// printable replaces the characters the font has no glyph for.
+func printable(text string) string {
+	return strings.Map(func(char rune) rune {
+		if char < firstGlyph || char > lastGlyph {
+			return '?'
+		}
+		return char
+	}, text)
+}
@@ -0,0 +1,195 @@
+package Entity
+
+import (
+	m "math"
+	"sync"
+	"time"
+
+	"github.com/allanks/Voxel-Engine/src/Model"
+	"github.com/allanks/Voxel-Engine/src/Network"
+	"github.com/allanks/Voxel-Engine/src/Server/DataType"
+)
+
+// models maps the entity kinds to the model drawn for them.
+var models = map[int]int{
+	DataType.EntityGopher: Model.Gopher,
+	DataType.EntityPlayer: Model.Gopher,
+}
+
+// Clips skinned models play while their entity stands still and while it
+// moves.
+const (
+	idleClip string = "idle"
+	walkClip string = "walk"
+)
+
+// Entities are drawn interpolationDelay behind the latest state the server
+// sent, so there is nearly always a later state to move towards.
+const (
+	interpolationDelay = 100 * time.Millisecond
+	maxSnapshots       = 32
+)
+
+var (
+	entities   = map[uint32]*remoteEntity{}
+	entityLock sync.Mutex
+	localID    uint32
+	started    = time.Now()
+)
+
+// remoteEntity is an entity as the server last described it, with the
+// states it was sent in the order they arrived.
+type remoteEntity struct {
+	DataType.Entity
+	snapshots []snapshot
+}
+
+type snapshot struct {
+	at       time.Time
+	position DataType.Pos
+	yaw      float32
+}
+
+// drawGroup is the entities drawn with one model playing one clip.
+type drawGroup struct {
+	modelType int
+	clip      string
+}
+
+// Listen registers the handlers that keep the entities in step with the
+// server. It has to be called before connecting.
+func Listen() {
+	Network.Handle(DataType.EntitySpawn, spawn)
+	Network.Handle(DataType.EntityUpdate, update)
+	Network.Handle(DataType.EntityDespawn, despawn)
+	Network.Handle(DataType.Welcome, welcome)
+}
+
+// LocalID returns the entity ID the server gave the player, zero until it
+// has joined.
+func LocalID() uint32 {
+	entityLock.Lock()
+	defer entityLock.Unlock()
+	return localID
+}
+
+func welcome(message *DataType.Message) {
+	if message.Player == nil {
+		return
+	}
+	entityLock.Lock()
+	defer entityLock.Unlock()
+	localID = message.Player.ID
+	delete(entities, localID)
+}
+
+// spawn starts an entity over, it is drawn where it is without moving in
+// from an older state.
+func spawn(message *DataType.Message) {
+	if message.Entity == nil {
+		return
+	}
+	entityLock.Lock()
+	defer entityLock.Unlock()
+	if message.Entity.ID == localID {
+		return
+	}
+	entities[message.Entity.ID] = &remoteEntity{Entity: *message.Entity}
+	entities[message.Entity.ID].record(time.Now())
+}
+
+func update(message *DataType.Message) {
+	if message.Entity == nil {
+		return
+	}
+	entityLock.Lock()
+	current, ok := entities[message.Entity.ID]
+	if ok {
+		current.Entity = *message.Entity
+		current.record(time.Now())
+	}
+	entityLock.Unlock()
+	if !ok {
+		spawn(message)
+	}
+}
+
+func despawn(message *DataType.Message) {
+	if message.Entity == nil {
+		return
+	}
+	entityLock.Lock()
+	defer entityLock.Unlock()
+	delete(entities, message.Entity.ID)
+}
+
+func (current *remoteEntity) record(at time.Time) {
+	current.snapshots = append(current.snapshots, snapshot{at, current.Position, current.Yaw})
+	if len(current.snapshots) > maxSnapshots {
+		current.snapshots = current.snapshots[len(current.snapshots)-maxSnapshots:]
+	}
+}
+
+// interpolate returns where the entity was at a time, between the two
+// snapshots around it. Before the first snapshot or after the last the
+// nearest one is held. Snapshots no longer needed are dropped.
+func (current *remoteEntity) interpolate(at time.Time) (DataType.Pos, float32) {
+	snapshots := current.snapshots
+	for len(snapshots) > 1 && !snapshots[1].at.After(at) {
+		snapshots = snapshots[1:]
+	}
+	current.snapshots = snapshots
+
+	from := snapshots[0]
+	if len(snapshots) == 1 || at.Before(from.at) {
+		return from.position, from.yaw
+	}
+	to := snapshots[1]
+	t := float32(at.Sub(from.at).Seconds() / to.at.Sub(from.at).Seconds())
+	position := DataType.Pos{
+		XPos: from.position.XPos + ((to.position.XPos - from.position.XPos) * t),
+		YPos: from.position.YPos + ((to.position.YPos - from.position.YPos) * t),
+		ZPos: from.position.ZPos + ((to.position.ZPos - from.position.ZPos) * t)}
+	return position, from.yaw + (angleBetween(from.yaw, to.yaw) * t)
+}
+
+// angleBetween returns the shortest turn from one yaw to another.
+func angleBetween(from, to float32) float32 {
+	turn := m.Mod(float64(to-from), 2*m.Pi)
+	if turn > m.Pi {
+		turn -= 2 * m.Pi
+	} else if turn < -m.Pi {
+		turn += 2 * m.Pi
+	}
+	return float32(turn)
+}
+
+// Render draws every entity with the model of its kind, one instanced draw
+// per model and clip. The yaw of the entity goes in the fourth instance
+// component.
+func Render() {
+	now := time.Now()
+	at := now.Add(-interpolationDelay)
+	instances := map[drawGroup][]float32{}
+	entityLock.Lock()
+	for _, current := range entities {
+		modelType, ok := models[current.Kind]
+		if !ok {
+			continue
+		}
+		group := drawGroup{modelType, idleClip}
+		if current.Velocity.XPos != 0 || current.Velocity.ZPos != 0 {
+			group.clip = walkClip
+		}
+		position, yaw := current.interpolate(at)
+		instances[group] = append(instances[group], position.XPos, position.YPos, position.ZPos, yaw)
+	}
+	entityLock.Unlock()
+
+	seconds := float32(now.Sub(started).Seconds())
+	for group, groupInstances := range instances {
+		Model.Animate(group.modelType, group.clip, seconds)
+		Model.BindBuffers([]float32{0.0, 0.0, 0.0}, group.modelType)
+		Model.Render(groupInstances, group.modelType)
+	}
+}
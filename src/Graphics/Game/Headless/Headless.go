@@ -0,0 +1,257 @@
+package Headless
+
+import (
+	"github.com/allanks/Voxel-Engine/src/Graphics"
+	controlHeadless "github.com/allanks/Voxel-Engine/src/Graphics/Headless"
+	"github.com/allanks/Voxel-Engine/src/Graphics/Software"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// HeadlessGame implements Graphics.OpenGLController by recording buffer
+// uploads, uniform and texture bindings and draw calls instead of issuing
+// them to a GL context. Draw calls are also rasterized when a Rasterizer is
+// set. Only cube program draws are rasterized, the rasterizer does not
+// follow the mob program shading.
+type HeadlessGame struct {
+	Control                  Graphics.OpenGLControl
+	Rasterizer               *Software.Rasterizer
+	Uploads                  []BufferUpload
+	UniformBindings          []Uniforms
+	TextureBindings          []uint32
+	TextureArrayBindings     []uint32
+	MaterialBindings         []Graphics.Material
+	DrawCalls                []DrawCall
+	Projection, Camera       mgl32.Mat4
+	JointMatrices            []mgl32.Mat4
+	cubeProgram, mobProgram  uint32
+	skinnedProgram           uint32
+	program                  uint32
+	buffersCreated, uniforms bool
+	upload                   BufferUpload
+	uniform                  Uniforms
+	texture                  uint32
+	layered                  bool
+	material                 Graphics.Material
+}
+
+type BufferUpload struct {
+	Vertices, Normals, TextureData, UV []float32
+	Joints, Weights                    []float32
+	Indices                            []uint32
+}
+
+type Uniforms struct {
+	Scale, Length float32
+	Offset        [3]float32
+}
+
+// DrawCall captures everything bound at the time RenderInstances was called.
+type DrawCall struct {
+	Program     uint32
+	Texture     uint32
+	Layered     bool
+	Material    Graphics.Material
+	First       int32
+	VertexCount int32
+	Indexed     bool
+	Instances   []float32
+	Buffers     BufferUpload
+	Uniforms    Uniforms
+	Projection  mgl32.Mat4
+	Camera      mgl32.Mat4
+	Joints      []mgl32.Mat4
+	DepthWrite  bool
+}
+
+func (game *HeadlessGame) CreateBuffers() {
+	game.buffersCreated = true
+}
+
+func (game *HeadlessGame) CreateUniforms() {
+	game.uniforms = true
+}
+
+func (game *HeadlessGame) BindFragData() {}
+
+func (game *HeadlessGame) BindProjection(projection mgl32.Mat4) {
+	game.Projection = projection
+}
+
+func (game *HeadlessGame) BindBuffers(bufferData ...[]float32) {
+	game.upload = BufferUpload{
+		Vertices:    copyFloats(bufferData[0]),
+		Normals:     copyFloats(bufferData[1]),
+		TextureData: copyFloats(bufferData[2]),
+		UV:          copyFloats(bufferData[3]),
+	}
+	game.Uploads = append(game.Uploads, game.upload)
+}
+
+// BindIndices adds the indices to the last buffer upload, which is what the
+// next indexed draw uses.
+func (game *HeadlessGame) BindIndices(indices []uint32) {
+	game.upload.Indices = append([]uint32(nil), indices...)
+	if len(game.Uploads) > 0 {
+		game.Uploads[len(game.Uploads)-1] = game.upload
+	}
+}
+
+// BindSkin adds the joints and weights to the last buffer upload.
+func (game *HeadlessGame) BindSkin(joints, weights []float32) {
+	game.upload.Joints, game.upload.Weights = copyFloats(joints), copyFloats(weights)
+	if len(game.Uploads) > 0 {
+		game.Uploads[len(game.Uploads)-1] = game.upload
+	}
+}
+
+func (game *HeadlessGame) BindJointMatrices(matrices []mgl32.Mat4) {
+	game.JointMatrices = append([]mgl32.Mat4(nil), matrices...)
+}
+
+func (game *HeadlessGame) BindUniforms(parameters ...[]float32) {
+	game.uniform = Uniforms{
+		Scale:  parameters[0][0],
+		Length: parameters[1][0],
+		Offset: [3]float32{parameters[2][0], parameters[2][1], parameters[2][2]},
+	}
+	game.UniformBindings = append(game.UniformBindings, game.uniform)
+}
+
+func (game *HeadlessGame) BindTexture(texture uint32) {
+	game.texture = texture
+	game.layered = false
+	game.TextureBindings = append(game.TextureBindings, texture)
+}
+
+func (game *HeadlessGame) BindTextureArray(texture uint32) {
+	game.texture = texture
+	game.layered = true
+	game.TextureArrayBindings = append(game.TextureArrayBindings, texture)
+}
+
+func (game *HeadlessGame) BindMaterial(material Graphics.Material) {
+	game.material = material
+	game.MaterialBindings = append(game.MaterialBindings, material)
+}
+
+func (game *HeadlessGame) SelectProgram(program int) {
+	switch program {
+	case Graphics.MobProgram:
+		game.program = game.mobProgram
+	case Graphics.SkinnedProgram:
+		game.program = game.skinnedProgram
+	default:
+		game.program = game.cubeProgram
+	}
+}
+
+func (game *HeadlessGame) RenderInstances(instances []float32, bufferSize int32) {
+	game.RenderInstanceRange(instances, 0, bufferSize)
+}
+
+func (game *HeadlessGame) RenderInstanceRange(instances []float32, first, count int32) {
+	game.render(instances, first, count, false)
+}
+
+func (game *HeadlessGame) RenderIndexedInstances(instances []float32, first, count int32) {
+	game.render(instances, first, count, true)
+}
+
+func (game *HeadlessGame) render(instances []float32, first, count int32, indexed bool) {
+	depthWrite := true
+	textureFile, layers := "", 0
+	if control, ok := game.Control.(*controlHeadless.HeadlessControl); ok {
+		depthWrite = control.DepthWrite
+		textureFile = control.TextureFile(game.texture)
+		if game.layered {
+			layers = control.TextureLayers(game.texture)
+		}
+	}
+	call := DrawCall{
+		Program:     game.program,
+		Texture:     game.texture,
+		Layered:     game.layered,
+		Material:    game.material,
+		First:       first,
+		VertexCount: count,
+		Indexed:     indexed,
+		Instances:   copyFloats(instances),
+		Buffers:     game.upload,
+		Uniforms:    game.uniform,
+		Projection:  game.Projection,
+		Camera:      game.Camera,
+		DepthWrite:  depthWrite,
+	}
+	if game.program == game.skinnedProgram {
+		call.Joints = game.JointMatrices
+	}
+	game.DrawCalls = append(game.DrawCalls, call)
+
+	if game.Rasterizer != nil && game.program == game.cubeProgram {
+		game.rasterize(call, textureFile, layers)
+	}
+}
+
+func (game *HeadlessGame) rasterize(call DrawCall, textureFile string, layers int) {
+	draw := Software.Draw{
+		Vertices:    call.Buffers.Vertices,
+		Normals:     call.Buffers.Normals,
+		TextureData: call.Buffers.TextureData,
+		UV:          call.Buffers.UV,
+		Instances:   call.Instances,
+		Offset:      call.Uniforms.Offset,
+		Length:      call.Uniforms.Length,
+		First:       call.First,
+		VertexCount: call.VertexCount,
+		Projection:  call.Projection,
+		Camera:      call.Camera,
+		DepthWrite:  call.DepthWrite,
+		Layers:      layers,
+	}
+	if call.Indexed {
+		draw.Indices = call.Buffers.Indices
+	}
+	if textureFile != "" {
+		texture, err := game.Rasterizer.LoadTexture(textureFile)
+		if err != nil {
+			panic(err)
+		}
+		draw.Texture = texture
+	}
+	game.Rasterizer.Render(draw)
+}
+
+func (game *HeadlessGame) StartPrograms() {
+	game.cubeProgram = game.Control.NewProgram("cubeShader.shad", "cubeFrag.frag")
+	game.mobProgram = game.Control.NewProgram("mobShader.shad", "mobFragment.frag")
+	game.skinnedProgram = game.Control.NewProgram("skinnedShader.shad", "mobFragment.frag")
+	game.program = game.cubeProgram
+}
+
+func (game *HeadlessGame) UpdateProjection(states mgl32.Mat4) {
+	game.Camera = states
+}
+
+// Reset forgets every recorded call but keeps the bound state, so a test can
+// inspect a single frame.
+func (game *HeadlessGame) Reset() {
+	game.Uploads = nil
+	game.UniformBindings = nil
+	game.TextureBindings = nil
+	game.TextureArrayBindings = nil
+	game.MaterialBindings = nil
+	game.DrawCalls = nil
+}
+
+// Instances returns how many instances were drawn over all recorded calls.
+func (game *HeadlessGame) Instances() int {
+	count := 0
+	for _, call := range game.DrawCalls {
+		count += len(call.Instances) / 4
+	}
+	return count
+}
+
+func copyFloats(data []float32) []float32 {
+	return append([]float32(nil), data...)
+}
@@ -0,0 +1,181 @@
+package Headless
+
+import (
+	"testing"
+
+	"github.com/allanks/Voxel-Engine/src/Graphics"
+	controlHeadless "github.com/allanks/Voxel-Engine/src/Graphics/Headless"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func createTestGame() (*HeadlessGame, *controlHeadless.HeadlessControl) {
+	control := &controlHeadless.HeadlessControl{}
+	control.Init()
+	game := &HeadlessGame{Control: control}
+	game.CreateBuffers()
+	game.CreateUniforms()
+	game.StartPrograms()
+	return game, control
+}
+
+func TestRecordsDrawCalls(t *testing.T) {
+	game, control := createTestGame()
+	projection := mgl32.Perspective(1, 1, 0.1, 10)
+	game.BindProjection(projection)
+	game.UpdateProjection(mgl32.Translate3D(0, 0, -5))
+
+	texture := control.CreateTexture("atlas.png")
+	vertices := []float32{0, 0, 0, 1, 0, 0, 0, 1, 0}
+	game.BindBuffers(vertices, vertices, []float32{0}, []float32{0, 0, 1, 0, 0, 1})
+	game.BindUniforms([]float32{1}, []float32{3}, []float32{16, 0, 32})
+	game.BindTexture(texture)
+	game.RenderInstances([]float32{0, 0, 0, 1, 2, 0, 0, 1}, 3)
+
+	if len(game.DrawCalls) != 1 {
+		t.Fatalf("recorded %v draw calls, want 1", len(game.DrawCalls))
+	}
+	call := game.DrawCalls[0]
+	if call.Program != control.Programs[0].ID || call.Texture != texture {
+		t.Errorf("draw used program %v texture %v, want %v %v", call.Program, call.Texture, control.Programs[0].ID, texture)
+	}
+	if call.VertexCount != 3 {
+		t.Errorf("draw covered %v vertices, want 3", call.VertexCount)
+	}
+	if call.Uniforms.Offset != [3]float32{16, 0, 32} || call.Uniforms.Length != 3 {
+		t.Errorf("draw uniforms %+v, want offset 16 0 32 and length 3", call.Uniforms)
+	}
+	if call.Projection != projection || !call.DepthWrite {
+		t.Error("draw did not keep the bound projection and depth write")
+	}
+	if game.Instances() != 2 {
+		t.Errorf("Instances() = %v, want 2", game.Instances())
+	}
+
+	// Recorded buffers must not follow later changes to the caller's slices
+	vertices[0] = 9
+	if call.Buffers.Vertices[0] != 0 {
+		t.Error("recorded vertices alias the uploaded slice")
+	}
+
+	control.DepthToggle(false)
+	game.RenderInstances([]float32{0, 0, 0, 0}, 3)
+	if game.DrawCalls[1].DepthWrite {
+		t.Error("draw did not follow depth write turned off")
+	}
+
+	game.Reset()
+	if len(game.DrawCalls) != 0 || len(game.Uploads) != 0 || len(game.UniformBindings) != 0 || len(game.TextureBindings) != 0 {
+		t.Error("Reset kept recorded calls")
+	}
+}
+
+func TestRecordsTextureArrays(t *testing.T) {
+	game, control := createTestGame()
+	array := control.CreateTextureArray("atlas.png", 4)
+	atlas := control.CreateTexture("atlas.png")
+	game.BindTextureArray(array)
+	game.RenderInstances([]float32{0, 0, 0, 0}, 3)
+	game.BindTexture(atlas)
+	game.RenderInstances([]float32{0, 0, 0, 0}, 3)
+
+	if len(game.TextureArrayBindings) != 1 || game.TextureArrayBindings[0] != array {
+		t.Errorf("texture array bindings %v, want [%v]", game.TextureArrayBindings, array)
+	}
+	layered, flat := game.DrawCalls[0], game.DrawCalls[1]
+	if !layered.Layered || layered.Texture != array {
+		t.Errorf("first draw layered %v with texture %v, want layered with %v", layered.Layered, layered.Texture, array)
+	}
+	if flat.Layered || flat.Texture != atlas {
+		t.Errorf("second draw layered %v with texture %v, want the atlas %v", flat.Layered, flat.Texture, atlas)
+	}
+
+	game.Reset()
+	if len(game.TextureArrayBindings) != 0 {
+		t.Error("Reset kept texture array bindings")
+	}
+}
+
+func TestRecordsMaterialsAndPrograms(t *testing.T) {
+	game, control := createTestGame()
+	vertices := []float32{0, 0, 0, 1, 0, 0, 0, 1, 0}
+	game.BindBuffers(vertices, vertices, []float32{0}, []float32{0, 0, 1, 0, 0, 1})
+
+	material := Graphics.DefaultMaterial(7)
+	game.SelectProgram(Graphics.MobProgram)
+	game.BindMaterial(material)
+	game.RenderInstanceRange([]float32{0, 0, 0, 0}, 3, 6)
+	game.SelectProgram(Graphics.CubeProgram)
+	game.RenderInstances([]float32{0, 0, 0, 0}, 3)
+
+	if len(game.DrawCalls) != 2 {
+		t.Fatalf("recorded %v draw calls, want 2", len(game.DrawCalls))
+	}
+	mob, cube := game.DrawCalls[0], game.DrawCalls[1]
+	if mob.Program != control.Programs[1].ID || cube.Program != control.Programs[0].ID {
+		t.Errorf("draws used programs %v and %v, want %v and %v", mob.Program, cube.Program, control.Programs[1].ID, control.Programs[0].ID)
+	}
+	if mob.First != 3 || mob.VertexCount != 6 || mob.Material != material {
+		t.Errorf("mob draw covered %v vertices from %v with %+v, want 6 from 3 with the bound material", mob.VertexCount, mob.First, mob.Material)
+	}
+	if cube.First != 0 || cube.VertexCount != 3 {
+		t.Errorf("cube draw covered %v vertices from %v, want 3 from 0", cube.VertexCount, cube.First)
+	}
+
+	game.Reset()
+	if len(game.MaterialBindings) != 0 {
+		t.Error("Reset kept material bindings")
+	}
+}
+
+func TestRecordsIndexedDraws(t *testing.T) {
+	game, _ := createTestGame()
+	vertices := []float32{0, 0, 0, 1, 0, 0, 0, 1, 0, 1, 1, 0}
+	game.BindBuffers(vertices, vertices, []float32{0}, []float32{0, 0, 1, 0, 0, 1, 1, 1})
+	indices := []uint32{0, 1, 2, 2, 1, 3}
+	game.BindIndices(indices)
+	game.RenderIndexedInstances([]float32{0, 0, 0, 0}, 3, 3)
+	game.RenderInstanceRange([]float32{0, 0, 0, 0}, 0, 3)
+
+	if len(game.Uploads) != 1 || len(game.Uploads[0].Indices) != 6 {
+		t.Fatalf("uploads %+v, want the indices added to the one upload", game.Uploads)
+	}
+	indexed, flat := game.DrawCalls[0], game.DrawCalls[1]
+	if !indexed.Indexed || indexed.First != 3 || indexed.VertexCount != 3 {
+		t.Errorf("indexed draw indexed %v covered %v indices from %v, want 3 from 3", indexed.Indexed, indexed.VertexCount, indexed.First)
+	}
+	if flat.Indexed {
+		t.Error("draw without indices recorded as indexed")
+	}
+
+	indices[0] = 9
+	if indexed.Buffers.Indices[0] != 0 {
+		t.Error("recorded indices alias the uploaded slice")
+	}
+}
+
+func TestRecordsSkinnedDraws(t *testing.T) {
+	game, control := createTestGame()
+	vertices := []float32{0, 0, 0, 1, 0, 0, 0, 1, 0}
+	game.BindBuffers(vertices, vertices, []float32{0}, []float32{0, 0, 1, 0, 0, 1})
+	game.BindSkin(make([]float32, 12), []float32{1, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0})
+	game.BindJointMatrices([]mgl32.Mat4{mgl32.Ident4(), mgl32.Translate3D(0, 1, 0)})
+
+	game.SelectProgram(Graphics.SkinnedProgram)
+	game.RenderInstances([]float32{0, 0, 0, 0}, 3)
+	game.SelectProgram(Graphics.MobProgram)
+	game.RenderInstances([]float32{0, 0, 0, 0}, 3)
+
+	if len(control.Programs) != 3 {
+		t.Fatalf("StartPrograms created %v programs, want 3", len(control.Programs))
+	}
+	if len(game.Uploads) != 1 || len(game.Uploads[0].Joints) != 12 || len(game.Uploads[0].Weights) != 12 {
+		t.Fatalf("uploads %+v, want the skin added to the one upload", game.Uploads)
+	}
+	skinned, mob := game.DrawCalls[0], game.DrawCalls[1]
+	if skinned.Program != control.Programs[2].ID || len(skinned.Joints) != 2 {
+		t.Errorf("skinned draw used program %v with %v joints, want %v with 2", skinned.Program, len(skinned.Joints), control.Programs[2].ID)
+	}
+	if mob.Joints != nil {
+		t.Errorf("mob draw kept joints %v", mob.Joints)
+	}
+}
@@ -1,8 +1,6 @@
 package OpenGL45
 
 import (
-	m "math"
-
 	"github.com/allanks/Voxel-Engine/src/Graphics"
 	"github.com/go-gl/glow/gl-core/4.5/gl"
 	"github.com/go-gl/mathgl/mgl32"
@@ -10,10 +8,36 @@ import (
 
 type OpenGL45Game struct {
 	Control                                                                 Graphics.OpenGLControl
-	cubeProgram, mobProgram                                                 uint32
-	vao, vertexBuffer, normalBuffer, typeBuffer, uvBuffer                   uint32
+	cubeProgram, mobProgram, skinnedProgram, program                        uint32
+	vao, vertexBuffer, normalBuffer, typeBuffer, uvBuffer, indexBuffer      uint32
+	jointBuffer, weightBuffer                                               uint32
+	indexType                                                               uint32
 	stateBufferStorageBlock, sunBufferStorageBlock, textureDataStorageBlock uint32
-	length, offset, normalMat, scale, mobOffset, mobNormalMat               int32
+	jointStorageBlock                                                       uint32
+	length, offset, normalMat                                               int32
+	layered, textureArray                                                   int32
+	mob, skinned                                                            mobUniforms
+}
+
+// mobUniforms are the uniform locations the mob program shares with its
+// skinned variant.
+type mobUniforms struct {
+	program                                                                   uint32
+	scale, offset, normalMat, ambient, diffuse, specular, shininess, textured int32
+}
+
+func locateMobUniforms(program uint32) mobUniforms {
+	return mobUniforms{
+		program:   program,
+		scale:     gl.GetUniformLocation(program, gl.Str("scale\x00")),
+		offset:    gl.GetUniformLocation(program, gl.Str("offset\x00")),
+		normalMat: gl.GetUniformLocation(program, gl.Str("normalMatrix\x00")),
+		ambient:   gl.GetUniformLocation(program, gl.Str("ambientColor\x00")),
+		diffuse:   gl.GetUniformLocation(program, gl.Str("diffuseColor\x00")),
+		specular:  gl.GetUniformLocation(program, gl.Str("specularColor\x00")),
+		shininess: gl.GetUniformLocation(program, gl.Str("shininess\x00")),
+		textured:  gl.GetUniformLocation(program, gl.Str("textured\x00")),
+	}
 }
 
 func (game *OpenGL45Game) CreateBuffers() {
@@ -22,9 +46,13 @@ func (game *OpenGL45Game) CreateBuffers() {
 	gl.GenBuffers(1, &game.normalBuffer)
 	gl.GenBuffers(1, &game.typeBuffer)
 	gl.GenBuffers(1, &game.uvBuffer)
+	gl.GenBuffers(1, &game.indexBuffer)
+	gl.GenBuffers(1, &game.jointBuffer)
+	gl.GenBuffers(1, &game.weightBuffer)
 	gl.GenBuffers(1, &game.stateBufferStorageBlock)
 	gl.GenBuffers(1, &game.sunBufferStorageBlock)
 	gl.GenBuffers(1, &game.textureDataStorageBlock)
+	gl.GenBuffers(1, &game.jointStorageBlock)
 	gl.BindVertexArray(game.vao)
 
 	gl.BindBuffer(gl.ARRAY_BUFFER, game.vertexBuffer)
@@ -44,6 +72,15 @@ func (game *OpenGL45Game) CreateBuffers() {
 	gl.EnableVertexAttribArray(3)
 	gl.VertexAttribPointer(3, 2, gl.FLOAT, false, 0, gl.PtrOffset(0))
 
+	// The joint attributes are only enabled while a skin is bound
+	gl.BindBuffer(gl.ARRAY_BUFFER, game.jointBuffer)
+	gl.VertexAttribPointer(4, 4, gl.FLOAT, false, 0, gl.PtrOffset(0))
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, game.weightBuffer)
+	gl.VertexAttribPointer(5, 4, gl.FLOAT, false, 0, gl.PtrOffset(0))
+
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, game.indexBuffer)
+
 	gl.BindBufferBase(gl.UNIFORM_BUFFER, 0, game.stateBufferStorageBlock)
 	gl.BufferData(gl.UNIFORM_BUFFER, 32*4, nil, gl.STATIC_DRAW)
 	gl.BindBufferRange(gl.UNIFORM_BUFFER, 0, game.stateBufferStorageBlock, 0, 32*4)
@@ -53,20 +90,9 @@ func (game *OpenGL45Game) CreateBuffers() {
 	gl.BindBufferRange(gl.UNIFORM_BUFFER, 1, game.sunBufferStorageBlock, 0, 9*4)
 
 	gl.BindBufferBase(gl.SHADER_STORAGE_BUFFER, 0, game.textureDataStorageBlock)
+	gl.BindBufferBase(gl.SHADER_STORAGE_BUFFER, 1, game.jointStorageBlock)
 
-	var sun [9]float32
-	// Sun color
-	sun[0] = 1.0
-	sun[1] = 1.0
-	sun[2] = 1.0
-	sun[3] = 0.0
-	// Sun position
-	sun[4] = float32(m.Cos(250.0 * m.Pi / 180))
-	sun[5] = float32(m.Sin(250.0 * m.Pi / 180))
-	sun[6] = float32(m.Cos(60 * m.Pi / 180))
-	sun[7] = 0.0
-	// Sun intensity
-	sun[8] = 0.8
+	sun := Graphics.SunBlock()
 
 	gl.BindBuffer(gl.UNIFORM_BUFFER, game.sunBufferStorageBlock)
 	gl.BufferSubData(gl.UNIFORM_BUFFER, 0, 4*len(sun), gl.Ptr(&sun[0]))
@@ -77,30 +103,39 @@ func (game *OpenGL45Game) CreateUniforms() {
 	game.length = gl.GetUniformLocation(game.cubeProgram, gl.Str("length\x00"))
 	game.offset = gl.GetUniformLocation(game.cubeProgram, gl.Str("offset\x00"))
 	game.normalMat = gl.GetUniformLocation(game.cubeProgram, gl.Str("normalMatrix\x00"))
+	game.layered = gl.GetUniformLocation(game.cubeProgram, gl.Str("layered\x00"))
+	game.textureArray = gl.GetUniformLocation(game.cubeProgram, gl.Str("texArray\x00"))
 
-	game.scale = gl.GetUniformLocation(game.mobProgram, gl.Str("scale\x00"))
-	game.mobOffset = gl.GetUniformLocation(game.mobProgram, gl.Str("offset\x00"))
-	game.mobNormalMat = gl.GetUniformLocation(game.mobProgram, gl.Str("normalMatrix\x00"))
+	game.mob = locateMobUniforms(game.mobProgram)
+	game.skinned = locateMobUniforms(game.skinnedProgram)
 
 	ident := mgl32.Ident4()
 
-	gl.UniformMatrix4fv(game.normalMat, 1, true, &ident[0])
-	gl.UniformMatrix4fv(game.mobNormalMat, 1, true, &ident[0])
-	gl.Uniform3f(game.offset, 0.0, 0.0, 0.0)
+	gl.ProgramUniformMatrix4fv(game.cubeProgram, game.normalMat, 1, true, &ident[0])
+	gl.ProgramUniform3f(game.cubeProgram, game.offset, 0.0, 0.0, 0.0)
+	for _, uniforms := range []mobUniforms{game.mob, game.skinned} {
+		gl.ProgramUniformMatrix4fv(uniforms.program, uniforms.normalMat, 1, true, &ident[0])
+		gl.ProgramUniform3f(uniforms.program, uniforms.offset, 0.0, 0.0, 0.0)
+	}
+	game.BindMaterial(Graphics.DefaultMaterial(0))
+
+	// The atlas stays on texture unit 0 and the texture array on unit 1
+	gl.ProgramUniform1i(game.cubeProgram, game.textureArray, 1)
+	gl.ProgramUniform1i(game.cubeProgram, game.layered, 0)
 }
 
 func (game *OpenGL45Game) BindFragData() {
 
 	gl.BindFragDataLocation(game.mobProgram, 0, gl.Str("outputColor\x00"))
+	gl.BindFragDataLocation(game.skinnedProgram, 0, gl.Str("outputColor\x00"))
 	gl.BindFragDataLocation(game.cubeProgram, 0, gl.Str("outputColor\x00"))
 }
 
-func (game *OpenGL45Game) BindProjection(WindowWidth float32, WindowHeight float32) {
+func (game *OpenGL45Game) BindProjection(projection mgl32.Mat4) {
 
 	gl.Enable(gl.DEPTH_TEST)
 	gl.DepthFunc(gl.LESS)
 
-	projection := mgl32.Perspective(70.0, WindowWidth/WindowHeight, 0.1, 100.0)
 	gl.BindBuffer(gl.UNIFORM_BUFFER, game.stateBufferStorageBlock)
 	gl.BufferSubData(gl.UNIFORM_BUFFER, 0, 4*len(projection), gl.Ptr(&projection[0]))
 }
@@ -123,12 +158,88 @@ func (game *OpenGL45Game) BindBuffers(bufferData ...[]float32) {
 
 	gl.BindBuffer(gl.ARRAY_BUFFER, game.uvBuffer)
 	gl.BufferData(gl.ARRAY_BUFFER, len(uv)*4, gl.Ptr(uv), gl.STATIC_DRAW)
+
+	gl.BindVertexArray(game.vao)
+	gl.DisableVertexAttribArray(4)
+	gl.DisableVertexAttribArray(5)
+}
+
+// BindSkin uploads four joint indices and weights per vertex for the
+// skinned program, until the next BindBuffers.
+func (game *OpenGL45Game) BindSkin(joints, weights []float32) {
+	gl.BindVertexArray(game.vao)
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, game.jointBuffer)
+	gl.BufferData(gl.ARRAY_BUFFER, len(joints)*4, gl.Ptr(joints), gl.STATIC_DRAW)
+	gl.EnableVertexAttribArray(4)
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, game.weightBuffer)
+	gl.BufferData(gl.ARRAY_BUFFER, len(weights)*4, gl.Ptr(weights), gl.STATIC_DRAW)
+	gl.EnableVertexAttribArray(5)
+}
+
+func (game *OpenGL45Game) BindJointMatrices(matrices []mgl32.Mat4) {
+	if len(matrices) == 0 {
+		return
+	}
+	gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, game.jointStorageBlock)
+	gl.BufferData(gl.SHADER_STORAGE_BUFFER, len(matrices)*16*4, gl.Ptr(&matrices[0][0]), gl.DYNAMIC_DRAW)
+}
+
+// BindIndices uploads the indices as 16 bit when every index fits, halving
+// the buffer for most models.
+func (game *OpenGL45Game) BindIndices(indices []uint32) {
+	gl.BindVertexArray(game.vao)
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, game.indexBuffer)
+
+	narrow := make([]uint16, len(indices))
+	for i, index := range indices {
+		if index > 0xFFFF {
+			narrow = nil
+			break
+		}
+		narrow[i] = uint16(index)
+	}
+	if narrow != nil && len(narrow) > 0 {
+		game.indexType = gl.UNSIGNED_SHORT
+		gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(narrow)*2, gl.Ptr(narrow), gl.STATIC_DRAW)
+	} else if len(indices) > 0 {
+		game.indexType = gl.UNSIGNED_INT
+		gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(indices)*4, gl.Ptr(indices), gl.STATIC_DRAW)
+	}
 }
 
 func (game *OpenGL45Game) BindUniforms(parameters ...[]float32) {
-	gl.Uniform1f(game.scale, parameters[0][0])
-	gl.Uniform1f(game.length, parameters[1][0])
-	gl.Uniform3f(game.offset, parameters[2][0], parameters[2][1], parameters[2][2])
+	gl.ProgramUniform1f(game.cubeProgram, game.length, parameters[1][0])
+	gl.ProgramUniform3f(game.cubeProgram, game.offset, parameters[2][0], parameters[2][1], parameters[2][2])
+	for _, uniforms := range []mobUniforms{game.mob, game.skinned} {
+		gl.ProgramUniform1f(uniforms.program, uniforms.scale, parameters[0][0])
+		gl.ProgramUniform3f(uniforms.program, uniforms.offset, parameters[2][0], parameters[2][1], parameters[2][2])
+	}
+}
+
+// BindMaterial sets the mob program material and binds its texture.
+// Translucent materials are blended over what is already drawn.
+func (game *OpenGL45Game) BindMaterial(material Graphics.Material) {
+	textured := int32(0)
+	if material.Texture != 0 {
+		textured = 1
+		gl.ActiveTexture(gl.TEXTURE0)
+		gl.BindTexture(gl.TEXTURE_2D, material.Texture)
+	}
+	for _, uniforms := range []mobUniforms{game.mob, game.skinned} {
+		gl.ProgramUniform3f(uniforms.program, uniforms.ambient, material.Ambient[0], material.Ambient[1], material.Ambient[2])
+		gl.ProgramUniform4f(uniforms.program, uniforms.diffuse, material.Diffuse[0], material.Diffuse[1], material.Diffuse[2], material.Opacity)
+		gl.ProgramUniform3f(uniforms.program, uniforms.specular, material.Specular[0], material.Specular[1], material.Specular[2])
+		gl.ProgramUniform1f(uniforms.program, uniforms.shininess, material.Shininess)
+		gl.ProgramUniform1i(uniforms.program, uniforms.textured, textured)
+	}
+	if material.Opacity < 1 {
+		gl.Enable(gl.BLEND)
+		gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+	} else {
+		gl.Disable(gl.BLEND)
+	}
 }
 
 func (game *OpenGL45Game) BindTexture(texture uint32) {
@@ -136,22 +247,65 @@ func (game *OpenGL45Game) BindTexture(texture uint32) {
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
 	gl.BindTexture(gl.TEXTURE_2D, texture)
+	gl.ProgramUniform1i(game.cubeProgram, game.layered, 0)
+}
+
+// BindTextureArray switches the cube program to sampling texture array
+// layers, taking the layer from the texture data buffer and the coordinates
+// from the uv buffer.
+func (game *OpenGL45Game) BindTextureArray(texture uint32) {
+	gl.ActiveTexture(gl.TEXTURE1)
+	gl.BindTexture(gl.TEXTURE_2D_ARRAY, texture)
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.ProgramUniform1i(game.cubeProgram, game.layered, 1)
 }
 
 func (game *OpenGL45Game) RenderInstances(instances []float32, bufferSize int32) {
-	gl.UseProgram(game.cubeProgram)
+	game.RenderInstanceRange(instances, 0, bufferSize)
+}
+
+func (game *OpenGL45Game) RenderInstanceRange(instances []float32, first, count int32) {
+	gl.UseProgram(game.program)
+
+	gl.BindVertexArray(game.vao)
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, game.typeBuffer)
+	gl.BufferData(gl.ARRAY_BUFFER, len(instances)*4, gl.Ptr(instances), gl.STATIC_DRAW)
+	gl.DrawArraysInstanced(gl.TRIANGLES, first, count, int32(len(instances)/4))
+}
+
+func (game *OpenGL45Game) RenderIndexedInstances(instances []float32, first, count int32) {
+	gl.UseProgram(game.program)
 
 	gl.BindVertexArray(game.vao)
 
 	gl.BindBuffer(gl.ARRAY_BUFFER, game.typeBuffer)
 	gl.BufferData(gl.ARRAY_BUFFER, len(instances)*4, gl.Ptr(instances), gl.STATIC_DRAW)
-	gl.DrawArraysInstanced(gl.TRIANGLES, 0, bufferSize, int32(len(instances)/4))
+
+	indexSize := 4
+	if game.indexType == gl.UNSIGNED_SHORT {
+		indexSize = 2
+	}
+	gl.DrawElementsInstanced(gl.TRIANGLES, count, game.indexType, gl.PtrOffset(int(first)*indexSize), int32(len(instances)/4))
+}
+
+func (game *OpenGL45Game) SelectProgram(program int) {
+	switch program {
+	case Graphics.MobProgram:
+		game.program = game.mobProgram
+	case Graphics.SkinnedProgram:
+		game.program = game.skinnedProgram
+	default:
+		game.program = game.cubeProgram
+	}
 }
 
 func (game *OpenGL45Game) StartPrograms() {
 	// Configure the vertex and fragment shaders
 	game.cubeProgram = game.Control.NewProgram("cubeShader.shad", "cubeFrag.frag")
 	game.mobProgram = game.Control.NewProgram("mobShader.shad", "mobFragment.frag")
+	game.skinnedProgram = game.Control.NewProgram("skinnedShader.shad", "mobFragment.frag")
+	game.program = game.cubeProgram
 }
 
 func (game *OpenGL45Game) UpdateProjection(states mgl32.Mat4) {
@@ -0,0 +1,133 @@
+package Headless
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/allanks/Voxel-Engine/src/Graphics/Software"
+)
+
+// HeadlessControl implements Graphics.OpenGLControl without a GL context.
+// Every call is recorded so rendering code can be asserted in go test. When
+// a Rasterizer is set, textures are decoded and Clear clears its image.
+type HeadlessControl struct {
+	Programs    []Program
+	Textures    []string
+	MipLevels   []int
+	Layers      []int
+	Clears      int
+	DepthWrite  bool
+	Initialized bool
+	Rasterizer  *Software.Rasterizer
+	onscreen    image.Rectangle
+}
+
+type Program struct {
+	ID                     uint32
+	VertexShader, Fragment string
+}
+
+func (control *HeadlessControl) Init() {
+	control.Initialized = true
+	control.DepthWrite = true
+}
+
+func (control *HeadlessControl) Clear() {
+	control.Clears++
+	if control.Rasterizer != nil {
+		control.Rasterizer.Clear()
+	}
+}
+
+func (control *HeadlessControl) DepthToggle(toggle bool) {
+	control.DepthWrite = toggle
+}
+
+func (control *HeadlessControl) NewProgram(vertexShaderSource, fragmentShaderSource string) uint32 {
+	program := Program{uint32(len(control.Programs) + 1), vertexShaderSource, fragmentShaderSource}
+	control.Programs = append(control.Programs, program)
+	return program.ID
+}
+
+// CreateTexture returns texture ids starting at 1, the id is the position of
+// the file in Textures plus one.
+func (control *HeadlessControl) CreateTexture(file string) uint32 {
+	if control.Rasterizer != nil {
+		if _, err := control.Rasterizer.LoadTexture(file); err != nil {
+			panic(err)
+		}
+	}
+	control.Textures = append(control.Textures, file)
+	control.MipLevels = append(control.MipLevels, 1)
+	control.Layers = append(control.Layers, 0)
+	return uint32(len(control.Textures))
+}
+
+// CreateImageTexture records the texture without a file, so TextureFile
+// returns "" for it and draws using it are rasterized untextured.
+func (control *HeadlessControl) CreateImageTexture(rgba *image.RGBA) uint32 {
+	control.Textures = append(control.Textures, "")
+	control.MipLevels = append(control.MipLevels, 1)
+	control.Layers = append(control.Layers, 0)
+	return uint32(len(control.Textures))
+}
+
+// CreateMipmappedTexture records the base level file; the rasterizer only
+// samples the base level.
+func (control *HeadlessControl) CreateMipmappedTexture(files []string) uint32 {
+	texture := control.CreateTexture(files[0])
+	control.MipLevels[texture-1] = len(files)
+	return texture
+}
+
+// CreateTextureArray records the stacked layer image along with its layer
+// count, which the rasterizer needs to split it back into layers.
+func (control *HeadlessControl) CreateTextureArray(file string, layers int32) uint32 {
+	texture := control.CreateTexture(file)
+	control.Layers[texture-1] = int(layers)
+	return texture
+}
+
+// ReadFramebuffer returns a copy of the rasterized image, or a blank image
+// when no Rasterizer is set. The size has to match the rasterized image.
+func (control *HeadlessControl) ReadFramebuffer(width, height int32) (*image.RGBA, error) {
+	img := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+	if control.Rasterizer == nil {
+		return img, nil
+	}
+	bounds := control.Rasterizer.Image.Rect
+	if bounds.Dx() != int(width) || bounds.Dy() != int(height) {
+		return nil, fmt.Errorf("framebuffer is %vx%v, cannot read %vx%v", bounds.Dx(), bounds.Dy(), width, height)
+	}
+	copy(img.Pix, control.Rasterizer.Image.Pix)
+	return img, nil
+}
+
+func (control *HeadlessControl) BeginOffscreen(width, height int32) {
+	if control.Rasterizer != nil {
+		control.onscreen = control.Rasterizer.Image.Rect
+		control.Rasterizer.Resize(int(width), int(height))
+	}
+}
+
+func (control *HeadlessControl) EndOffscreen() {
+	if control.Rasterizer != nil {
+		control.Rasterizer.Resize(control.onscreen.Dx(), control.onscreen.Dy())
+	}
+}
+
+// TextureLayers returns the layer count of a texture array, or 0 for plain
+// textures.
+func (control *HeadlessControl) TextureLayers(texture uint32) int {
+	if texture == 0 || int(texture) > len(control.Layers) {
+		return 0
+	}
+	return control.Layers[texture-1]
+}
+
+func (control *HeadlessControl) TextureFile(texture uint32) string {
+	if texture == 0 || int(texture) > len(control.Textures) {
+		return ""
+	}
+	return control.Textures[texture-1]
+}
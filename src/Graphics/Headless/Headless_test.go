@@ -0,0 +1,44 @@
+package Headless
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/allanks/Voxel-Engine/src/Graphics/Software"
+)
+
+func TestReadFramebuffer(t *testing.T) {
+	control := &HeadlessControl{Rasterizer: Software.CreateRasterizer(4, 2)}
+	control.Rasterizer.ClearColor = color.RGBA{10, 20, 30, 255}
+	control.Clear()
+
+	img, err := control.ReadFramebuffer(4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.RGBAAt(3, 1) != control.Rasterizer.ClearColor {
+		t.Errorf("read %v, want the clear colour", img.RGBAAt(3, 1))
+	}
+	img.SetRGBA(0, 0, color.RGBA{})
+	if control.Rasterizer.Image.RGBAAt(0, 0) != control.Rasterizer.ClearColor {
+		t.Error("the image read aliases the rasterized image")
+	}
+
+	for _, size := range [][2]int32{{2, 2}, {4, 4}, {8, 1}} {
+		if _, err = control.ReadFramebuffer(size[0], size[1]); err == nil {
+			t.Errorf("reading %vx%v from a 4x2 framebuffer did not fail", size[0], size[1])
+		}
+	}
+}
+
+func TestReadOffscreenFramebuffer(t *testing.T) {
+	control := &HeadlessControl{Rasterizer: Software.CreateRasterizer(4, 2)}
+	control.BeginOffscreen(8, 4)
+	if _, err := control.ReadFramebuffer(8, 4); err != nil {
+		t.Error(err)
+	}
+	control.EndOffscreen()
+	if _, err := control.ReadFramebuffer(4, 2); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,23 @@
+package Graphics
+
+import "github.com/go-gl/mathgl/mgl32"
+
+const (
+	// Programs a ProgramSelector can switch between
+	CubeProgram = iota
+	MobProgram
+	SkinnedProgram
+)
+
+// Material is the surface the mob program shades the following draws with.
+// A zero Texture draws the diffuse colour alone.
+type Material struct {
+	Ambient, Diffuse, Specular mgl32.Vec3
+	Shininess, Opacity         float32
+	Texture                    uint32
+}
+
+// DefaultMaterial is plain white, for models without materials.
+func DefaultMaterial(texture uint32) Material {
+	return Material{Diffuse: mgl32.Vec3{1, 1, 1}, Opacity: 1, Texture: texture}
+}
@@ -12,7 +12,10 @@ import (
 	"github.com/go-gl/glow/gl-core/4.5/gl"
 )
 
-type OpenGLControl struct{}
+type OpenGLControl struct {
+	framebuffer, colorBuffer, depthBuffer uint32
+	viewport                              [4]int32
+}
 
 const LineEnding string = "\x00"
 
@@ -73,21 +76,12 @@ func (control *OpenGLControl) NewProgram(vertexShaderSource, fragmentShaderSourc
 }
 
 func (control *OpenGLControl) CreateTexture(file string) uint32 {
-	imgFile, err := os.Open(file)
-	if err != nil {
-		panic(err)
-	}
-	img, _, err := image.Decode(imgFile)
-	if err != nil {
-		panic(err)
-	}
-
-	rgba := image.NewRGBA(img.Bounds())
-	if rgba.Stride != rgba.Rect.Size().X*4 {
-		panic(err)
-	}
-	draw.Draw(rgba, rgba.Bounds(), img, image.Point{0, 0}, draw.Src)
+	return control.CreateImageTexture(loadRGBA(file))
+}
 
+// CreateImageTexture uploads an image that is already in memory, such as one
+// embedded in a model file.
+func (control *OpenGLControl) CreateImageTexture(rgba *image.RGBA) uint32 {
 	var texture uint32
 	gl.GenTextures(1, &texture)
 	gl.ActiveTexture(gl.TEXTURE0)
@@ -110,6 +104,142 @@ func (control *OpenGLControl) CreateTexture(file string) uint32 {
 	return texture
 }
 
+// CreateMipmappedTexture uploads a base image followed by its precomputed
+// mip levels, each half the size of the one before, and samples them with
+// trilinear filtering.
+func (control *OpenGLControl) CreateMipmappedTexture(files []string) uint32 {
+	var texture uint32
+	gl.GenTextures(1, &texture)
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, texture)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_BASE_LEVEL, 0)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAX_LEVEL, int32(len(files)-1))
+
+	for level, file := range files {
+		rgba := loadRGBA(file)
+		gl.TexImage2D(
+			gl.TEXTURE_2D,
+			int32(level),
+			gl.RGBA,
+			int32(rgba.Rect.Size().X),
+			int32(rgba.Rect.Size().Y),
+			0,
+			gl.RGBA,
+			gl.UNSIGNED_BYTE,
+			gl.Ptr(rgba.Pix))
+	}
+
+	return texture
+}
+
+// CreateTextureArray uploads an image holding layers stacked top to bottom
+// as a GL_TEXTURE_2D_ARRAY. Layers repeat so merged faces can tile them.
+func (control *OpenGLControl) CreateTextureArray(file string, layers int32) uint32 {
+	rgba := loadRGBA(file)
+	width := int32(rgba.Rect.Size().X)
+	height := int32(rgba.Rect.Size().Y) / layers
+
+	var texture uint32
+	gl.GenTextures(1, &texture)
+	gl.ActiveTexture(gl.TEXTURE1)
+	gl.BindTexture(gl.TEXTURE_2D_ARRAY, texture)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_WRAP_S, gl.REPEAT)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_WRAP_T, gl.REPEAT)
+	gl.TexImage3D(
+		gl.TEXTURE_2D_ARRAY,
+		0,
+		gl.RGBA,
+		width,
+		height,
+		layers,
+		0,
+		gl.RGBA,
+		gl.UNSIGNED_BYTE,
+		gl.Ptr(rgba.Pix))
+	gl.GenerateMipmap(gl.TEXTURE_2D_ARRAY)
+	gl.ActiveTexture(gl.TEXTURE0)
+
+	return texture
+}
+
+func loadRGBA(file string) *image.RGBA {
+	imgFile, err := os.Open(file)
+	if err != nil {
+		panic(err)
+	}
+	defer imgFile.Close()
+	img, _, err := image.Decode(imgFile)
+	if err != nil {
+		panic(err)
+	}
+
+	rgba := image.NewRGBA(img.Bounds())
+	if rgba.Stride != rgba.Rect.Size().X*4 {
+		panic(err)
+	}
+	draw.Draw(rgba, rgba.Bounds(), img, image.Point{0, 0}, draw.Src)
+	return rgba
+}
+
+// ReadFramebuffer reads back the currently bound framebuffer. GL stores rows
+// bottom up so they are flipped to match image.RGBA. The size has to match
+// the viewport, which is the size of the bound framebuffer.
+func (control *OpenGLControl) ReadFramebuffer(width, height int32) (*image.RGBA, error) {
+	var viewport [4]int32
+	gl.GetIntegerv(gl.VIEWPORT, &viewport[0])
+	if viewport[2] != width || viewport[3] != height {
+		return nil, fmt.Errorf("framebuffer is %vx%v, cannot read %vx%v", viewport[2], viewport[3], width, height)
+	}
+	pixels := make([]uint8, width*height*4)
+	gl.PixelStorei(gl.PACK_ALIGNMENT, 1)
+	gl.ReadPixels(0, 0, width, height, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(pixels))
+
+	img := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+	stride := int(width) * 4
+	for y := 0; y < int(height); y++ {
+		copy(img.Pix[y*img.Stride:(y*img.Stride)+stride], pixels[(int(height)-1-y)*stride:])
+	}
+	return img, nil
+}
+
+// BeginOffscreen redirects rendering into a framebuffer of the given size
+// until EndOffscreen is called.
+func (control *OpenGLControl) BeginOffscreen(width, height int32) {
+	gl.GetIntegerv(gl.VIEWPORT, &control.viewport[0])
+
+	gl.GenFramebuffers(1, &control.framebuffer)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, control.framebuffer)
+
+	gl.GenRenderbuffers(1, &control.colorBuffer)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, control.colorBuffer)
+	gl.RenderbufferStorage(gl.RENDERBUFFER, gl.RGBA8, width, height)
+	gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.RENDERBUFFER, control.colorBuffer)
+
+	gl.GenRenderbuffers(1, &control.depthBuffer)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, control.depthBuffer)
+	gl.RenderbufferStorage(gl.RENDERBUFFER, gl.DEPTH_COMPONENT24, width, height)
+	gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.RENDERBUFFER, control.depthBuffer)
+
+	if status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER); status != gl.FRAMEBUFFER_COMPLETE {
+		panic(fmt.Errorf("offscreen framebuffer incomplete: 0x%x", status))
+	}
+	gl.Viewport(0, 0, width, height)
+}
+
+func (control *OpenGLControl) EndOffscreen() {
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+	gl.DeleteRenderbuffers(1, &control.colorBuffer)
+	gl.DeleteRenderbuffers(1, &control.depthBuffer)
+	gl.DeleteFramebuffers(1, &control.framebuffer)
+	gl.Viewport(control.viewport[0], control.viewport[1], control.viewport[2], control.viewport[3])
+}
+
 func compileShader(source string, shaderType uint32) (uint32, error) {
 	shader := gl.CreateShader(shaderType)
 
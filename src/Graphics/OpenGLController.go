@@ -1,6 +1,10 @@
 package Graphics
 
-import "github.com/go-gl/mathgl/mgl32"
+import (
+	"image"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
 
 type BufferBinder interface {
 	BindBuffers(...[]float32)
@@ -14,10 +18,46 @@ type TextureBinder interface {
 	BindTexture(uint32)
 }
 
+type TextureArrayBinder interface {
+	BindTextureArray(uint32)
+}
+
+// IndexBinder uploads the index buffer used by RenderIndexedInstances.
+type IndexBinder interface {
+	BindIndices([]uint32)
+}
+
+type MaterialBinder interface {
+	BindMaterial(Material)
+}
+
+// SkinBinder uploads the joints and weights of a skinned model, four per
+// vertex, and the matrices of its joints for the skinned program.
+type SkinBinder interface {
+	BindSkin([]float32, []float32)
+	BindJointMatrices([]mgl32.Mat4)
+}
+
 type InstanceRenderer interface {
 	RenderInstances([]float32, int32)
 }
 
+// RangeRenderer draws the vertices from first to first+count of the bound
+// buffers for every instance.
+type RangeRenderer interface {
+	RenderInstanceRange([]float32, int32, int32)
+}
+
+// IndexedRenderer draws count indices starting at first of the bound index
+// buffer for every instance.
+type IndexedRenderer interface {
+	RenderIndexedInstances([]float32, int32, int32)
+}
+
+type ProgramSelector interface {
+	SelectProgram(int)
+}
+
 type BufferCreator interface {
 	CreateBuffers()
 }
@@ -35,7 +75,7 @@ type ProgramStarter interface {
 }
 
 type ProjectionInitializer interface {
-	BindProjection(float32, float32)
+	BindProjection(mgl32.Mat4)
 }
 
 type ProjectionUpdater interface {
@@ -52,9 +92,16 @@ type OpenGLController interface {
 	UniformCreator
 	FragmentBinder
 	BufferBinder
+	IndexBinder
 	UniformBinder
 	TextureBinder
+	TextureArrayBinder
+	MaterialBinder
+	SkinBinder
 	InstanceRenderer
+	RangeRenderer
+	IndexedRenderer
+	ProgramSelector
 	ProgramStarter
 	ProjectionController
 }
@@ -69,6 +116,9 @@ type ProgramCreator interface {
 
 type TextureCreator interface {
 	CreateTexture(string) uint32
+	CreateImageTexture(*image.RGBA) uint32
+	CreateMipmappedTexture([]string) uint32
+	CreateTextureArray(string, int32) uint32
 }
 
 type OpenGLDepthToggle interface {
@@ -79,12 +129,23 @@ type OpenGLClear interface {
 	Clear()
 }
 
+type FramebufferReader interface {
+	ReadFramebuffer(int32, int32) (*image.RGBA, error)
+}
+
+type OffscreenRenderer interface {
+	BeginOffscreen(int32, int32)
+	EndOffscreen()
+}
+
 type OpenGLControl interface {
 	OpenGLInitializer
 	ProgramCreator
 	TextureCreator
 	OpenGLDepthToggle
 	OpenGLClear
+	FramebufferReader
+	OffscreenRenderer
 }
 
 type OpenGLControlCreator interface {
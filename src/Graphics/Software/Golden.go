@@ -0,0 +1,132 @@
+package Software
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"strings"
+)
+
+// UpdateGolden makes MatchGolden overwrite the golden file instead of
+// comparing against it. Tests usually wire it to a command line flag.
+var UpdateGolden bool
+
+// Tolerance describes how far a rendering may drift from its golden image.
+// Channel is the largest per channel difference a pixel may have before it
+// counts as mismatched, Pixels is the fraction of pixels allowed to mismatch.
+type Tolerance struct {
+	Channel uint8
+	Pixels  float64
+}
+
+type ImageDiff struct {
+	Mismatched, Total int
+	MaxDelta          uint8
+	Diff              *image.RGBA
+}
+
+func (diff ImageDiff) Within(tolerance Tolerance) bool {
+	return float64(diff.Mismatched) <= tolerance.Pixels*float64(diff.Total)
+}
+
+// CompareImages compares two images of the same size pixel by pixel. The
+// returned Diff image marks mismatched pixels in red over a dimmed copy of
+// the expected image.
+func CompareImages(got, want image.Image, tolerance Tolerance) (ImageDiff, error) {
+	gotBounds, wantBounds := got.Bounds(), want.Bounds()
+	if gotBounds.Dx() != wantBounds.Dx() || gotBounds.Dy() != wantBounds.Dy() {
+		return ImageDiff{}, fmt.Errorf("image size %vx%v does not match golden %vx%v",
+			gotBounds.Dx(), gotBounds.Dy(), wantBounds.Dx(), wantBounds.Dy())
+	}
+
+	diff := ImageDiff{Total: wantBounds.Dx() * wantBounds.Dy(), Diff: image.NewRGBA(image.Rect(0, 0, wantBounds.Dx(), wantBounds.Dy()))}
+	for y := 0; y < wantBounds.Dy(); y++ {
+		for x := 0; x < wantBounds.Dx(); x++ {
+			a := color.RGBAModel.Convert(got.At(gotBounds.Min.X+x, gotBounds.Min.Y+y)).(color.RGBA)
+			b := color.RGBAModel.Convert(want.At(wantBounds.Min.X+x, wantBounds.Min.Y+y)).(color.RGBA)
+			delta := maxDelta(a, b)
+			if delta > diff.MaxDelta {
+				diff.MaxDelta = delta
+			}
+			if delta > tolerance.Channel {
+				diff.Mismatched++
+				diff.Diff.SetRGBA(x, y, color.RGBA{255, 0, 0, 255})
+			} else {
+				diff.Diff.SetRGBA(x, y, color.RGBA{b.R / 3, b.G / 3, b.B / 3, 255})
+			}
+		}
+	}
+	return diff, nil
+}
+
+// MatchGolden compares an image against the golden PNG. On a mismatch the
+// rendered image and the diff are written next to the golden file with
+// .actual.png and .diff.png suffixes so they can be inspected.
+func MatchGolden(got image.Image, goldenFile string, tolerance Tolerance) error {
+	if UpdateGolden {
+		return WritePNG(goldenFile, got)
+	}
+
+	want, err := LoadPNG(goldenFile)
+	if err != nil {
+		return err
+	}
+	diff, err := CompareImages(got, want, tolerance)
+	if err != nil {
+		return err
+	}
+	if diff.Within(tolerance) {
+		return nil
+	}
+
+	base := strings.TrimSuffix(goldenFile, ".png")
+	if err = WritePNG(base+".actual.png", got); err != nil {
+		return err
+	}
+	if err = WritePNG(base+".diff.png", diff.Diff); err != nil {
+		return err
+	}
+	return fmt.Errorf("%v of %v pixels differ from %v (max channel delta %v)",
+		diff.Mismatched, diff.Total, goldenFile, diff.MaxDelta)
+}
+
+func LoadPNG(file string) (image.Image, error) {
+	pngFile, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer pngFile.Close()
+	return png.Decode(pngFile)
+}
+
+func WritePNG(file string, img image.Image) error {
+	pngFile, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err = png.Encode(pngFile, img); err != nil {
+		pngFile.Close()
+		return err
+	}
+	return pngFile.Close()
+}
+
+func (raster *Rasterizer) WritePNG(file string) error {
+	return WritePNG(file, raster.Image)
+}
+
+func maxDelta(a, b color.RGBA) uint8 {
+	delta := uint8(0)
+	for _, pair := range [][2]uint8{{a.R, b.R}, {a.G, b.G}, {a.B, b.B}, {a.A, b.A}} {
+		d := pair[0] - pair[1]
+		if pair[1] > pair[0] {
+			d = pair[1] - pair[0]
+		}
+		if d > delta {
+			delta = d
+		}
+	}
+	return delta
+}
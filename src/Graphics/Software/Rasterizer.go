@@ -0,0 +1,317 @@
+package Software
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	m "math"
+
+	"github.com/allanks/Voxel-Engine/src/Graphics"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// nearClip keeps vertices just in front of the eye so the perspective divide
+// never sees a zero or negative w.
+const nearClip float32 = 1e-5
+
+// Rasterizer draws the same data the cube program receives into an RGBA
+// image on the CPU. It follows cubeShader.shad and cubeFrag.frag: texture
+// coordinates come from the texture data buffer indexed by vertex id and
+// instance type, and the colour is lit by the shared sun. Layered draws take
+// the array layer from the texture data buffer and the coordinates from UV.
+type Rasterizer struct {
+	Image      *image.RGBA
+	ClearColor color.RGBA
+	depth      []float32
+	textures   map[string]*image.RGBA
+	sun        [9]float32
+}
+
+// Draw is a single instanced draw, laid out the way the controller receives
+// it through BindBuffers, BindUniforms and RenderInstances. When Indices is
+// set, First and VertexCount count indices as in RenderIndexedInstances.
+type Draw struct {
+	Vertices, Normals, TextureData, UV []float32
+	Indices                            []uint32
+	Instances                          []float32
+	Offset                             [3]float32
+	Length                             float32
+	First, VertexCount                 int32
+	Texture                            *image.RGBA
+	Layers                             int
+	Projection, Camera                 mgl32.Mat4
+	DepthWrite                         bool
+}
+
+type vertex struct {
+	clip  mgl32.Vec4
+	uv    mgl32.Vec2
+	layer float32
+	light float32
+}
+
+func CreateRasterizer(width, height int) *Rasterizer {
+	raster := &Rasterizer{
+		Image:      image.NewRGBA(image.Rect(0, 0, width, height)),
+		ClearColor: color.RGBA{0, 0, 0, 255},
+		depth:      make([]float32, width*height),
+		textures:   map[string]*image.RGBA{},
+		sun:        Graphics.SunBlock(),
+	}
+	raster.Clear()
+	return raster
+}
+
+// Resize replaces the image and depth buffer with cleared ones of the new size.
+func (raster *Rasterizer) Resize(width, height int) {
+	raster.Image = image.NewRGBA(image.Rect(0, 0, width, height))
+	raster.depth = make([]float32, width*height)
+	raster.Clear()
+}
+
+func (raster *Rasterizer) Clear() {
+	draw.Draw(raster.Image, raster.Image.Bounds(), &image.Uniform{raster.ClearColor}, image.ZP, draw.Src)
+	for i := range raster.depth {
+		raster.depth[i] = 1
+	}
+}
+
+// LoadTexture decodes a texture file once and keeps it for later draws.
+func (raster *Rasterizer) LoadTexture(file string) (*image.RGBA, error) {
+	if texture, ok := raster.textures[file]; ok {
+		return texture, nil
+	}
+	img, err := LoadPNG(file)
+	if err != nil {
+		return nil, err
+	}
+	rgba := image.NewRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	raster.textures[file] = rgba
+	return rgba, nil
+}
+
+func (raster *Rasterizer) Render(call Draw) {
+	transform := call.Projection.Mul4(call.Camera)
+	triangles := int(call.VertexCount) / 3
+	for i := 0; i+3 < len(call.Instances); i += 4 {
+		object := mgl32.Vec3{
+			call.Instances[i] + call.Offset[0],
+			call.Instances[i+1] + call.Offset[1],
+			call.Instances[i+2] + call.Offset[2]}
+		base := int(call.Instances[i+3] * call.Length)
+		for t := 0; t < triangles; t++ {
+			var triangle [3]vertex
+			for k := range triangle {
+				id := int(call.First) + (t * 3) + k
+				if call.Indices != nil {
+					id = int(call.Indices[id])
+				}
+				triangle[k] = raster.shadeVertex(call, transform, object, id, base+id)
+			}
+			raster.drawTriangle(call, triangle)
+		}
+	}
+}
+
+func (raster *Rasterizer) shadeVertex(call Draw, transform mgl32.Mat4, object mgl32.Vec3, id, textureIndex int) vertex {
+	position := mgl32.Vec3{call.Vertices[id*3], call.Vertices[(id*3)+1], call.Vertices[(id*3)+2]}.Add(object)
+	result := vertex{clip: transform.Mul4x1(position.Vec4(1))}
+
+	if call.Layers > 0 {
+		if (id*2)+1 < len(call.UV) {
+			result.uv = mgl32.Vec2{call.UV[id*2], call.UV[(id*2)+1]}
+		}
+		if textureIndex*2 < len(call.TextureData) {
+			result.layer = call.TextureData[textureIndex*2]
+		}
+	} else if (textureIndex*2)+1 < len(call.TextureData) {
+		result.uv = mgl32.Vec2{call.TextureData[textureIndex*2], call.TextureData[(textureIndex*2)+1]}
+	}
+
+	normal := mgl32.Vec3{call.Normals[id*3], call.Normals[(id*3)+1], call.Normals[(id*3)+2]}
+	direction := mgl32.Vec3{raster.sun[4], raster.sun[5], raster.sun[6]}
+	diffuse := float32(0)
+	if normal.Len() > 0 {
+		diffuse = float32(m.Max(0, float64(normal.Normalize().Dot(direction.Mul(-1)))))
+	}
+	result.light = raster.sun[8] + diffuse
+	return result
+}
+
+func (raster *Rasterizer) drawTriangle(call Draw, triangle [3]vertex) {
+	polygon := clipNear(triangle[:])
+	for i := 1; i+1 < len(polygon); i++ {
+		raster.fillTriangle(call, polygon[0], polygon[i], polygon[i+1])
+	}
+}
+
+// clipNear cuts the polygon against the near plane (z >= -w) in clip space.
+func clipNear(polygon []vertex) []vertex {
+	clipped := []vertex{}
+	for i, current := range polygon {
+		next := polygon[(i+1)%len(polygon)]
+		currentDistance := current.clip.Z() + current.clip.W() - nearClip
+		nextDistance := next.clip.Z() + next.clip.W() - nearClip
+		if currentDistance >= 0 {
+			clipped = append(clipped, current)
+		}
+		if (currentDistance >= 0) != (nextDistance >= 0) {
+			t := currentDistance / (currentDistance - nextDistance)
+			clipped = append(clipped, vertex{
+				clip:  current.clip.Add(next.clip.Sub(current.clip).Mul(t)),
+				uv:    current.uv.Add(next.uv.Sub(current.uv).Mul(t)),
+				layer: current.layer,
+				light: current.light + ((next.light - current.light) * t),
+			})
+		}
+	}
+	return clipped
+}
+
+func (raster *Rasterizer) fillTriangle(call Draw, a, b, c vertex) {
+	width, height := raster.Image.Rect.Dx(), raster.Image.Rect.Dy()
+
+	toScreen := func(v vertex) (float32, float32, float32, float32) {
+		invW := 1 / v.clip.W()
+		x := ((v.clip.X() * invW) + 1) * 0.5 * float32(width)
+		y := (1 - (v.clip.Y() * invW)) * 0.5 * float32(height)
+		z := ((v.clip.Z() * invW) * 0.5) + 0.5
+		return x, y, z, invW
+	}
+	ax, ay, az, aw := toScreen(a)
+	bx, by, bz, bw := toScreen(b)
+	cx, cy, cz, cw := toScreen(c)
+
+	area := edge(ax, ay, bx, by, cx, cy)
+	if area == 0 {
+		return
+	}
+
+	minX := clampInt(int(m.Floor(float64(min3(ax, bx, cx)))), 0, width-1)
+	maxX := clampInt(int(m.Ceil(float64(max3(ax, bx, cx)))), 0, width-1)
+	minY := clampInt(int(m.Floor(float64(min3(ay, by, cy)))), 0, height-1)
+	maxY := clampInt(int(m.Ceil(float64(max3(ay, by, cy)))), 0, height-1)
+
+	for y := minY; y <= maxY; y++ {
+		for x := minX; x <= maxX; x++ {
+			px, py := float32(x)+0.5, float32(y)+0.5
+			w0 := edge(bx, by, cx, cy, px, py) / area
+			w1 := edge(cx, cy, ax, ay, px, py) / area
+			w2 := edge(ax, ay, bx, by, px, py) / area
+			if w0 < 0 || w1 < 0 || w2 < 0 {
+				continue
+			}
+
+			depth := (w0 * az) + (w1 * bz) + (w2 * cz)
+			index := (y * width) + x
+			if depth < 0 || depth > 1 || depth >= raster.depth[index] {
+				continue
+			}
+
+			// Perspective correct interpolation of the varyings
+			invW := (w0 * aw) + (w1 * bw) + (w2 * cw)
+			pa, pb, pc := (w0*aw)/invW, (w1*bw)/invW, (w2*cw)/invW
+			uv := a.uv.Mul(pa).Add(b.uv.Mul(pb)).Add(c.uv.Mul(pc))
+			light := (a.light * pa) + (b.light * pb) + (c.light * pc)
+
+			texel := color.RGBA{255, 255, 255, 255}
+			if call.Texture != nil && call.Layers > 0 {
+				texel = sampleLayer(call.Texture, call.Layers, a.layer, uv)
+			} else if call.Texture != nil {
+				texel = sampleLinear(call.Texture, uv, false)
+			}
+			raster.Image.SetRGBA(x+raster.Image.Rect.Min.X, y+raster.Image.Rect.Min.Y, color.RGBA{
+				lit(texel.R, raster.sun[0]*light),
+				lit(texel.G, raster.sun[1]*light),
+				lit(texel.B, raster.sun[2]*light),
+				texel.A,
+			})
+			if call.DepthWrite {
+				raster.depth[index] = depth
+			}
+		}
+	}
+}
+
+// sampleLayer samples one layer of a texture array stored as layers stacked
+// top to bottom, repeating the coordinates like GL_REPEAT.
+func sampleLayer(texture *image.RGBA, layers int, layer float32, uv mgl32.Vec2) color.RGBA {
+	index := clampInt(int(layer+0.5), 0, layers-1)
+	height := texture.Rect.Dy() / layers
+	origin := texture.Rect.Min.Add(image.Pt(0, index*height))
+	return sampleLinear(texture.SubImage(image.Rect(origin.X, origin.Y, texture.Rect.Max.X, origin.Y+height)).(*image.RGBA), uv, true)
+}
+
+// sampleLinear mirrors GL_LINEAR filtering with GL_CLAMP_TO_EDGE wrapping,
+// or GL_REPEAT when repeat is set.
+func sampleLinear(texture *image.RGBA, uv mgl32.Vec2, repeat bool) color.RGBA {
+	bounds := texture.Rect
+	x := (uv.X() * float32(bounds.Dx())) - 0.5
+	y := (uv.Y() * float32(bounds.Dy())) - 0.5
+	x0, y0 := int(m.Floor(float64(x))), int(m.Floor(float64(y)))
+	fx, fy := x-float32(x0), y-float32(y0)
+
+	texel := func(tx, ty int) color.RGBA {
+		if repeat {
+			tx = wrapInt(tx, bounds.Dx()) + bounds.Min.X
+			ty = wrapInt(ty, bounds.Dy()) + bounds.Min.Y
+		} else {
+			tx = clampInt(tx, 0, bounds.Dx()-1) + bounds.Min.X
+			ty = clampInt(ty, 0, bounds.Dy()-1) + bounds.Min.Y
+		}
+		return texture.RGBAAt(tx, ty)
+	}
+	c00, c10 := texel(x0, y0), texel(x0+1, y0)
+	c01, c11 := texel(x0, y0+1), texel(x0+1, y0+1)
+
+	mix := func(a, b, c, d uint8) uint8 {
+		top := (float32(a) * (1 - fx)) + (float32(b) * fx)
+		bottom := (float32(c) * (1 - fx)) + (float32(d) * fx)
+		return uint8((top * (1 - fy)) + (bottom * fy) + 0.5)
+	}
+	return color.RGBA{
+		mix(c00.R, c10.R, c01.R, c11.R),
+		mix(c00.G, c10.G, c01.G, c11.G),
+		mix(c00.B, c10.B, c01.B, c11.B),
+		mix(c00.A, c10.A, c01.A, c11.A),
+	}
+}
+
+func lit(channel uint8, light float32) uint8 {
+	value := float32(channel) * light
+	if value > 255 {
+		return 255
+	}
+	return uint8(value + 0.5)
+}
+
+func edge(ax, ay, bx, by, px, py float32) float32 {
+	return ((px - ax) * (by - ay)) - ((py - ay) * (bx - ax))
+}
+
+func min3(a, b, c float32) float32 {
+	return float32(m.Min(float64(a), m.Min(float64(b), float64(c))))
+}
+
+func max3(a, b, c float32) float32 {
+	return float32(m.Max(float64(a), m.Max(float64(b), float64(c))))
+}
+
+func wrapInt(value, size int) int {
+	value %= size
+	if value < 0 {
+		value += size
+	}
+	return value
+}
+
+func clampInt(value, low, high int) int {
+	if value < low {
+		return low
+	}
+	if value > high {
+		return high
+	}
+	return value
+}
@@ -0,0 +1,101 @@
+package Software
+
+import (
+	"flag"
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden images in testdata")
+
+// quad is two triangles facing +Z, a unit square centred on the origin,
+// with atlas coordinates covering the whole texture.
+var quad = Draw{
+	Vertices: []float32{
+		-0.5, -0.5, 0, 0.5, -0.5, 0, 0.5, 0.5, 0,
+		-0.5, -0.5, 0, 0.5, 0.5, 0, -0.5, 0.5, 0,
+	},
+	Normals: []float32{
+		0, 0, 1, 0, 0, 1, 0, 0, 1,
+		0, 0, 1, 0, 0, 1, 0, 0, 1,
+	},
+	TextureData: []float32{
+		0, 1, 1, 1, 1, 0,
+		0, 1, 1, 0, 0, 0,
+	},
+	Length:      6,
+	VertexCount: 6,
+	DepthWrite:  true,
+}
+
+func createChecker(size, squares int) *image.RGBA {
+	checker := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if ((x*squares/size)+(y*squares/size))%2 == 0 {
+				checker.SetRGBA(x, y, color.RGBA{200, 40, 40, 255})
+			} else {
+				checker.SetRGBA(x, y, color.RGBA{40, 40, 200, 255})
+			}
+		}
+	}
+	return checker
+}
+
+func TestRenderGolden(t *testing.T) {
+	UpdateGolden = *update
+	raster := CreateRasterizer(64, 64)
+	camera := mgl32.LookAtV(mgl32.Vec3{0.6, 0.4, 2}, mgl32.Vec3{}, mgl32.Vec3{0, 1, 0})
+	projection := mgl32.Perspective(mgl32.DegToRad(45), 1, 0.1, 10)
+
+	textured := quad
+	textured.Texture = createChecker(16, 4)
+	textured.Instances = []float32{0, 0, 0, 0}
+	textured.Projection, textured.Camera = projection, camera
+	raster.Render(textured)
+
+	// An untextured quad partly behind the first one checks the depth test
+	behind := quad
+	behind.Instances = []float32{0.4, 0.3, -0.5, 0}
+	behind.Projection, behind.Camera = projection, camera
+	raster.Render(behind)
+
+	if raster.Image.RGBAAt(0, 0) != raster.ClearColor {
+		t.Errorf("corner pixel %v, want the clear colour", raster.Image.RGBAAt(0, 0))
+	}
+	if centre := raster.Image.RGBAAt(32, 32); centre == raster.ClearColor {
+		t.Error("centre pixel was not drawn")
+	}
+	if err := MatchGolden(raster.Image, filepath.Join("testdata", "quad.png"), Tolerance{Channel: 2, Pixels: 0.001}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCompareImages(t *testing.T) {
+	want := createChecker(8, 2)
+	got := createChecker(8, 2)
+	got.SetRGBA(1, 1, color.RGBA{0, 255, 0, 255})
+	got.SetRGBA(2, 2, color.RGBA{201, 40, 40, 255})
+
+	diff, err := CompareImages(got, want, Tolerance{Channel: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.Mismatched != 1 || diff.Total != 64 || diff.MaxDelta != 215 {
+		t.Errorf("diff %v of %v pixels with max delta %v, want 1 of 64 with 215", diff.Mismatched, diff.Total, diff.MaxDelta)
+	}
+	if diff.Diff.RGBAAt(1, 1) != (color.RGBA{255, 0, 0, 255}) {
+		t.Error("mismatched pixel not marked red in the diff")
+	}
+	if diff.Within(Tolerance{Pixels: 0}) || !diff.Within(Tolerance{Pixels: 1.0 / 64}) {
+		t.Error("Within does not follow the pixel tolerance")
+	}
+
+	if _, err = CompareImages(createChecker(4, 2), want, Tolerance{}); err == nil {
+		t.Error("images of different sizes compared without an error")
+	}
+}
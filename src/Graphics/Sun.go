@@ -0,0 +1,22 @@
+package Graphics
+
+import m "math"
+
+// SunBlock returns the directional light laid out as the std140 Sun uniform
+// block in cubeShader.shad: color, direction, then intensity.
+func SunBlock() [9]float32 {
+	var sun [9]float32
+	// Sun color
+	sun[0] = 1.0
+	sun[1] = 1.0
+	sun[2] = 1.0
+	sun[3] = 0.0
+	// Sun position
+	sun[4] = float32(m.Cos(250.0 * m.Pi / 180))
+	sun[5] = float32(m.Sin(250.0 * m.Pi / 180))
+	sun[6] = float32(m.Cos(60 * m.Pi / 180))
+	sun[7] = 0.0
+	// Sun intensity
+	sun[8] = 0.8
+	return sun
+}
@@ -1,72 +1,108 @@
 package Model
 
-import "github.com/allanks/Voxel-Engine/src/ObjectLoader"
+import (
+	"fmt"
 
-const (
-	collisionDistance float64 = 0.15
+	"github.com/allanks/Voxel-Engine/src/Block"
+	"github.com/allanks/Voxel-Engine/src/ObjectLoader"
+	"github.com/allanks/Voxel-Engine/src/TexturePacker"
 )
 
 const (
-	// Cube Types
-	Empty = iota
-	SkyBox
-	Dirt
-	Grass
-	Stone
-	CobbleStone
-	Gravel
+	collisionDistance float64 = 0.15
+	verticesPerFace   int     = 6
 )
 
-type skyBox struct {
-	texture []float32
-}
-
 type GCube struct {
 	Texture []float32
 	Gtype   uint8
 }
 
-var sky skyBox
+// GCubes holds the texture data of every block, indexed by block id.
+var GCubes []GCube
 
-var GCubes = []GCube{
-	GCube{},
-	GCube{},
-	GCube{},
-	GCube{},
-	GCube{},
-	GCube{},
-	GCube{},
-}
+var atlas *TexturePacker.Manifest
+var textureArray *TexturePacker.ArrayManifest
+
+// UseTextureArray draws cubes from a texture array with one layer per block
+// texture instead of the atlas, so textures can repeat across a face.
+var UseTextureArray bool
 
 func getTextureBuffer() []float32 {
 
 	textureBuffer := []float32{}
-	for i := 0; i < 36; i++ {
-		textureBuffer = append(textureBuffer, 0, 0)
-	}
-
-	textureBuffer = append(textureBuffer, sky.texture...)
-
 	for _, gCube := range GCubes {
-		if gCube.Gtype == 0 {
-			continue
+		if gCube.Texture == nil {
+			gCube.Texture = make([]float32, len(models[Cube].uv))
 		}
 		textureBuffer = append(textureBuffer, gCube.Texture...)
 	}
 	return textureBuffer
 }
 
+// InitGCubes builds the per vertex atlas coordinates of every registered
+// block by mapping the unit square UVs of cube.obj onto the texture of each
+// face.
+// With UseTextureArray the texture data holds the layer of each face instead
+// and the cube.obj UVs are used as they are.
 func InitGCubes() {
-	_, _, sky.texture = ObjectLoader.LoadObjFile("cube/skybox.obj")
-	_, _, GCubes[Dirt].Texture = ObjectLoader.LoadObjFile("cube/dirt.obj")
-	_, _, GCubes[Grass].Texture = ObjectLoader.LoadObjFile("cube/grass.obj")
-	_, _, GCubes[Stone].Texture = ObjectLoader.LoadObjFile("cube/stone.obj")
-	_, _, GCubes[CobbleStone].Texture = ObjectLoader.LoadObjFile("cube/cobblestone.obj")
-	_, _, GCubes[Gravel].Texture = ObjectLoader.LoadObjFile("cube/gravel.obj")
+	var err error
+	if UseTextureArray {
+		textureArray, err = TexturePacker.LoadArrayManifest(TexturePacker.ArrayManifestFile)
+	} else {
+		atlas, err = TexturePacker.LoadManifest(TexturePacker.ManifestFile)
+	}
+	if err != nil {
+		panic(err)
+	}
 
-	GCubes[Dirt].Gtype = Dirt
-	GCubes[Grass].Gtype = Grass
-	GCubes[Stone].Gtype = Stone
-	GCubes[CobbleStone].Gtype = CobbleStone
-	GCubes[Gravel].Gtype = Gravel
+	mesh, err := ObjectLoader.LoadObjFile("cube/cube.obj")
+	if err != nil {
+		panic(err)
+	}
+	uv := mesh.UV
+	GCubes = make([]GCube, Block.Count())
+	for id := range GCubes {
+		definition := Block.Get(id)
+		if definition == nil || definition.ID == Block.Empty {
+			continue
+		}
+		if UseTextureArray {
+			GCubes[id].Texture = mapCubeLayers(uv, definition.Textures)
+		} else {
+			GCubes[id].Texture = mapCubeUVs(uv, definition.Textures)
+		}
+		GCubes[id].Gtype = definition.ID
+	}
+}
+
+func mapCubeUVs(uv []float32, faces [6]string) []float32 {
+	texture := make([]float32, len(uv))
+	for i := 0; i < len(uv)/2; i++ {
+		name := faces[i/verticesPerFace]
+		region, ok := atlas.Textures[name]
+		if !ok {
+			panic(fmt.Errorf("texture %v is not in the atlas", name))
+		}
+		if region.Atlas != 0 {
+			panic(fmt.Errorf("texture %v was packed into atlas %v, cubes only bind the first atlas", name, region.Atlas))
+		}
+		texture[i*2], texture[(i*2)+1] = region.MapUV(uv[i*2], uv[(i*2)+1])
+	}
+	return texture
+}
+
+// mapCubeLayers stores the array layer of each vertex in the first component,
+// keeping the same two floats per vertex as the atlas coordinates.
+func mapCubeLayers(uv []float32, faces [6]string) []float32 {
+	texture := make([]float32, len(uv))
+	for i := 0; i < len(uv)/2; i++ {
+		name := faces[i/verticesPerFace]
+		layer, ok := textureArray.Layers[name]
+		if !ok {
+			panic(fmt.Errorf("texture %v is not in the texture array", name))
+		}
+		texture[i*2] = float32(layer)
+	}
+	return texture
 }
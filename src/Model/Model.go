@@ -2,68 +2,238 @@ package Model
 
 import (
 	"fmt"
+	"image"
+	"image/draw"
+	"path/filepath"
+	"strings"
 
 	"github.com/allanks/Voxel-Engine/src/Graphics"
 	"github.com/allanks/Voxel-Engine/src/ObjectLoader"
+	"github.com/go-gl/mathgl/mgl32"
 )
 
-const textureAtlas string = "resource/texture/textureAtlas.png"
-const textureGopher string = "resource/texture/Gopher/gopher.png"
 const (
 	Cube = iota
 	Gopher
 )
 
-var TestMob Mob
+// textureGopher is drawn on the parts of the gopher whose material has no
+// texture of its own, as the gopher was before it had materials.
+const textureGopher string = "resource/texture/Gopher/gopher.png"
 
 var models = []model{
 	model{},
 	model{},
 }
 
-type Mob struct {
-	xPos, yPos, zPos float64
-	mType            uint
-}
-
 type model struct {
 	vertices, normals, uv, ssbo []float32
+	indices                     []uint32
 	scale                       float32
 	texture                     uint32
+	layered                     bool
+	program                     int
+	parts                       []part
+	skin
+}
+
+// skin is what a skinned model is animated with. jointMatrices holds the
+// pose the next draw uses.
+type skin struct {
+	joints, weights []float32
+	skeleton        *Skeleton
+	clips           []Clip
+	pose            Pose
+	jointMatrices   []mgl32.Mat4
+}
+
+// part is a run of vertices drawn with one material.
+type part struct {
+	first, count int32
+	material     Graphics.Material
 }
 
 var Controller Graphics.OpenGLController
 var Control Graphics.OpenGLControl
 
+// textures caches material textures by file so models sharing one upload it
+// once.
+var textures = map[string]uint32{}
+
 func InitModels() {
-	TestMob.xPos = 3
-	TestMob.yPos = 62
-	TestMob.zPos = 5
-	models[Cube].vertices, models[Cube].normals, models[Cube].uv = ObjectLoader.LoadObjFile("cube/cube.obj")
+	loadModel(Cube, "cube/cube.obj")
 	models[Cube].scale = 1.00
 	models[Cube].ssbo = getTextureBuffer()
-	models[Cube].texture = Control.CreateTexture(textureAtlas)
+	if UseTextureArray {
+		models[Cube].texture = Control.CreateTextureArray(textureArray.ArrayFile(), int32(textureArray.LayerCount()))
+		models[Cube].layered = true
+	} else {
+		models[Cube].texture = Control.CreateMipmappedTexture(atlas.MipmapFiles(0))
+	}
 
 	fmt.Printf("Cube vertices %v\n", len(models[Cube].vertices))
 	fmt.Printf("Cube normals %v\n", len(models[Cube].normals))
 	fmt.Printf("Cube uvs %v\n", len(models[Cube].uv))
 
-	models[Gopher].vertices, models[Gopher].normals, models[Gopher].uv = ObjectLoader.LoadObjFile("gopher-3d-master/gopher.obj")
+	mesh := loadMobModel(Gopher, "gopher-3d-master/gopher.obj")
 	models[Gopher].scale = 1.0
 	models[Gopher].ssbo = []float32{0}
-	models[Gopher].texture = Control.CreateTexture(textureGopher)
+	models[Gopher].parts = loadParts(mesh.Groups, mesh.Materials, textureGopher)
 
 	fmt.Printf("Gopher vertices %v\n", len(models[Gopher].vertices))
 	fmt.Printf("Gopher normals %v\n", len(models[Gopher].normals))
 	fmt.Printf("Gopher uvs %v\n", len(models[Gopher].uv))
+	fmt.Printf("Gopher indices %v\n", len(models[Gopher].indices))
+}
+
+func loadModel(modelType int, file string) *ObjectLoader.Mesh {
+	mesh, err := ObjectLoader.LoadObjFile(file)
+	if err != nil {
+		panic(err)
+	}
+	models[modelType].vertices, models[modelType].normals, models[modelType].uv = mesh.Vertices, mesh.Normals, mesh.UV
+	return mesh
+}
+
+// loadIndexedModel loads an OBJ or glTF model as an indexed mesh, which is
+// drawn through the index buffer.
+func loadIndexedModel(modelType int, file string) *ObjectLoader.IndexedMesh {
+	indexed, err := ObjectLoader.LoadIndexedMesh(file)
+	if err != nil {
+		panic(err)
+	}
+	models[modelType].vertices, models[modelType].normals, models[modelType].uv = indexed.Vertices, indexed.Normals, indexed.UV
+	models[modelType].indices = indexed.Indices
+	return indexed
+}
+
+// loadMobModel loads a model for the mob program. glTF models with a skin
+// are drawn with the skinned program and can be animated.
+func loadMobModel(modelType int, file string) *ObjectLoader.IndexedMesh {
+	models[modelType].program = Graphics.MobProgram
+	extension := strings.ToLower(filepath.Ext(file))
+	if extension != ".gltf" && extension != ".glb" {
+		return loadIndexedModel(modelType, file)
+	}
+
+	scene, err := ObjectLoader.LoadGLTFFile(file)
+	if err != nil {
+		panic(err)
+	}
+	mesh := scene.Mesh
+	models[modelType].vertices, models[modelType].normals, models[modelType].uv = mesh.Vertices, mesh.Normals, mesh.UV
+	models[modelType].indices = mesh.Indices
+	if len(scene.Skins) == 0 || mesh.Joints == nil {
+		return mesh
+	}
+
+	skeleton, clips, err := LoadSkeleton(scene, 0)
+	if err != nil {
+		panic(err)
+	}
+	models[modelType].program = Graphics.SkinnedProgram
+	models[modelType].skin = skin{
+		joints:   mesh.Joints,
+		weights:  mesh.Weights,
+		skeleton: skeleton,
+		clips:    clips,
+		pose:     skeleton.RestPose(),
+	}
+	models[modelType].jointMatrices = skeleton.JointMatrices(models[modelType].pose)
+	return mesh
+}
+
+// Animate poses a skinned model with the named clip at time seconds,
+// looping it. Models without the clip are drawn in their rest pose and
+// models without a skin are left alone.
+func Animate(modelType int, clipName string, time float32) {
+	current := &models[modelType]
+	if current.skeleton == nil {
+		return
+	}
+	current.pose = current.skeleton.RestPose()
+	for i := range current.clips {
+		if current.clips[i].Name == clipName {
+			current.clips[i].Sample(current.pose, time, true)
+			break
+		}
+	}
+	current.jointMatrices = current.skeleton.JointMatrices(current.pose)
+}
+
+// loadParts turns the groups of a mesh into parts, uploading the diffuse
+// textures their materials name. Groups without a material are drawn white.
+// Parts without a texture get the fallback texture, unless it is "".
+func loadParts(groups []ObjectLoader.Group, materials map[string]*ObjectLoader.Material, fallback string) []part {
+	parts := []part{}
+	for _, group := range groups {
+		material := Graphics.DefaultMaterial(0)
+		if objMaterial, ok := materials[group.Material]; ok {
+			material = Graphics.Material{
+				Ambient:   objMaterial.Ambient,
+				Diffuse:   objMaterial.Diffuse,
+				Specular:  objMaterial.Specular,
+				Shininess: objMaterial.Shininess,
+				Opacity:   objMaterial.Opacity,
+			}
+			if objMaterial.DiffuseImage != nil {
+				rgba := image.NewRGBA(objMaterial.DiffuseImage.Bounds())
+				draw.Draw(rgba, rgba.Bounds(), objMaterial.DiffuseImage, rgba.Bounds().Min, draw.Src)
+				material.Texture = Control.CreateImageTexture(rgba)
+			} else if objMaterial.DiffuseMap != "" {
+				material.Texture = loadTexture(objMaterial.DiffuseMap)
+			}
+		}
+		if material.Texture == 0 && fallback != "" {
+			material.Texture = loadTexture(fallback)
+		}
+		parts = append(parts, part{int32(group.First), int32(group.Count), material})
+	}
+	return parts
+}
+
+func loadTexture(file string) uint32 {
+	if texture, ok := textures[file]; ok {
+		return texture
+	}
+	textures[file] = Control.CreateTexture(file)
+	return textures[file]
 }
 
+// Render draws every instance of a model, one draw per part for models with
+// materials.
 func Render(instances []float32, modelType int) {
-	Controller.RenderInstances(instances, int32(len(models[modelType].vertices)/3))
+	if models[modelType].parts == nil {
+		Controller.RenderInstances(instances, int32(len(models[modelType].vertices)/3))
+		return
+	}
+	for _, part := range models[modelType].parts {
+		Controller.BindMaterial(part.material)
+		if models[modelType].indices != nil {
+			Controller.RenderIndexedInstances(instances, part.first, part.count)
+		} else {
+			Controller.RenderInstanceRange(instances, part.first, part.count)
+		}
+	}
 }
 
 func BindBuffers(offset []float32, modelType int) {
+	Controller.SelectProgram(models[modelType].program)
 	Controller.BindBuffers(models[modelType].vertices, models[modelType].normals, models[modelType].ssbo, models[modelType].uv)
 	Controller.BindUniforms([]float32{models[modelType].scale}, []float32{float32(len(models[modelType].vertices) / 3)}, offset)
-	Controller.BindTexture(models[modelType].texture)
+	if models[modelType].indices != nil {
+		Controller.BindIndices(models[modelType].indices)
+	}
+	if models[modelType].skeleton != nil {
+		Controller.BindSkin(models[modelType].joints, models[modelType].weights)
+		Controller.BindJointMatrices(models[modelType].jointMatrices)
+	}
+	if models[modelType].parts != nil {
+		return
+	}
+	if models[modelType].layered {
+		Controller.BindTextureArray(models[modelType].texture)
+	} else {
+		Controller.BindTexture(models[modelType].texture)
+	}
 }
@@ -0,0 +1,44 @@
+package Model
+
+import (
+	"testing"
+
+	"github.com/allanks/Voxel-Engine/src/Graphics/Headless"
+	"github.com/allanks/Voxel-Engine/src/ObjectLoader"
+)
+
+func TestLoadPartsFallbackTexture(t *testing.T) {
+	control := &Headless.HeadlessControl{}
+	Control = control
+	textures = map[string]uint32{}
+
+	groups := []ObjectLoader.Group{
+		{Name: "body", Material: "fur", First: 0, Count: 6},
+		{Name: "eyes", Material: "eye", First: 6, Count: 3},
+		{Name: "teeth", First: 9, Count: 3},
+	}
+	materials := map[string]*ObjectLoader.Material{
+		"fur": {Name: "fur", Diffuse: [3]float32{0.5, 0.7, 0.9}, Opacity: 1},
+		"eye": {Name: "eye", Diffuse: [3]float32{1, 1, 1}, Opacity: 1, DiffuseMap: "eye.png"},
+	}
+
+	parts := loadParts(groups, materials, "gopher.png")
+	want := []string{"gopher.png", "eye.png", "gopher.png"}
+	for i, part := range parts {
+		if file := control.TextureFile(part.material.Texture); file != want[i] {
+			t.Errorf("part %v textured with %q, want %q", groups[i].Name, file, want[i])
+		}
+	}
+	if len(control.Textures) != 2 {
+		t.Errorf("uploaded %v textures, want the fallback once and eye.png", len(control.Textures))
+	}
+	if parts[0].material.Diffuse != materials["fur"].Diffuse {
+		t.Errorf("fur drawn with diffuse %v, want its own", parts[0].material.Diffuse)
+	}
+
+	for _, part := range loadParts(groups[2:], materials, "") {
+		if part.material.Texture != 0 {
+			t.Error("part without a material was textured without a fallback")
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package Model
+
+import (
+	"fmt"
+	m "math"
+	"sort"
+
+	"github.com/allanks/Voxel-Engine/src/ObjectLoader"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// Track properties
+const (
+	TrackTranslation = iota
+	TrackRotation
+	TrackScale
+)
+
+// Track interpolations
+const (
+	InterpolationLinear = iota
+	InterpolationStep
+	InterpolationCubic
+)
+
+// Joint is one bone of a skeleton. Parent is the index of the closest
+// ancestor that is a joint, -1 for roots. Base holds the transforms of any
+// nodes between the joint and that ancestor, which are not animated.
+type Joint struct {
+	Name        string
+	Parent      int
+	Translation mgl32.Vec3
+	Rotation    mgl32.Quat
+	Scale       mgl32.Vec3
+	Base        mgl32.Mat4
+	InverseBind mgl32.Mat4
+}
+
+type Skeleton struct {
+	Joints []Joint
+}
+
+// Track animates one property of a joint. Values holds 3 floats per key for
+// translation and scale and 4 (x, y, z, w) for rotation. Cubic tracks hold
+// an in tangent, the value and an out tangent for every key.
+type Track struct {
+	Joint, Property, Interpolation int
+	Times, Values                  []float32
+}
+
+type Clip struct {
+	Name     string
+	Duration float32
+	Tracks   []Track
+}
+
+// Pose is the local transform of every joint of a skeleton.
+type Pose struct {
+	Translations []mgl32.Vec3
+	Rotations    []mgl32.Quat
+	Scales       []mgl32.Vec3
+}
+
+// LoadSkeleton builds the skeleton of a glTF skin and the clips of the
+// animations that move its joints. Channels on other nodes are dropped.
+func LoadSkeleton(scene *ObjectLoader.Scene, skin int) (*Skeleton, []Clip, error) {
+	if skin < 0 || skin >= len(scene.Skins) {
+		return nil, nil, fmt.Errorf("skin %v does not exist", skin)
+	}
+	source := scene.Skins[skin]
+	nodeJoints := map[int]int{}
+	for joint, node := range source.Joints {
+		nodeJoints[node] = joint
+	}
+
+	skeleton := &Skeleton{Joints: make([]Joint, len(source.Joints))}
+	for joint, node := range source.Joints {
+		current := scene.Nodes[node]
+		skeleton.Joints[joint] = Joint{
+			Name:        current.Name,
+			Parent:      -1,
+			Translation: current.Translation,
+			Rotation:    current.Rotation,
+			Scale:       current.Scale,
+			Base:        mgl32.Ident4(),
+			InverseBind: source.InverseBindMatrices[joint],
+		}
+		for parent := current.Parent; parent >= 0; parent = scene.Nodes[parent].Parent {
+			if index, ok := nodeJoints[parent]; ok {
+				skeleton.Joints[joint].Parent = index
+				break
+			}
+			skeleton.Joints[joint].Base = scene.Nodes[parent].Local.Mul4(skeleton.Joints[joint].Base)
+		}
+	}
+
+	clips := []Clip{}
+	for _, animation := range scene.Animations {
+		clip := Clip{Name: animation.Name}
+		for _, channel := range animation.Channels {
+			joint, ok := nodeJoints[channel.Node]
+			if !ok || len(channel.Times) == 0 {
+				continue
+			}
+			track := Track{Joint: joint, Times: channel.Times, Values: channel.Values}
+			switch channel.Path {
+			case "translation":
+				track.Property = TrackTranslation
+			case "rotation":
+				track.Property = TrackRotation
+			case "scale":
+				track.Property = TrackScale
+			default:
+				continue
+			}
+			switch channel.Interpolation {
+			case "STEP":
+				track.Interpolation = InterpolationStep
+			case "CUBICSPLINE":
+				track.Interpolation = InterpolationCubic
+			}
+			size := 3
+			if track.Property == TrackRotation {
+				size = 4
+			}
+			keys := len(channel.Times)
+			if track.Interpolation == InterpolationCubic {
+				keys *= 3
+			}
+			if len(channel.Values) != keys*size {
+				return nil, nil, fmt.Errorf("animation %v: %v keys of joint %v do not match %v values", animation.Name, channel.Path, joint, len(channel.Values))
+			}
+			if end := channel.Times[len(channel.Times)-1]; end > clip.Duration {
+				clip.Duration = end
+			}
+			clip.Tracks = append(clip.Tracks, track)
+		}
+		clips = append(clips, clip)
+	}
+	return skeleton, clips, nil
+}
+
+// RestPose returns the pose the joints have without an animation.
+func (skeleton *Skeleton) RestPose() Pose {
+	pose := Pose{
+		Translations: make([]mgl32.Vec3, len(skeleton.Joints)),
+		Rotations:    make([]mgl32.Quat, len(skeleton.Joints)),
+		Scales:       make([]mgl32.Vec3, len(skeleton.Joints)),
+	}
+	for i, joint := range skeleton.Joints {
+		pose.Translations[i], pose.Rotations[i], pose.Scales[i] = joint.Translation, joint.Rotation, joint.Scale
+	}
+	return pose
+}
+
+// JointMatrices returns the skinning matrix of every joint, which moves a
+// vertex from the bind pose into the pose.
+func (skeleton *Skeleton) JointMatrices(pose Pose) []mgl32.Mat4 {
+	world := make([]mgl32.Mat4, len(skeleton.Joints))
+	done := make([]bool, len(skeleton.Joints))
+	var worldOf func(int) mgl32.Mat4
+	worldOf = func(joint int) mgl32.Mat4 {
+		if !done[joint] {
+			parent := mgl32.Ident4()
+			if skeleton.Joints[joint].Parent >= 0 {
+				parent = worldOf(skeleton.Joints[joint].Parent)
+			}
+			local := ObjectLoader.LocalTransform(pose.Translations[joint], pose.Rotations[joint], pose.Scales[joint])
+			world[joint], done[joint] = parent.Mul4(skeleton.Joints[joint].Base).Mul4(local), true
+		}
+		return world[joint]
+	}
+
+	matrices := make([]mgl32.Mat4, len(skeleton.Joints))
+	for joint := range skeleton.Joints {
+		matrices[joint] = worldOf(joint).Mul4(skeleton.Joints[joint].InverseBind)
+	}
+	return matrices
+}
+
+// Sample sets the joints the clip animates to their value at time. Looping
+// clips wrap around their duration, others hold their last key.
+func (clip *Clip) Sample(pose Pose, time float32, loop bool) {
+	if loop && clip.Duration > 0 {
+		time = float32(m.Mod(float64(time), float64(clip.Duration)))
+		if time < 0 {
+			time += clip.Duration
+		}
+	}
+	for _, track := range clip.Tracks {
+		switch track.Property {
+		case TrackTranslation:
+			value := track.sample(time, 3)
+			pose.Translations[track.Joint] = mgl32.Vec3{value[0], value[1], value[2]}
+		case TrackRotation:
+			value := track.sample(time, 4)
+			pose.Rotations[track.Joint] = mgl32.Quat{W: value[3], V: mgl32.Vec3{value[0], value[1], value[2]}}.Normalize()
+		case TrackScale:
+			value := track.sample(time, 3)
+			pose.Scales[track.Joint] = mgl32.Vec3{value[0], value[1], value[2]}
+		}
+	}
+}
+
+// sample interpolates the track at time, rotations are slerped.
+func (track *Track) sample(time float32, size int) []float32 {
+	keys := len(track.Times)
+	next := sort.Search(keys, func(i int) bool { return track.Times[i] > time })
+	switch {
+	case next == 0:
+		return track.key(0, size)
+	case next == keys:
+		return track.key(keys-1, size)
+	case track.Interpolation == InterpolationStep:
+		return track.key(next-1, size)
+	}
+
+	previous := next - 1
+	span := track.Times[next] - track.Times[previous]
+	t := (time - track.Times[previous]) / span
+	if track.Interpolation == InterpolationCubic {
+		return track.cubic(previous, next, t, span, size)
+	}
+	a, b := track.key(previous, size), track.key(next, size)
+	if size == 4 {
+		q := mgl32.QuatSlerp(mgl32.Quat{W: a[3], V: mgl32.Vec3{a[0], a[1], a[2]}}, mgl32.Quat{W: b[3], V: mgl32.Vec3{b[0], b[1], b[2]}}, t)
+		return []float32{q.V[0], q.V[1], q.V[2], q.W}
+	}
+	value := make([]float32, size)
+	for i := range value {
+		value[i] = a[i] + ((b[i] - a[i]) * t)
+	}
+	return value
+}
+
+// key returns the value of a key, skipping the tangents of cubic tracks.
+func (track *Track) key(index, size int) []float32 {
+	if track.Interpolation == InterpolationCubic {
+		return track.Values[((index*3)+1)*size : ((index*3)+2)*size]
+	}
+	return track.Values[index*size : (index+1)*size]
+}
+
+// cubic evaluates the Hermite spline glTF defines between two keys.
+func (track *Track) cubic(previous, next int, t, span float32, size int) []float32 {
+	t2, t3 := t*t, t*t*t
+	start := track.Values[((previous*3)+1)*size:]
+	out := track.Values[((previous*3)+2)*size:]
+	in := track.Values[(next*3)*size:]
+	end := track.Values[((next*3)+1)*size:]
+	value := make([]float32, size)
+	for i := range value {
+		value[i] = (((2 * t3) - (3 * t2) + 1) * start[i]) +
+			((t3 - (2 * t2) + t) * span * out[i]) +
+			(((-2 * t3) + (3 * t2)) * end[i]) +
+			((t3 - t2) * span * in[i])
+	}
+	return value
+}
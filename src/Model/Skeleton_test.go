@@ -0,0 +1,242 @@
+package Model
+
+import (
+	"io/ioutil"
+	m "math"
+	"path/filepath"
+	"testing"
+
+	"github.com/allanks/Voxel-Engine/src/ObjectLoader"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const sin45, sin22, cos22 float32 = 0.70710678, 0.38268343, 0.92387953
+
+func equalFloats(a, b []float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if m.Abs(float64(a[i]-b[i])) > 1e-5 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestTrackSample(t *testing.T) {
+	linear := Track{Times: []float32{0, 2}, Values: []float32{0, 0, 0, 2, 4, 6}}
+	step := Track{Interpolation: InterpolationStep, Times: []float32{0, 1, 2}, Values: []float32{1, 1, 1, 2, 2, 2, 3, 3, 3}}
+	rotation := Track{Property: TrackRotation, Times: []float32{0, 1}, Values: []float32{0, 0, 0, 1, 0, 0, sin45, sin45}}
+	// Keys of 0 and 1 leaving the first at a slope of 1 and arriving flat
+	cubic := Track{Interpolation: InterpolationCubic, Times: []float32{0, 2}, Values: []float32{0, 0, 1, 0, 1, 0}}
+	tests := []struct {
+		name  string
+		track Track
+		size  int
+		time  float32
+		want  []float32
+	}{
+		{"linear before the first key", linear, 3, -1, []float32{0, 0, 0}},
+		{"linear between keys", linear, 3, 1, []float32{1, 2, 3}},
+		{"linear after the last key", linear, 3, 3, []float32{2, 4, 6}},
+		{"step before a key", step, 3, 0.99, []float32{1, 1, 1}},
+		{"step on a key", step, 3, 1, []float32{2, 2, 2}},
+		{"step between keys", step, 3, 1.5, []float32{2, 2, 2}},
+		{"slerp halfway", rotation, 4, 0.5, []float32{0, 0, sin22, cos22}},
+		{"slerp on the last key", rotation, 4, 1, []float32{0, 0, sin45, sin45}},
+		{"cubic halfway", cubic, 1, 1, []float32{0.75}},
+		{"cubic on the last key", cubic, 1, 2, []float32{1}},
+		{"cubic before the first key", cubic, 1, -1, []float32{0}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if value := test.track.sample(test.time, test.size); !equalFloats(value, test.want) {
+				t.Errorf("sample(%v) = %v, want %v", test.time, value, test.want)
+			}
+		})
+	}
+}
+
+func TestClipSample(t *testing.T) {
+	clip := Clip{Duration: 2, Tracks: []Track{{Times: []float32{0, 2}, Values: []float32{0, 0, 0, 2, 0, 0}}}}
+	tests := []struct {
+		name string
+		time float32
+		loop bool
+		want float32
+	}{
+		{"inside the clip", 0.5, true, 0.5},
+		{"looped past the end", 4.5, true, 0.5},
+		{"looped before the start", -0.5, true, 1.5},
+		{"held after the end", 4.5, false, 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pose := Pose{Translations: make([]mgl32.Vec3, 1), Rotations: make([]mgl32.Quat, 1), Scales: make([]mgl32.Vec3, 1)}
+			clip.Sample(pose, test.time, test.loop)
+			if x := pose.Translations[0][0]; !equalFloats([]float32{x}, []float32{test.want}) {
+				t.Errorf("x = %v, want %v", x, test.want)
+			}
+		})
+	}
+}
+
+func TestClipSampleLongTime(t *testing.T) {
+	clip := Clip{Duration: 0.3, Tracks: []Track{{Times: []float32{0, 0.3}, Values: []float32{0, 0, 0, 0.3, 0, 0}}}}
+	pose := Pose{Translations: make([]mgl32.Vec3, 1), Rotations: make([]mgl32.Quat, 1), Scales: make([]mgl32.Vec3, 1)}
+	clip.Sample(pose, 1e30, true)
+	if x := pose.Translations[0][0]; x < 0 || x > clip.Duration {
+		t.Errorf("x = %v, want it inside the clip", x)
+	}
+}
+
+// createTestSkeleton returns a root joint at the origin with an arm joint
+// one above it, bound where they stand.
+func createTestSkeleton() *Skeleton {
+	return &Skeleton{Joints: []Joint{
+		{Name: "root", Parent: -1, Rotation: mgl32.QuatIdent(), Scale: mgl32.Vec3{1, 1, 1}, Base: mgl32.Ident4(), InverseBind: mgl32.Ident4()},
+		{Name: "arm", Parent: 0, Translation: mgl32.Vec3{0, 1, 0}, Rotation: mgl32.QuatIdent(), Scale: mgl32.Vec3{1, 1, 1}, Base: mgl32.Ident4(), InverseBind: mgl32.Translate3D(0, -1, 0)},
+	}}
+}
+
+func TestJointMatrices(t *testing.T) {
+	turn := mgl32.QuatRotate(m.Pi/2, mgl32.Vec3{0, 0, 1})
+	offset := createTestSkeleton()
+	offset.Joints[1].Base = mgl32.Translate3D(0, 0, 3)
+	offset.Joints[1].InverseBind = mgl32.Translate3D(0, -1, -3)
+	tests := []struct {
+		name     string
+		skeleton *Skeleton
+		pose     func(Pose)
+		joint    int
+		vertex   mgl32.Vec3
+		want     mgl32.Vec3
+	}{
+		{"rest pose", createTestSkeleton(), func(Pose) {}, 1, mgl32.Vec3{0, 2, 0}, mgl32.Vec3{0, 2, 0}},
+		{"root turned", createTestSkeleton(), func(pose Pose) { pose.Rotations[0] = turn }, 1, mgl32.Vec3{0, 2, 0}, mgl32.Vec3{-2, 0, 0}},
+		{"arm turned", createTestSkeleton(), func(pose Pose) { pose.Rotations[1] = turn }, 1, mgl32.Vec3{0, 2, 0}, mgl32.Vec3{-1, 1, 0}},
+		{"root moved", createTestSkeleton(), func(pose Pose) { pose.Translations[0] = mgl32.Vec3{5, 0, 0} }, 0, mgl32.Vec3{0, 0, 0}, mgl32.Vec3{5, 0, 0}},
+		{"arm scaled", createTestSkeleton(), func(pose Pose) { pose.Scales[1] = mgl32.Vec3{2, 2, 2} }, 1, mgl32.Vec3{1, 2, 0}, mgl32.Vec3{2, 3, 0}},
+		{"base between joints", offset, func(pose Pose) { pose.Rotations[1] = turn }, 1, mgl32.Vec3{0, 2, 3}, mgl32.Vec3{-1, 1, 3}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pose := test.skeleton.RestPose()
+			test.pose(pose)
+			matrices := test.skeleton.JointMatrices(pose)
+			moved := matrices[test.joint].Mul4x1(test.vertex.Vec4(1)).Vec3()
+			if !equalFloats(moved[:], test.want[:]) {
+				t.Errorf("vertex %v moved to %v, want %v", test.vertex, moved, test.want)
+			}
+		})
+	}
+}
+
+// createTestScene returns a root joint with an arm joint below a node that
+// is not a joint, animated by a clip with channels on every kind of node.
+func createTestScene() *ObjectLoader.Scene {
+	node := func(name string, parent int, translation mgl32.Vec3) ObjectLoader.Node {
+		return ObjectLoader.Node{Name: name, Parent: parent, Translation: translation, Rotation: mgl32.QuatIdent(), Scale: mgl32.Vec3{1, 1, 1},
+			Local: mgl32.Translate3D(translation[0], translation[1], translation[2]), Mesh: -1, Skin: -1}
+	}
+	return &ObjectLoader.Scene{
+		Nodes: []ObjectLoader.Node{
+			node("root", -1, mgl32.Vec3{}),
+			node("offset", 0, mgl32.Vec3{0, 0, 3}),
+			node("arm", 1, mgl32.Vec3{0, 1, 0}),
+			node("body", -1, mgl32.Vec3{}),
+		},
+		Skins: []ObjectLoader.Skin{{Name: "rig", Joints: []int{2, 0}, InverseBindMatrices: []mgl32.Mat4{mgl32.Translate3D(0, -1, -3), mgl32.Ident4()}}},
+		Animations: []ObjectLoader.Animation{{Name: "wave", Channels: []ObjectLoader.Channel{
+			{Node: 2, Path: "rotation", Interpolation: "STEP", Times: []float32{0, 1.5}, Values: []float32{0, 0, 0, 1, 0, 0, sin45, sin45}},
+			{Node: 0, Path: "translation", Interpolation: "CUBICSPLINE", Times: []float32{0}, Values: []float32{0, 0, 0, 1, 2, 3, 0, 0, 0}},
+			{Node: 3, Path: "translation", Times: []float32{0, 4}, Values: []float32{0, 0, 0, 1, 1, 1}},
+			{Node: 0, Path: "weights", Times: []float32{0, 4}, Values: []float32{0, 1}},
+			{Node: 0, Path: "scale"},
+		}}},
+	}
+}
+
+func TestLoadSkeleton(t *testing.T) {
+	skeleton, clips, err := LoadSkeleton(createTestScene(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arm, root := skeleton.Joints[0], skeleton.Joints[1]
+	if arm.Name != "arm" || arm.Parent != 1 || root.Name != "root" || root.Parent != -1 {
+		t.Errorf("joints = %v %v and %v %v, want arm under root", arm.Name, arm.Parent, root.Name, root.Parent)
+	}
+	if !arm.Base.ApproxEqual(mgl32.Translate3D(0, 0, 3)) {
+		t.Errorf("arm base = %v, want the offset node", arm.Base)
+	}
+	if !arm.InverseBind.ApproxEqual(mgl32.Translate3D(0, -1, -3)) {
+		t.Errorf("arm inverse bind = %v", arm.InverseBind)
+	}
+
+	if len(clips) != 1 {
+		t.Fatalf("%v clips, want 1", len(clips))
+	}
+	clip := clips[0]
+	if clip.Name != "wave" || clip.Duration != 1.5 {
+		t.Errorf("clip %q of %v seconds, want wave of 1.5", clip.Name, clip.Duration)
+	}
+	want := []Track{
+		{Joint: 0, Property: TrackRotation, Interpolation: InterpolationStep},
+		{Joint: 1, Property: TrackTranslation, Interpolation: InterpolationCubic},
+	}
+	if len(clip.Tracks) != len(want) {
+		t.Fatalf("%v tracks, want the joint rotation and translation", len(clip.Tracks))
+	}
+	for i, track := range clip.Tracks {
+		if track.Joint != want[i].Joint || track.Property != want[i].Property || track.Interpolation != want[i].Interpolation {
+			t.Errorf("track %v = joint %v property %v interpolation %v, want %v", i, track.Joint, track.Property, track.Interpolation, want[i])
+		}
+	}
+
+	pose := skeleton.RestPose()
+	clip.Sample(pose, 0, false)
+	if pose.Translations[1] != (mgl32.Vec3{1, 2, 3}) {
+		t.Errorf("root at %v, want the cubic key without its tangents", pose.Translations[1])
+	}
+}
+
+func TestLoadSkeletonErrors(t *testing.T) {
+	if _, _, err := LoadSkeleton(createTestScene(), 1); err == nil {
+		t.Error("loaded a skin that does not exist")
+	}
+	scene := createTestScene()
+	scene.Animations[0].Channels[0].Values = scene.Animations[0].Channels[0].Values[:6]
+	if _, _, err := LoadSkeleton(scene, 0); err == nil {
+		t.Error("loaded a channel with values missing")
+	}
+}
+
+func TestLoadSkeletonFromGLB(t *testing.T) {
+	directory := filepath.Join("..", "ObjectLoader", "testdata")
+	data, err := ioutil.ReadFile(filepath.Join(directory, "skinned.glb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scene, err := ObjectLoader.ParseGLB(data, directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	skeleton, clips, err := LoadSkeleton(scene, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clips) != 1 || clips[0].Name != "bend" {
+		t.Fatalf("clips = %v, want bend", clips)
+	}
+
+	// At the end of bend the hips have stepped 2 forward and the spine has
+	// turned a quarter about z
+	pose := skeleton.RestPose()
+	clips[0].Sample(pose, 1, false)
+	tip := mgl32.Vec3{0, 2, 0}
+	moved := skeleton.JointMatrices(pose)[1].Mul4x1(tip.Vec4(1)).Vec3()
+	if want := (mgl32.Vec3{-1, 1, 2}); !equalFloats(moved[:], want[:]) {
+		t.Errorf("tip of the spine moved to %v, want %v", moved, want)
+	}
+}
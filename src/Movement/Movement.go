@@ -0,0 +1,148 @@
+package Movement
+
+import (
+	m "math"
+
+	"github.com/allanks/Voxel-Engine/src/Server/DataType"
+)
+
+// The client predicts its player with the same Step the server runs, so
+// every constant the result depends on lives here.
+const (
+	WalkSpeed        float32 = 6
+	FlySpeed         float32 = 6
+	JumpSpeed        float32 = 6.5
+	Gravity          float32 = 20
+	TerminalVelocity float32 = -30
+	// MaxDelta is the longest step an input may take, longer frames are cut
+	// short so a stalled client cannot jump through walls
+	MaxDelta float32 = 0.1
+	// Height and Radius are the size of the box a player collides as,
+	// EyeHeight is where its camera sits above its feet
+	Height    float32 = 1.8
+	Radius    float32 = 0.3
+	EyeHeight float32 = 1.6
+)
+
+// World answers whether a block stops players.
+type World interface {
+	IsSolid(x, y, z int) bool
+}
+
+// Step moves a player by one input. Walking players are pulled down by
+// gravity and can jump while on the ground, flying players move along the
+// direction they look and up or down. Axes are held to -1 to 1 and inputs
+// that are not numbers do not move the player.
+func Step(state DataType.MoveState, input DataType.PlayerInput, world World) DataType.MoveState {
+	if !Finite(input) {
+		return state
+	}
+	delta := input.Delta
+	if delta > MaxDelta {
+		delta = MaxDelta
+	}
+	if delta <= 0 {
+		return state
+	}
+	state.Flying = input.Fly
+	input.Forward, input.Strafe, input.Up = clampAxis(input.Forward), clampAxis(input.Strafe), clampAxis(input.Up)
+
+	yaw, pitch := float64(input.Yaw), float64(input.Pitch)
+	forwardX, forwardZ := float32(m.Sin(yaw)), float32(m.Cos(yaw))
+	moveX := (forwardX * input.Forward) - (forwardZ * input.Strafe)
+	moveZ := (forwardZ * input.Forward) + (forwardX * input.Strafe)
+	if length := float32(m.Sqrt(float64((moveX * moveX) + (moveZ * moveZ)))); length > 1 {
+		moveX, moveZ = moveX/length, moveZ/length
+	}
+
+	var moveY float32
+	if state.Flying {
+		state.Fall = 0
+		lookUp := float32(m.Sin(pitch))
+		lookAlong := float32(m.Cos(pitch))
+		moveX, moveZ = moveX*lookAlong, moveZ*lookAlong
+		moveY = ((input.Forward * lookUp) + input.Up) * FlySpeed * delta
+	} else {
+		if input.Jump && state.OnGround {
+			state.Fall = JumpSpeed
+		}
+		state.Fall -= Gravity * delta
+		if state.Fall < TerminalVelocity {
+			state.Fall = TerminalVelocity
+		}
+		moveY = state.Fall * delta
+	}
+
+	speed := WalkSpeed
+	if state.Flying {
+		speed = FlySpeed
+	}
+	// each axis is moved on its own so players slide along walls
+	position := slide(state.Position, moveX*speed*delta, 0, world)
+	position = slide(position, 0, moveZ*speed*delta, world)
+
+	next := position
+	next.YPos += moveY
+	if Collides(next, world) {
+		if moveY < 0 {
+			// land on top of the block below
+			next.YPos = float32(DataType.FloorToInt(next.YPos) + 1)
+			if Collides(next, world) {
+				next.YPos = position.YPos
+			}
+			position = next
+		}
+		state.Fall = 0
+	} else {
+		position = next
+	}
+	state.Position = position
+	state.OnGround = !state.Flying && state.Fall <= 0 && Collides(DataType.Pos{XPos: position.XPos, YPos: position.YPos - 0.05, ZPos: position.ZPos}, world)
+	return state
+}
+
+// Finite reports whether every number of an input is finite.
+func Finite(input DataType.PlayerInput) bool {
+	for _, value := range []float32{input.Forward, input.Strafe, input.Up, input.Yaw, input.Pitch, input.Delta} {
+		if m.IsNaN(float64(value)) || m.IsInf(float64(value), 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func clampAxis(value float32) float32 {
+	if value > 1 {
+		return 1
+	}
+	if value < -1 {
+		return -1
+	}
+	return value
+}
+
+func slide(position DataType.Pos, dx, dz float32, world World) DataType.Pos {
+	next := DataType.Pos{XPos: position.XPos + dx, YPos: position.YPos, ZPos: position.ZPos + dz}
+	if Collides(next, world) {
+		return position
+	}
+	return next
+}
+
+// Collides reports whether the box of a player standing at position
+// overlaps a solid block.
+func Collides(position DataType.Pos, world World) bool {
+	minX, maxX := DataType.FloorToInt(position.XPos-Radius), DataType.FloorToInt(position.XPos+Radius)
+	minZ, maxZ := DataType.FloorToInt(position.ZPos-Radius), DataType.FloorToInt(position.ZPos+Radius)
+	minY, maxY := DataType.FloorToInt(position.YPos), DataType.FloorToInt(position.YPos+Height-0.001)
+	for x := minX; x <= maxX; x++ {
+		for y := minY; y <= maxY; y++ {
+			for z := minZ; z <= maxZ; z++ {
+				if world.IsSolid(x, y, z) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
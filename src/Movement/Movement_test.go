@@ -0,0 +1,57 @@
+package Movement
+
+import (
+	m "math"
+	"testing"
+
+	"github.com/allanks/Voxel-Engine/src/Server/DataType"
+)
+
+// openAir is a world without solid blocks.
+type openAir struct{}
+
+func (openAir) IsSolid(x, y, z int) bool {
+	return false
+}
+
+func TestStepClampsAxes(t *testing.T) {
+	start := DataType.MoveState{Position: DataType.Pos{XPos: 0.5, YPos: 10, ZPos: 0.5}, Flying: true}
+	tests := []struct {
+		name          string
+		input, within DataType.PlayerInput
+	}{
+		{"up", DataType.PlayerInput{Fly: true, Up: 1000, Delta: 0.05}, DataType.PlayerInput{Fly: true, Up: 1, Delta: 0.05}},
+		{"down", DataType.PlayerInput{Fly: true, Up: -1000, Delta: 0.05}, DataType.PlayerInput{Fly: true, Up: -1, Delta: 0.05}},
+		{"forward", DataType.PlayerInput{Fly: true, Forward: 50, Delta: 0.05}, DataType.PlayerInput{Fly: true, Forward: 1, Delta: 0.05}},
+		{"strafe", DataType.PlayerInput{Strafe: -50, Delta: 0.05}, DataType.PlayerInput{Strafe: -1, Delta: 0.05}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			state, want := Step(start, test.input, openAir{}), Step(start, test.within, openAir{})
+			if state != want {
+				t.Errorf("moved to %v, want %v", state.Position, want.Position)
+			}
+		})
+	}
+}
+
+func TestStepIgnoresNonFiniteInput(t *testing.T) {
+	start := DataType.MoveState{Position: DataType.Pos{XPos: 0.5, YPos: 10, ZPos: 0.5}, Flying: true}
+	nan, inf := float32(m.NaN()), float32(m.Inf(1))
+	tests := []struct {
+		name  string
+		input DataType.PlayerInput
+	}{
+		{"delta", DataType.PlayerInput{Fly: true, Forward: 1, Delta: nan}},
+		{"yaw", DataType.PlayerInput{Fly: true, Forward: 1, Yaw: inf, Delta: 0.05}},
+		{"pitch", DataType.PlayerInput{Fly: true, Forward: 1, Pitch: nan, Delta: 0.05}},
+		{"up", DataType.PlayerInput{Fly: true, Up: nan, Delta: 0.05}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if state := Step(start, test.input, openAir{}); state != start {
+				t.Errorf("moved to %v, want to stay at %v", state.Position, start.Position)
+			}
+		})
+	}
+}
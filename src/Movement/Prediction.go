@@ -0,0 +1,49 @@
+package Movement
+
+import "github.com/allanks/Voxel-Engine/src/Server/DataType"
+
+// MaxHistory is how many inputs the server may leave unacknowledged before
+// the oldest are forgotten.
+const MaxHistory int = 256
+
+// Prediction moves a player by its inputs before the server has answered
+// them. The inputs the server has not acknowledged are kept, oldest first,
+// and replayed on top of every state it acknowledges.
+type Prediction struct {
+	State    DataType.MoveState
+	history  []DataType.PlayerInput
+	sequence uint32
+}
+
+// Reset starts over from a state the server gave, forgetting every input.
+func (prediction *Prediction) Reset(state DataType.MoveState) {
+	prediction.State, prediction.history = state, nil
+}
+
+// Predict numbers an input and applies it straight away, returning it to be
+// sent to the server.
+func (prediction *Prediction) Predict(input DataType.PlayerInput, world World) DataType.PlayerInput {
+	prediction.sequence++
+	input.Sequence = prediction.sequence
+	prediction.State = Step(prediction.State, input, world)
+	prediction.history = append(prediction.history, input)
+	if len(prediction.history) > MaxHistory {
+		prediction.history = prediction.history[len(prediction.history)-MaxHistory:]
+	}
+	return input
+}
+
+// Reconcile starts over from the state the server reached after the input
+// numbered sequence and replays the inputs it has not applied yet on top of
+// it.
+func (prediction *Prediction) Reconcile(sequence uint32, state DataType.MoveState, world World) {
+	applied := 0
+	for applied < len(prediction.history) && prediction.history[applied].Sequence <= sequence {
+		applied++
+	}
+	prediction.history = prediction.history[applied:]
+	for _, input := range prediction.history {
+		state = Step(state, input, world)
+	}
+	prediction.State = state
+}
@@ -0,0 +1,61 @@
+package Network
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+
+	"github.com/allanks/Voxel-Engine/src/Server/DataType"
+)
+
+// Handler is called on the network goroutine for every message of the type
+// it was registered for, in the order the handlers were registered.
+type Handler func(*DataType.Message)
+
+var (
+	conn        *DataType.Connection
+	handlers    = map[int][]Handler{}
+	handlerLock sync.Mutex
+)
+
+// Connect dials the server and starts reading messages from it. Handlers
+// should be registered first so no message is missed.
+func Connect(address string) {
+	netConn, err := net.Dial("tcp", address)
+	if err != nil {
+		log.Fatal("Connection error", err)
+	}
+	conn = DataType.CreateConnection(netConn)
+	go listen()
+}
+
+func Handle(messageType int, handler Handler) {
+	handlerLock.Lock()
+	defer handlerLock.Unlock()
+	handlers[messageType] = append(handlers[messageType], handler)
+}
+
+func Send(message *DataType.Message) error {
+	return conn.Send(message)
+}
+
+func Close() {
+	conn.Close()
+}
+
+func listen() {
+	for {
+		message, err := conn.Receive()
+		if err != nil {
+			fmt.Printf("Connection closed %v\n", err)
+			return
+		}
+		handlerLock.Lock()
+		registered := handlers[message.Type]
+		handlerLock.Unlock()
+		for _, handler := range registered {
+			handler(message)
+		}
+	}
+}
@@ -0,0 +1,890 @@
+package ObjectLoader
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io/ioutil"
+	m "math"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	glbMagic      uint32 = 0x46546C67 // "glTF"
+	glbJSONChunk  uint32 = 0x4E4F534A
+	glbBinChunk   uint32 = 0x004E4942
+	trianglesMode int    = 4
+	// Bounds on accessors, which keep their byte offsets from overflowing
+	maxAccessorCount int = 1 << 24
+	maxByteStride    int = 252
+)
+
+// Scene is a glTF file flattened into one indexed mesh. Meshes of nodes
+// without a skin are moved into place by their node's world transform,
+// skinned meshes stay in bind pose for the skin to move.
+type Scene struct {
+	Mesh       *IndexedMesh
+	Nodes      []Node
+	Skins      []Skin
+	Animations []Animation
+}
+
+// Node is one entry of the glTF node hierarchy. Local is the node transform
+// relative to its parent, World includes every parent. Parent, Mesh and
+// Skin are -1 when the node has none.
+type Node struct {
+	Name         string
+	Parent       int
+	Children     []int
+	Translation  mgl32.Vec3
+	Rotation     mgl32.Quat
+	Scale        mgl32.Vec3
+	Local, World mgl32.Mat4
+	Mesh, Skin   int
+}
+
+// Skin lists the nodes acting as joints. The joint attributes of the mesh
+// index into Joints.
+type Skin struct {
+	Name                string
+	Joints              []int
+	InverseBindMatrices []mgl32.Mat4
+	Skeleton            int
+}
+
+type Animation struct {
+	Name     string
+	Channels []Channel
+}
+
+// Channel animates one property of a node. Path is "translation",
+// "rotation", "scale" or "weights", Values holds one key per time with 3
+// floats for translation and scale and 4 (x, y, z, w) for rotation.
+type Channel struct {
+	Node          int
+	Path          string
+	Interpolation string
+	Times, Values []float32
+}
+
+type gltfDocument struct {
+	Asset struct {
+		Version string `json:"version"`
+	} `json:"asset"`
+	Scene  *int `json:"scene"`
+	Scenes []struct {
+		Nodes []int `json:"nodes"`
+	} `json:"scenes"`
+	Nodes       []gltfNode       `json:"nodes"`
+	Meshes      []gltfMesh       `json:"meshes"`
+	Materials   []gltfMaterial   `json:"materials"`
+	Textures    []gltfTexture    `json:"textures"`
+	Images      []gltfImage      `json:"images"`
+	Accessors   []gltfAccessor   `json:"accessors"`
+	BufferViews []gltfBufferView `json:"bufferViews"`
+	Buffers     []gltfBuffer     `json:"buffers"`
+	Skins       []gltfSkin       `json:"skins"`
+	Animations  []gltfAnimation  `json:"animations"`
+}
+
+type gltfNode struct {
+	Name        string    `json:"name"`
+	Children    []int     `json:"children"`
+	Matrix      []float32 `json:"matrix"`
+	Translation []float32 `json:"translation"`
+	Rotation    []float32 `json:"rotation"`
+	Scale       []float32 `json:"scale"`
+	Mesh        *int      `json:"mesh"`
+	Skin        *int      `json:"skin"`
+}
+
+type gltfMesh struct {
+	Name       string `json:"name"`
+	Primitives []struct {
+		Attributes map[string]int `json:"attributes"`
+		Indices    *int           `json:"indices"`
+		Material   *int           `json:"material"`
+		Mode       *int           `json:"mode"`
+	} `json:"primitives"`
+}
+
+type gltfTextureInfo struct {
+	Index int `json:"index"`
+}
+
+type gltfMaterial struct {
+	Name                 string `json:"name"`
+	PBRMetallicRoughness struct {
+		BaseColorFactor  []float32        `json:"baseColorFactor"`
+		BaseColorTexture *gltfTextureInfo `json:"baseColorTexture"`
+		RoughnessFactor  *float32         `json:"roughnessFactor"`
+	} `json:"pbrMetallicRoughness"`
+	NormalTexture *gltfTextureInfo `json:"normalTexture"`
+}
+
+type gltfTexture struct {
+	Source *int `json:"source"`
+}
+
+type gltfImage struct {
+	URI        string `json:"uri"`
+	BufferView *int   `json:"bufferView"`
+	MimeType   string `json:"mimeType"`
+}
+
+type gltfAccessor struct {
+	BufferView    *int            `json:"bufferView"`
+	ByteOffset    int             `json:"byteOffset"`
+	ComponentType int             `json:"componentType"`
+	Normalized    bool            `json:"normalized"`
+	Count         int             `json:"count"`
+	Type          string          `json:"type"`
+	Sparse        json.RawMessage `json:"sparse"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	ByteStride int `json:"byteStride"`
+}
+
+type gltfBuffer struct {
+	URI        string `json:"uri"`
+	ByteLength int    `json:"byteLength"`
+}
+
+type gltfSkin struct {
+	Name                string `json:"name"`
+	Joints              []int  `json:"joints"`
+	InverseBindMatrices *int   `json:"inverseBindMatrices"`
+	Skeleton            *int   `json:"skeleton"`
+}
+
+type gltfAnimation struct {
+	Name     string `json:"name"`
+	Channels []struct {
+		Sampler int `json:"sampler"`
+		Target  struct {
+			Node *int   `json:"node"`
+			Path string `json:"path"`
+		} `json:"target"`
+	} `json:"channels"`
+	Samplers []struct {
+		Input         int    `json:"input"`
+		Output        int    `json:"output"`
+		Interpolation string `json:"interpolation"`
+	} `json:"samplers"`
+}
+
+// gltfReader holds a document while it is turned into a Scene.
+type gltfReader struct {
+	document  gltfDocument
+	buffers   [][]byte
+	directory string
+}
+
+// LoadGLTFFile loads a .gltf or .glb model relative to resource/models.
+// External buffers and images are looked up next to it.
+func LoadGLTFFile(fileName string) (*Scene, error) {
+	return loadGLTFFile(modelDirectory, fileName)
+}
+
+func loadGLTFFile(modelDirectory, fileName string) (*Scene, error) {
+	data, err := ioutil.ReadFile(filepath.Join(modelDirectory, fileName))
+	if err != nil {
+		return nil, err
+	}
+	directory := filepath.Dir(filepath.Join(modelDirectory, fileName))
+	var scene *Scene
+	if strings.ToLower(filepath.Ext(fileName)) == ".glb" {
+		scene, err = ParseGLB(data, directory)
+	} else {
+		scene, err = ParseGLTF(data, nil, directory)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", fileName, err)
+	}
+	return scene, nil
+}
+
+// ParseGLB splits a binary glTF into its JSON and BIN chunks.
+func ParseGLB(data []byte, directory string) (*Scene, error) {
+	if len(data) < 12 || binary.LittleEndian.Uint32(data) != glbMagic {
+		return nil, fmt.Errorf("not a binary glTF file")
+	}
+	if version := binary.LittleEndian.Uint32(data[4:]); version != 2 {
+		return nil, fmt.Errorf("unsupported glTF version %v", version)
+	}
+	var jsonChunk, binChunk []byte
+	for offset := 12; offset+8 <= len(data); {
+		length := int(binary.LittleEndian.Uint32(data[offset:]))
+		chunkType := binary.LittleEndian.Uint32(data[offset+4:])
+		if offset+8+length > len(data) {
+			return nil, fmt.Errorf("chunk at %v runs past the end of the file", offset)
+		}
+		chunk := data[offset+8 : offset+8+length]
+		switch chunkType {
+		case glbJSONChunk:
+			jsonChunk = chunk
+		case glbBinChunk:
+			binChunk = chunk
+		}
+		offset += 8 + length
+	}
+	if jsonChunk == nil {
+		return nil, fmt.Errorf("missing JSON chunk")
+	}
+	return ParseGLTF(jsonChunk, binChunk, directory)
+}
+
+// ParseGLTF reads a glTF JSON document. bin is the BIN chunk of a .glb, used
+// for the buffer without a uri.
+func ParseGLTF(data, bin []byte, directory string) (*Scene, error) {
+	reader := &gltfReader{directory: directory}
+	if err := json.Unmarshal(data, &reader.document); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(reader.document.Asset.Version, "2.") {
+		return nil, fmt.Errorf("unsupported glTF version %q", reader.document.Asset.Version)
+	}
+	for i, buffer := range reader.document.Buffers {
+		var content []byte
+		var err error
+		if buffer.URI == "" {
+			if bin == nil {
+				return nil, fmt.Errorf("buffer %v has no uri", i)
+			}
+			content = bin
+		} else if content, err = reader.readURI(buffer.URI); err != nil {
+			return nil, err
+		}
+		if len(content) < buffer.ByteLength {
+			return nil, fmt.Errorf("buffer %v is %v bytes, expected %v", i, len(content), buffer.ByteLength)
+		}
+		reader.buffers = append(reader.buffers, content)
+	}
+
+	scene := &Scene{}
+	var err error
+	if scene.Nodes, err = reader.readNodes(); err != nil {
+		return nil, err
+	}
+	if scene.Mesh, err = reader.readMeshes(scene.Nodes); err != nil {
+		return nil, err
+	}
+	if scene.Skins, err = reader.readSkins(); err != nil {
+		return nil, err
+	}
+	if scene.Animations, err = reader.readAnimations(); err != nil {
+		return nil, err
+	}
+	return scene, nil
+}
+
+func (reader *gltfReader) readNodes() ([]Node, error) {
+	nodes := make([]Node, len(reader.document.Nodes))
+	for i, source := range reader.document.Nodes {
+		node := Node{
+			Name:        source.Name,
+			Parent:      -1,
+			Children:    source.Children,
+			Translation: mgl32.Vec3{0, 0, 0},
+			Rotation:    mgl32.QuatIdent(),
+			Scale:       mgl32.Vec3{1, 1, 1},
+			Mesh:        -1,
+			Skin:        -1,
+		}
+		if len(source.Translation) == 3 {
+			node.Translation = mgl32.Vec3{source.Translation[0], source.Translation[1], source.Translation[2]}
+		}
+		if len(source.Rotation) == 4 {
+			node.Rotation = mgl32.Quat{W: source.Rotation[3], V: mgl32.Vec3{source.Rotation[0], source.Rotation[1], source.Rotation[2]}}
+		}
+		if len(source.Scale) == 3 {
+			node.Scale = mgl32.Vec3{source.Scale[0], source.Scale[1], source.Scale[2]}
+		}
+		if len(source.Matrix) == 16 {
+			copy(node.Local[:], source.Matrix)
+		} else {
+			node.Local = LocalTransform(node.Translation, node.Rotation, node.Scale)
+		}
+		if source.Mesh != nil {
+			node.Mesh = *source.Mesh
+		}
+		if source.Skin != nil {
+			node.Skin = *source.Skin
+		}
+		nodes[i] = node
+	}
+
+	for i, node := range nodes {
+		for _, child := range node.Children {
+			if child < 0 || child >= len(nodes) {
+				return nil, fmt.Errorf("node %v has missing child %v", i, child)
+			}
+			if nodes[child].Parent >= 0 {
+				return nil, fmt.Errorf("node %v has more than one parent", child)
+			}
+			nodes[child].Parent = i
+		}
+	}
+	// Every node has one parent at most, so a node more ancestors up than
+	// there are nodes is in a cycle
+	for i := range nodes {
+		ancestor := nodes[i].Parent
+		for depth := 0; ancestor >= 0; depth++ {
+			if ancestor == i || depth >= len(nodes) {
+				return nil, fmt.Errorf("node %v is its own ancestor", i)
+			}
+			ancestor = nodes[ancestor].Parent
+		}
+	}
+	for i := range nodes {
+		if nodes[i].Parent < 0 {
+			updateWorld(nodes, i, mgl32.Ident4())
+		}
+	}
+	return nodes, nil
+}
+
+// LocalTransform builds a node matrix from its translation, rotation and
+// scale, applied in the order glTF defines: scale, rotate, translate.
+func LocalTransform(translation mgl32.Vec3, rotation mgl32.Quat, scale mgl32.Vec3) mgl32.Mat4 {
+	return mgl32.Translate3D(translation[0], translation[1], translation[2]).
+		Mul4(rotation.Mat4()).
+		Mul4(mgl32.Scale3D(scale[0], scale[1], scale[2]))
+}
+
+func updateWorld(nodes []Node, node int, parent mgl32.Mat4) {
+	nodes[node].World = parent.Mul4(nodes[node].Local)
+	for _, child := range nodes[node].Children {
+		updateWorld(nodes, child, nodes[node].World)
+	}
+}
+
+// sceneNodes returns the nodes of the default scene in hierarchy order, or
+// every node when the file has no scenes. Scene nodes that are not roots are
+// skipped, they are reached through their parent.
+func (reader *gltfReader) sceneNodes(nodes []Node) []int {
+	roots := []int{}
+	document := reader.document
+	if len(document.Scenes) > 0 {
+		scene := 0
+		if document.Scene != nil && *document.Scene < len(document.Scenes) {
+			scene = *document.Scene
+		}
+		roots = document.Scenes[scene].Nodes
+	} else {
+		for i, node := range nodes {
+			if node.Parent < 0 {
+				roots = append(roots, i)
+			}
+		}
+	}
+
+	order := []int{}
+	var visit func(int)
+	visit = func(node int) {
+		order = append(order, node)
+		for _, child := range nodes[node].Children {
+			visit(child)
+		}
+	}
+	for _, root := range roots {
+		if root >= 0 && root < len(nodes) && nodes[root].Parent < 0 {
+			visit(root)
+		}
+	}
+	return order
+}
+
+func (reader *gltfReader) readMeshes(nodes []Node) (*IndexedMesh, error) {
+	mesh := &IndexedMesh{Materials: map[string]*Material{}}
+	skinned := false
+	for _, node := range reader.sceneNodes(nodes) {
+		if nodes[node].Mesh < 0 {
+			continue
+		}
+		if nodes[node].Mesh >= len(reader.document.Meshes) {
+			return nil, fmt.Errorf("node %v has missing mesh %v", node, nodes[node].Mesh)
+		}
+		source := reader.document.Meshes[nodes[node].Mesh]
+		for p, primitive := range source.Primitives {
+			if primitive.Mode != nil && *primitive.Mode != trianglesMode {
+				return nil, fmt.Errorf("mesh %v primitive %v: only triangles are supported", nodes[node].Mesh, p)
+			}
+			position, ok := primitive.Attributes["POSITION"]
+			if !ok {
+				return nil, fmt.Errorf("mesh %v primitive %v has no positions", nodes[node].Mesh, p)
+			}
+			positions, err := reader.readFloats(position, "VEC3")
+			if err != nil {
+				return nil, err
+			}
+			count := len(positions) / 3
+			normals, err := reader.readOptional(primitive.Attributes, "NORMAL", "VEC3", count)
+			if err != nil {
+				return nil, err
+			}
+			uv, err := reader.readOptional(primitive.Attributes, "TEXCOORD_0", "VEC2", count)
+			if err != nil {
+				return nil, err
+			}
+			joints, err := reader.readOptional(primitive.Attributes, "JOINTS_0", "VEC4", count)
+			if err != nil {
+				return nil, err
+			}
+			weights, err := reader.readOptional(primitive.Attributes, "WEIGHTS_0", "VEC4", count)
+			if err != nil {
+				return nil, err
+			}
+			_, hasJoints := primitive.Attributes["JOINTS_0"]
+			skinned = skinned || hasJoints
+
+			// Skinned vertices are placed by their joints, not by the node
+			if nodes[node].Skin < 0 {
+				world := nodes[node].World
+				normalMatrix := world.Mat3().Inv().Transpose()
+				for i := 0; i < count; i++ {
+					moved := world.Mul4x1(mgl32.Vec4{positions[i*3], positions[(i*3)+1], positions[(i*3)+2], 1})
+					copy(positions[i*3:], moved[:3])
+					if _, ok := primitive.Attributes["NORMAL"]; ok {
+						turned := normalMatrix.Mul3x1(mgl32.Vec3{normals[i*3], normals[(i*3)+1], normals[(i*3)+2]}).Normalize()
+						copy(normals[i*3:], turned[:])
+					}
+				}
+			}
+
+			var indices []uint32
+			if primitive.Indices != nil {
+				if indices, err = reader.readIndices(*primitive.Indices, count); err != nil {
+					return nil, err
+				}
+			} else {
+				for i := 0; i < count; i++ {
+					indices = append(indices, uint32(i))
+				}
+			}
+			if _, ok := primitive.Attributes["NORMAL"]; !ok {
+				smoothNormals(positions, indices, normals)
+			}
+
+			base := uint32(mesh.VertexCount())
+			group := Group{Name: source.Name, First: len(mesh.Indices), Count: len(indices)}
+			if primitive.Material != nil {
+				if group.Material, err = reader.readMaterial(*primitive.Material, mesh.Materials); err != nil {
+					return nil, err
+				}
+			}
+			for _, index := range indices {
+				mesh.Indices = append(mesh.Indices, base+index)
+			}
+			mesh.Vertices = append(mesh.Vertices, positions...)
+			mesh.Normals = append(mesh.Normals, normals...)
+			mesh.UV = append(mesh.UV, uv...)
+			mesh.Joints = append(mesh.Joints, joints...)
+			mesh.Weights = append(mesh.Weights, weights...)
+			mesh.Groups = append(mesh.Groups, group)
+		}
+	}
+	if !skinned {
+		mesh.Joints, mesh.Weights = nil, nil
+	}
+	return mesh, nil
+}
+
+// readOptional reads a vertex attribute, or zeros for every vertex when the
+// primitive does not have it.
+func (reader *gltfReader) readOptional(attributes map[string]int, name, accessorType string, count int) ([]float32, error) {
+	accessor, ok := attributes[name]
+	if !ok {
+		return make([]float32, count*componentCount(accessorType)), nil
+	}
+	values, err := reader.readFloats(accessor, accessorType)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != count*componentCount(accessorType) {
+		return nil, fmt.Errorf("%v has %v values for %v vertices", name, len(values)/componentCount(accessorType), count)
+	}
+	return values, nil
+}
+
+// readMaterial adds a material to materials under its name, naming unnamed
+// ones after their index, and returns the name.
+func (reader *gltfReader) readMaterial(index int, materials map[string]*Material) (string, error) {
+	if index < 0 || index >= len(reader.document.Materials) {
+		return "", fmt.Errorf("missing material %v", index)
+	}
+	source := reader.document.Materials[index]
+	name := source.Name
+	if name == "" {
+		name = fmt.Sprintf("material%v", index)
+	}
+	if _, ok := materials[name]; ok {
+		return name, nil
+	}
+
+	material := newMaterial(name)
+	pbr := source.PBRMetallicRoughness
+	if len(pbr.BaseColorFactor) == 4 {
+		copy(material.Diffuse[:], pbr.BaseColorFactor[:3])
+		material.Opacity = pbr.BaseColorFactor[3]
+	}
+	if pbr.RoughnessFactor != nil {
+		// A rough guess at a Phong exponent, rough surfaces get wide dull highlights
+		roughness := *pbr.RoughnessFactor
+		material.Shininess = (1 - roughness) * 128
+		material.Specular = [3]float32{1 - roughness, 1 - roughness, 1 - roughness}
+	}
+	var err error
+	if pbr.BaseColorTexture != nil {
+		if material.DiffuseMap, material.DiffuseImage, err = reader.readTexture(pbr.BaseColorTexture.Index); err != nil {
+			return "", err
+		}
+	}
+	if source.NormalTexture != nil {
+		// Only an external normal map has a path to keep
+		if material.BumpMap, _, err = reader.readTexture(source.NormalTexture.Index); err != nil {
+			return "", err
+		}
+	}
+	materials[name] = material
+	return name, nil
+}
+
+// readTexture returns the file of an external image, or the decoded image
+// when it is embedded in a buffer or data uri.
+func (reader *gltfReader) readTexture(index int) (string, image.Image, error) {
+	document := reader.document
+	if index < 0 || index >= len(document.Textures) || document.Textures[index].Source == nil {
+		return "", nil, fmt.Errorf("missing texture %v", index)
+	}
+	source := *document.Textures[index].Source
+	if source < 0 || source >= len(document.Images) {
+		return "", nil, fmt.Errorf("texture %v has missing image %v", index, source)
+	}
+	img := document.Images[source]
+
+	var data []byte
+	var err error
+	switch {
+	case img.BufferView != nil:
+		data, err = reader.bufferView(*img.BufferView)
+	case strings.HasPrefix(img.URI, "data:"):
+		data, err = reader.readURI(img.URI)
+	default:
+		return filepath.ToSlash(filepath.Join(reader.directory, img.URI)), nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", nil, fmt.Errorf("image %v: %v", source, err)
+	}
+	return "", decoded, nil
+}
+
+func (reader *gltfReader) readSkins() ([]Skin, error) {
+	skins := []Skin{}
+	for i, source := range reader.document.Skins {
+		skin := Skin{Name: source.Name, Joints: source.Joints, Skeleton: -1}
+		for _, joint := range skin.Joints {
+			if joint < 0 || joint >= len(reader.document.Nodes) {
+				return nil, fmt.Errorf("skin %v has missing joint node %v", i, joint)
+			}
+		}
+		if source.Skeleton != nil {
+			skin.Skeleton = *source.Skeleton
+			if skin.Skeleton < 0 || skin.Skeleton >= len(reader.document.Nodes) {
+				return nil, fmt.Errorf("skin %v has missing skeleton node %v", i, skin.Skeleton)
+			}
+		}
+		if source.InverseBindMatrices != nil {
+			values, err := reader.readFloats(*source.InverseBindMatrices, "MAT4")
+			if err != nil {
+				return nil, err
+			}
+			for m := 0; m+16 <= len(values); m += 16 {
+				var matrix mgl32.Mat4
+				copy(matrix[:], values[m:m+16])
+				skin.InverseBindMatrices = append(skin.InverseBindMatrices, matrix)
+			}
+		} else {
+			for range skin.Joints {
+				skin.InverseBindMatrices = append(skin.InverseBindMatrices, mgl32.Ident4())
+			}
+		}
+		if len(skin.InverseBindMatrices) != len(skin.Joints) {
+			return nil, fmt.Errorf("skin %v has %v joints but %v inverse bind matrices", i, len(skin.Joints), len(skin.InverseBindMatrices))
+		}
+		skins = append(skins, skin)
+	}
+	return skins, nil
+}
+
+func (reader *gltfReader) readAnimations() ([]Animation, error) {
+	animations := []Animation{}
+	for i, source := range reader.document.Animations {
+		animation := Animation{Name: source.Name}
+		for c, channel := range source.Channels {
+			if channel.Target.Node == nil {
+				continue
+			}
+			if node := *channel.Target.Node; node < 0 || node >= len(reader.document.Nodes) {
+				return nil, fmt.Errorf("animation %v channel %v targets missing node %v", i, c, node)
+			}
+			if channel.Sampler < 0 || channel.Sampler >= len(source.Samplers) {
+				return nil, fmt.Errorf("animation %v channel %v has missing sampler %v", i, c, channel.Sampler)
+			}
+			sampler := source.Samplers[channel.Sampler]
+			times, err := reader.readFloats(sampler.Input, "SCALAR")
+			if err != nil {
+				return nil, err
+			}
+			values, err := reader.readFloats(sampler.Output, "")
+			if err != nil {
+				return nil, err
+			}
+			interpolation := sampler.Interpolation
+			if interpolation == "" {
+				interpolation = "LINEAR"
+			}
+			animation.Channels = append(animation.Channels, Channel{
+				Node:          *channel.Target.Node,
+				Path:          channel.Target.Path,
+				Interpolation: interpolation,
+				Times:         times,
+				Values:        values,
+			})
+		}
+		animations = append(animations, animation)
+	}
+	return animations, nil
+}
+
+// readFloats reads an accessor as floats, converting integer components and
+// normalising them when the accessor says so. An empty accessorType accepts
+// any type.
+func (reader *gltfReader) readFloats(index int, accessorType string) ([]float32, error) {
+	accessor, data, stride, err := reader.accessor(index)
+	if err != nil {
+		return nil, err
+	}
+	if accessorType != "" && accessor.Type != accessorType {
+		return nil, fmt.Errorf("accessor %v is %v, expected %v", index, accessor.Type, accessorType)
+	}
+	components := componentCount(accessor.Type)
+	size := componentSize(accessor.ComponentType)
+	values := make([]float32, 0, accessor.Count*components)
+	for i := 0; i < accessor.Count; i++ {
+		for c := 0; c < components; c++ {
+			values = append(values, readComponent(data[(i*stride)+(c*size):], accessor.ComponentType, accessor.Normalized))
+		}
+	}
+	return values, nil
+}
+
+func (reader *gltfReader) readIndices(index, vertexCount int) ([]uint32, error) {
+	accessor, data, stride, err := reader.accessor(index)
+	if err != nil {
+		return nil, err
+	}
+	if accessor.Type != "SCALAR" {
+		return nil, fmt.Errorf("index accessor %v is %v", index, accessor.Type)
+	}
+	indices := make([]uint32, accessor.Count)
+	for i := range indices {
+		switch accessor.ComponentType {
+		case 5121:
+			indices[i] = uint32(data[i*stride])
+		case 5123:
+			indices[i] = uint32(binary.LittleEndian.Uint16(data[i*stride:]))
+		case 5125:
+			indices[i] = binary.LittleEndian.Uint32(data[i*stride:])
+		default:
+			return nil, fmt.Errorf("index accessor %v has component type %v", index, accessor.ComponentType)
+		}
+		if int(indices[i]) >= vertexCount {
+			return nil, fmt.Errorf("index %v out of range, %v vertices", indices[i], vertexCount)
+		}
+	}
+	return indices, nil
+}
+
+// accessor returns an accessor with the bytes it starts at and the stride
+// between its elements, checking they all fit in the buffer view.
+func (reader *gltfReader) accessor(index int) (gltfAccessor, []byte, int, error) {
+	if index < 0 || index >= len(reader.document.Accessors) {
+		return gltfAccessor{}, nil, 0, fmt.Errorf("missing accessor %v", index)
+	}
+	accessor := reader.document.Accessors[index]
+	if accessor.Sparse != nil {
+		return accessor, nil, 0, fmt.Errorf("accessor %v is sparse, which is not supported", index)
+	}
+	components, size := componentCount(accessor.Type), componentSize(accessor.ComponentType)
+	if components == 0 || size == 0 {
+		return accessor, nil, 0, fmt.Errorf("accessor %v has unknown type %v/%v", index, accessor.Type, accessor.ComponentType)
+	}
+	if accessor.Count < 0 || accessor.Count > maxAccessorCount {
+		return accessor, nil, 0, fmt.Errorf("accessor %v count %v is outside 0 to %v", index, accessor.Count, maxAccessorCount)
+	}
+	if accessor.ByteOffset < 0 {
+		return accessor, nil, 0, fmt.Errorf("accessor %v has negative byte offset %v", index, accessor.ByteOffset)
+	}
+	elementSize := components * size
+	if accessor.BufferView == nil {
+		// Accessors without a buffer view are all zeros
+		return accessor, make([]byte, accessor.Count*elementSize), elementSize, nil
+	}
+
+	view, err := reader.bufferView(*accessor.BufferView)
+	if err != nil {
+		return accessor, nil, 0, err
+	}
+	stride := reader.document.BufferViews[*accessor.BufferView].ByteStride
+	if stride < 0 || stride > maxByteStride {
+		return accessor, nil, 0, fmt.Errorf("buffer view %v stride %v is outside 0 to %v", *accessor.BufferView, stride, maxByteStride)
+	}
+	if stride == 0 {
+		stride = elementSize
+	}
+	if accessor.ByteOffset > len(view) || (accessor.Count > 0 && accessor.ByteOffset+((accessor.Count-1)*stride)+elementSize > len(view)) {
+		return accessor, nil, 0, fmt.Errorf("accessor %v runs past its buffer view", index)
+	}
+	return accessor, view[accessor.ByteOffset:], stride, nil
+}
+
+func (reader *gltfReader) bufferView(index int) ([]byte, error) {
+	if index < 0 || index >= len(reader.document.BufferViews) {
+		return nil, fmt.Errorf("missing buffer view %v", index)
+	}
+	view := reader.document.BufferViews[index]
+	if view.Buffer < 0 || view.Buffer >= len(reader.buffers) {
+		return nil, fmt.Errorf("buffer view %v has missing buffer %v", index, view.Buffer)
+	}
+	buffer := reader.buffers[view.Buffer]
+	if view.ByteOffset < 0 || view.ByteLength < 0 {
+		return nil, fmt.Errorf("buffer view %v has negative offset %v or length %v", index, view.ByteOffset, view.ByteLength)
+	}
+	if view.ByteOffset > len(buffer) || view.ByteLength > len(buffer)-view.ByteOffset {
+		return nil, fmt.Errorf("buffer view %v runs past its buffer", index)
+	}
+	return buffer[view.ByteOffset : view.ByteOffset+view.ByteLength], nil
+}
+
+// readURI reads base64 data uris directly and anything else as a file next
+// to the model.
+func (reader *gltfReader) readURI(uri string) ([]byte, error) {
+	if strings.HasPrefix(uri, "data:") {
+		comma := strings.Index(uri, ",")
+		if comma < 0 || !strings.HasSuffix(uri[:comma], ";base64") {
+			return nil, fmt.Errorf("unsupported data uri")
+		}
+		return base64.StdEncoding.DecodeString(uri[comma+1:])
+	}
+	return ioutil.ReadFile(filepath.Join(reader.directory, uri))
+}
+
+func readComponent(data []byte, componentType int, normalized bool) float32 {
+	switch componentType {
+	case 5120:
+		value := float32(int8(data[0]))
+		if normalized {
+			return maxFloat(value/127, -1)
+		}
+		return value
+	case 5121:
+		if normalized {
+			return float32(data[0]) / 255
+		}
+		return float32(data[0])
+	case 5122:
+		value := float32(int16(binary.LittleEndian.Uint16(data)))
+		if normalized {
+			return maxFloat(value/32767, -1)
+		}
+		return value
+	case 5123:
+		value := float32(binary.LittleEndian.Uint16(data))
+		if normalized {
+			return value / 65535
+		}
+		return value
+	case 5125:
+		return float32(binary.LittleEndian.Uint32(data))
+	default:
+		return m.Float32frombits(binary.LittleEndian.Uint32(data))
+	}
+}
+
+// smoothNormals sets each vertex normal to the average of the faces around
+// it, for primitives that come without normals.
+func smoothNormals(positions []float32, indices []uint32, normals []float32) {
+	for t := 0; t+2 < len(indices); t += 3 {
+		var corners [3][3]float32
+		for k := range corners {
+			copy(corners[k][:], positions[indices[t+k]*3:])
+		}
+		normal := faceNormal(corners[0], corners[1], corners[2])
+		for k := 0; k < 3; k++ {
+			for c := 0; c < 3; c++ {
+				normals[(indices[t+k]*3)+uint32(c)] += normal[c]
+			}
+		}
+	}
+	for i := 0; i+2 < len(normals); i += 3 {
+		normal := mgl32.Vec3{normals[i], normals[i+1], normals[i+2]}
+		if normal.Len() > 0 {
+			normal = normal.Normalize()
+		}
+		copy(normals[i:], normal[:])
+	}
+}
+
+func componentCount(accessorType string) int {
+	switch accessorType {
+	case "SCALAR":
+		return 1
+	case "VEC2":
+		return 2
+	case "VEC3":
+		return 3
+	case "VEC4", "MAT2":
+		return 4
+	case "MAT3":
+		return 9
+	case "MAT4":
+		return 16
+	}
+	return 0
+}
+
+func componentSize(componentType int) int {
+	switch componentType {
+	case 5120, 5121:
+		return 1
+	case 5122, 5123:
+		return 2
+	case 5125, 5126:
+		return 4
+	}
+	return 0
+}
+
+func maxFloat(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
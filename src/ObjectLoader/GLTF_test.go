@@ -0,0 +1,188 @@
+package ObjectLoader
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func loadTestGLTF(t *testing.T, file string) *Scene {
+	t.Helper()
+	scene, err := loadGLTFFile(testDirectory, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return scene
+}
+
+func TestGLTFNodeHierarchy(t *testing.T) {
+	scene := loadTestGLTF(t, "hierarchy.gltf")
+	if len(scene.Nodes) != 3 {
+		t.Fatalf("loaded %v nodes, want 3", len(scene.Nodes))
+	}
+	for i, parent := range []int{-1, 0, 1} {
+		if scene.Nodes[i].Parent != parent {
+			t.Errorf("node %v has parent %v, want %v", scene.Nodes[i].Name, scene.Nodes[i].Parent, parent)
+		}
+	}
+	hand := scene.Nodes[2].World.Mul4x1(mgl32.Vec4{0, 0, 0, 1})
+	if !equalFloats(hand[:], []float32{-1, 0, 0, 1}) {
+		t.Errorf("hand origin at %v, want -1 0 0", hand)
+	}
+
+	// The mesh is moved into place by the hand's world transform
+	mesh := scene.Mesh
+	want := []float32{-1, 0, 0, -1, 2, 0, -3, 0, 0}
+	if !equalFloats(mesh.Vertices, want) {
+		t.Errorf("vertices %v, want %v", mesh.Vertices, want)
+	}
+	if !equalFloats(mesh.Normals[:3], []float32{0, 0, 1}) {
+		t.Errorf("generated normal %v, want 0 0 1", mesh.Normals[:3])
+	}
+	if !equalFloats(mesh.UV, []float32{0, 0, 1, 0, 0, 1}) {
+		t.Errorf("uv %v", mesh.UV)
+	}
+	if len(mesh.Indices) != 3 || len(mesh.Groups) != 1 || mesh.Groups[0] != (Group{"palm", "skin", 0, 3}) {
+		t.Errorf("indices %v groups %+v", mesh.Indices, mesh.Groups)
+	}
+	if mesh.Joints != nil {
+		t.Error("unskinned mesh has joints")
+	}
+}
+
+func TestGLTFTexturedMaterial(t *testing.T) {
+	material := loadTestGLTF(t, "hierarchy.gltf").Mesh.Materials["skin"]
+	if material == nil {
+		t.Fatal("material skin not loaded")
+	}
+	if material.Diffuse != [3]float32{1, 0.5, 0.25} || material.Opacity != 0.75 || material.Shininess != 64 {
+		t.Errorf("material %+v", material)
+	}
+	if material.DiffuseMap != "testdata/checker.png" || material.DiffuseImage != nil {
+		t.Errorf("external texture read as %q, image %v", material.DiffuseMap, material.DiffuseImage)
+	}
+	if material.BumpMap != "testdata/checker.png" {
+		t.Errorf("normal map read as %q", material.BumpMap)
+	}
+
+	// The .glb embeds its texture in the BIN chunk
+	embedded := loadTestGLTF(t, "skinned.glb").Mesh.Materials["material0"]
+	if embedded == nil || embedded.DiffuseImage == nil {
+		t.Fatal("embedded texture not decoded")
+	}
+	if size := embedded.DiffuseImage.Bounds().Size(); size.X != 2 || size.Y != 2 {
+		t.Errorf("embedded texture is %v, want 2x2", size)
+	}
+	if got := color.RGBAModel.Convert(embedded.DiffuseImage.At(1, 0)).(color.RGBA); got != (color.RGBA{40, 40, 200, 255}) {
+		t.Errorf("embedded texel %v, want blue", got)
+	}
+}
+
+func TestGLBSkin(t *testing.T) {
+	scene := loadTestGLTF(t, "skinned.glb")
+	if len(scene.Skins) != 1 {
+		t.Fatalf("loaded %v skins, want 1", len(scene.Skins))
+	}
+	skin := scene.Skins[0]
+	if skin.Name != "rig" || len(skin.Joints) != 2 || skin.Joints[1] != 1 || skin.Skeleton != 0 {
+		t.Errorf("skin %+v", skin)
+	}
+	if translation := skin.InverseBindMatrices[1].Col(3); translation != (mgl32.Vec4{0, -1, 0, 1}) {
+		t.Errorf("second inverse bind matrix translates by %v, want 0 -1 0", translation)
+	}
+
+	mesh := scene.Mesh
+	if mesh.VertexCount() != 6 || len(mesh.Indices) != 12 {
+		t.Fatalf("mesh has %v vertices and %v indices, want 6 and 12", mesh.VertexCount(), len(mesh.Indices))
+	}
+	// Skinned vertices stay in bind pose
+	if !equalFloats(mesh.Vertices[12:15], []float32{0, 2, 0}) {
+		t.Errorf("fifth vertex at %v, want 0 2 0", mesh.Vertices[12:15])
+	}
+	if !equalFloats(mesh.Joints[:4], []float32{0, 1, 0, 0}) || !equalFloats(mesh.Weights[8:12], []float32{0.5, 0.5, 0, 0}) {
+		t.Errorf("joints %v weights %v", mesh.Joints[:4], mesh.Weights[8:12])
+	}
+}
+
+func TestGLBAnimation(t *testing.T) {
+	scene := loadTestGLTF(t, "skinned.glb")
+	if len(scene.Animations) != 1 || scene.Animations[0].Name != "bend" {
+		t.Fatalf("animations %+v", scene.Animations)
+	}
+	channels := scene.Animations[0].Channels
+	if len(channels) != 2 {
+		t.Fatalf("loaded %v channels, want 2", len(channels))
+	}
+	rotation, translation := channels[0], channels[1]
+	if rotation.Node != 1 || rotation.Path != "rotation" || rotation.Interpolation != "LINEAR" {
+		t.Errorf("rotation channel %+v", rotation)
+	}
+	if !equalFloats(rotation.Times, []float32{0, 1}) || len(rotation.Values) != 8 {
+		t.Errorf("rotation keys %v %v", rotation.Times, rotation.Values)
+	}
+	if translation.Node != 0 || translation.Path != "translation" || translation.Interpolation != "STEP" || len(translation.Values) != 9 {
+		t.Errorf("translation channel %+v", translation)
+	}
+}
+
+func TestGLTFBadHierarchy(t *testing.T) {
+	tests := []struct {
+		name, nodes, err string
+	}{
+		{"cycle", `[{"children": [1]}, {"children": [0]}]`, "own ancestor"},
+		{"own child", `[{"children": [0]}]`, "own ancestor"},
+		{"longer cycle", `[{"children": [1]}, {"children": [2]}, {"children": [1]}]`, "more than one parent"},
+		{"detached cycle", `[{}, {"children": [2]}, {"children": [3]}, {"children": [1]}]`, "own ancestor"},
+		{"two parents", `[{"children": [2]}, {"children": [2]}, {}]`, "more than one parent"},
+		{"missing child", `[{"children": [4]}]`, "missing child"},
+	}
+	for _, test := range tests {
+		document := `{"asset": {"version": "2.0"}, "scenes": [{"nodes": [0]}], "nodes": ` + test.nodes + `}`
+		_, err := ParseGLTF([]byte(document), nil, testDirectory)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%v: ParseGLTF returned %v, want an error about %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestGLTFBadAccessors(t *testing.T) {
+	const (
+		view     = `{"buffer": 0, "byteLength": 12}`
+		accessor = `{"bufferView": 0, "componentType": 5126, "count": 1, "type": "VEC3"}`
+	)
+	tests := []struct {
+		name, view, accessor, extra, err string
+	}{
+		{"negative accessor offset", view, `{"bufferView": 0, "byteOffset": -4, "componentType": 5126, "count": 1, "type": "VEC3"}`, "", "negative byte offset"},
+		{"accessor offset past the view", view, `{"bufferView": 0, "byteOffset": 9223372036854775800, "componentType": 5126, "count": 1, "type": "VEC3"}`, "", "runs past its buffer view"},
+		{"negative count", view, `{"bufferView": 0, "componentType": 5126, "count": -1, "type": "VEC3"}`, "", "count -1"},
+		{"negative count without a view", view, `{"componentType": 5126, "count": -1, "type": "VEC3"}`, "", "count -1"},
+		{"huge count without a view", view, `{"componentType": 5126, "count": 1000000000000, "type": "VEC3"}`, "", "count 1000000000000"},
+		{"negative view offset", `{"buffer": 0, "byteOffset": -4, "byteLength": 12}`, accessor, "", "negative offset"},
+		{"negative view length", `{"buffer": 0, "byteLength": -12}`, accessor, "", "negative offset"},
+		{"view length past the buffer", `{"buffer": 0, "byteOffset": 4, "byteLength": 9223372036854775807}`, accessor, "", "runs past its buffer"},
+		{"negative stride", `{"buffer": 0, "byteLength": 12, "byteStride": -12}`, accessor, "", "stride -12"},
+		{"missing joint", view, accessor, `, "skins": [{"joints": [0, 5]}]`, "missing joint node 5"},
+		{"negative joint", view, accessor, `, "skins": [{"joints": [-1]}]`, "missing joint node -1"},
+		{"missing skeleton", view, accessor, `, "skins": [{"joints": [0], "skeleton": 3}]`, "missing skeleton node 3"},
+		{"missing animated node", view, accessor, `, "animations": [{"channels": [{"sampler": 0, "target": {"node": 7, "path": "translation"}}], "samplers": [{"input": 0, "output": 0}]}]`, "missing node 7"},
+	}
+	for _, test := range tests {
+		document := `{"asset": {"version": "2.0"}, "scenes": [{"nodes": [0]}], "nodes": [{"mesh": 0}],
+			"meshes": [{"primitives": [{"attributes": {"POSITION": 0}}]}],
+			"buffers": [{"uri": "data:application/octet-stream;base64,AAAAAAAAAAAAAAAA", "byteLength": 12}],
+			"bufferViews": [` + test.view + `], "accessors": [` + test.accessor + `]` + test.extra + `}`
+		_, err := ParseGLTF([]byte(document), nil, testDirectory)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%v: ParseGLTF returned %v, want an error about %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestGLBTruncated(t *testing.T) {
+	if _, err := ParseGLB([]byte("glTF"), testDirectory); err == nil {
+		t.Error("a truncated GLB parsed")
+	}
+}
@@ -0,0 +1,75 @@
+package ObjectLoader
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+)
+
+// IndexedMesh holds each distinct vertex once, with triangles given as
+// indices into the vertex attributes. Groups count indices rather than
+// vertices. Skinned meshes also have four joints and weights per vertex.
+type IndexedMesh struct {
+	Vertices, Normals, UV []float32
+	Joints, Weights       []float32
+	Indices               []uint32
+	Groups                []Group
+	Materials             map[string]*Material
+}
+
+// LoadIndexedMesh loads an OBJ, glTF or GLB model relative to
+// resource/models, picking the loader by extension.
+func LoadIndexedMesh(fileName string) (*IndexedMesh, error) {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".obj":
+		mesh, err := LoadObjFile(fileName)
+		if err != nil {
+			return nil, err
+		}
+		return mesh.Indexed(), nil
+	case ".gltf", ".glb":
+		scene, err := LoadGLTFFile(fileName)
+		if err != nil {
+			return nil, err
+		}
+		return scene.Mesh, nil
+	}
+	return nil, fmt.Errorf("%v: unsupported model format", fileName)
+}
+
+// vertexKey is the bit pattern of every attribute of a vertex, so only
+// vertices that are exactly equal are welded.
+type vertexKey [8]uint32
+
+// Indexed welds the vertices of the mesh that share position, normal and
+// texture coordinate.
+func (mesh *Mesh) Indexed() *IndexedMesh {
+	indexed := &IndexedMesh{Groups: mesh.Groups, Materials: mesh.Materials}
+	welded := map[vertexKey]uint32{}
+	for i := 0; i < len(mesh.Vertices)/3; i++ {
+		position := mesh.Vertices[i*3 : (i*3)+3]
+		normal := mesh.Normals[i*3 : (i*3)+3]
+		uv := mesh.UV[i*2 : (i*2)+2]
+
+		var key vertexKey
+		for k, value := range append(append(append([]float32{}, position...), normal...), uv...) {
+			key[k] = math.Float32bits(value)
+		}
+		index, ok := welded[key]
+		if !ok {
+			index = uint32(len(indexed.Vertices) / 3)
+			welded[key] = index
+			indexed.Vertices = append(indexed.Vertices, position...)
+			indexed.Normals = append(indexed.Normals, normal...)
+			indexed.UV = append(indexed.UV, uv...)
+		}
+		indexed.Indices = append(indexed.Indices, index)
+	}
+	return indexed
+}
+
+// VertexCount returns the number of distinct vertices.
+func (mesh *IndexedMesh) VertexCount() int {
+	return len(mesh.Vertices) / 3
+}
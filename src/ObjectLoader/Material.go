@@ -0,0 +1,131 @@
+package ObjectLoader
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Material holds the MTL properties of a model part. Texture maps are
+// paths relative to the working directory, resolved from the directory of
+// the .mtl file that named them. Textures embedded in a glTF file are
+// decoded into DiffuseImage instead of DiffuseMap. No shader reads BumpMap
+// yet.
+type Material struct {
+	Name                       string
+	Ambient, Diffuse, Specular [3]float32
+	Shininess, Opacity         float32
+	DiffuseMap, BumpMap        string
+	DiffuseImage               image.Image
+}
+
+func newMaterial(name string) *Material {
+	return &Material{Name: name, Diffuse: [3]float32{1, 1, 1}, Opacity: 1}
+}
+
+// LoadMtlFile loads a material library, the path is used as it is.
+func LoadMtlFile(filePath string) (map[string]*Material, error) {
+	mtlFile, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer mtlFile.Close()
+	return ParseMtl(mtlFile, filePath)
+}
+
+// ParseMtl reads the materials of an MTL library. Texture map options such as
+// -s or -bm are skipped, the last field is taken as the file.
+func ParseMtl(reader io.Reader, filePath string) (map[string]*Material, error) {
+	materials := map[string]*Material{}
+	directory := filepath.Dir(filePath)
+	var current *Material
+
+	scanner := bufio.NewScanner(reader)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if comment := strings.Index(text, "#"); comment >= 0 {
+			text = text[:comment]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if fields[0] == "newmtl" {
+			if len(fields) < 2 {
+				return nil, &ParseError{filePath, line, fmt.Errorf("newmtl needs a material name")}
+			}
+			current = newMaterial(fields[1])
+			materials[current.Name] = current
+			continue
+		}
+		if current == nil {
+			return nil, &ParseError{filePath, line, fmt.Errorf("%v before newmtl", fields[0])}
+		}
+
+		var err error
+		switch strings.ToLower(fields[0]) {
+		case "ka":
+			err = parseColor(fields[1:], &current.Ambient)
+		case "kd":
+			err = parseColor(fields[1:], &current.Diffuse)
+		case "ks":
+			err = parseColor(fields[1:], &current.Specular)
+		case "ns":
+			err = parseScalar(fields[1:], &current.Shininess)
+		case "d":
+			err = parseScalar(fields[1:], &current.Opacity)
+		case "tr":
+			// Tr is the inverse of d, written by some exporters
+			if err = parseScalar(fields[1:], &current.Opacity); err == nil {
+				current.Opacity = 1 - current.Opacity
+			}
+		case "map_kd":
+			current.DiffuseMap, err = mapFile(fields[1:], directory)
+		case "map_bump", "bump":
+			current.BumpMap, err = mapFile(fields[1:], directory)
+		}
+		if err != nil {
+			return nil, &ParseError{filePath, line, err}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, &ParseError{filePath, line, err}
+	}
+	return materials, nil
+}
+
+func parseColor(fields []string, color *[3]float32) error {
+	values, err := parseFloats(fields, 1, 3)
+	if err != nil {
+		return err
+	}
+	if len(fields) < 3 {
+		// A single value is used for all three channels
+		values[1], values[2] = values[0], values[0]
+	}
+	copy(color[:], values)
+	return nil
+}
+
+func parseScalar(fields []string, value *float32) error {
+	values, err := parseFloats(fields, 1, 1)
+	if err != nil {
+		return err
+	}
+	*value = values[0]
+	return nil
+}
+
+func mapFile(fields []string, directory string) (string, error) {
+	if len(fields) == 0 {
+		return "", fmt.Errorf("texture map needs a file")
+	}
+	return filepath.ToSlash(filepath.Join(directory, fields[len(fields)-1])), nil
+}
@@ -2,80 +2,281 @@ package ObjectLoader
 
 import (
 	"bufio"
+	"fmt"
+	"io"
+	m "math"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
 
-func LoadObjFile(fileName string) ([]float32, []float32, []float32) {
+const modelDirectory string = "resource/models/"
 
-	objFile, err := os.Open("resource/models/" + fileName)
+// Mesh is a triangle list with one position, normal and texture coordinate
+// per vertex, ready to be uploaded as it is. Groups split the triangles by
+// the o/g and usemtl statements that preceded them.
+type Mesh struct {
+	Vertices, Normals, UV []float32
+	Groups                []Group
+	MaterialLibraries     []string
+	Materials             map[string]*Material
+}
+
+// Group is a run of Count vertices starting at First that share an object or
+// group name and a material.
+type Group struct {
+	Name, Material string
+	First, Count   int
+}
+
+// ParseError reports the file and line an OBJ statement failed on.
+type ParseError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (err *ParseError) Error() string {
+	return fmt.Sprintf("%v:%v: %v", err.File, err.Line, err.Err)
+}
+
+type faceVertex struct {
+	position, texture, normal int
+}
+
+type objParser struct {
+	mesh                       *Mesh
+	positions, textures, norms []float32
+	name, material             string
+}
+
+// LoadObjFile loads a model relative to resource/models along with the
+// material libraries it names, which are looked up next to it.
+func LoadObjFile(fileName string) (*Mesh, error) {
+	return loadObjFile(modelDirectory, fileName)
+}
+
+func loadObjFile(directory, fileName string) (*Mesh, error) {
+	objFile, err := os.Open(filepath.Join(directory, fileName))
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	defer objFile.Close()
+	mesh, err := ParseObj(objFile, fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, library := range mesh.MaterialLibraries {
+		materials, err := LoadMtlFile(filepath.Join(directory, filepath.Dir(fileName), library))
+		if err != nil {
+			return nil, err
+		}
+		for name, material := range materials {
+			mesh.Materials[name] = material
+		}
+	}
+	for _, group := range mesh.Groups {
+		if _, ok := mesh.Materials[group.Material]; group.Material != "" && !ok {
+			return nil, fmt.Errorf("%v: material %v is not defined", fileName, group.Material)
+		}
+	}
+	return mesh, nil
+}
 
-	fileReader := bufio.NewScanner(objFile)
-
-	vertices := []float32{}
-	textures := []float32{}
-	normals := []float32{}
-
-	fileVertices := []float32{}
-	fileTextures := []float32{}
-	fileNormals := []float32{}
-
-	for fileReader.Scan() {
-		line := fileReader.Text()
-		split := strings.Split(line, " ")
-		switch split[0] {
-		case "v":
-			x, _ := strconv.ParseFloat(split[1], 64)
-			y, _ := strconv.ParseFloat(split[2], 64)
-			z, _ := strconv.ParseFloat(split[3], 64)
-			fileVertices = append(fileVertices, float32(x), float32(y), float32(z))
-		case "vt":
-			x, _ := strconv.ParseFloat(split[1], 64)
-			y, _ := strconv.ParseFloat(split[2], 64)
-			fileTextures = append(fileTextures, float32(x), float32(y))
-		case "vn":
-			x, _ := strconv.ParseFloat(split[1], 64)
-			y, _ := strconv.ParseFloat(split[2], 64)
-			z, _ := strconv.ParseFloat(split[3], 64)
-			fileNormals = append(fileNormals, float32(x), float32(y), float32(z))
-		case "f":
-			face := strings.Split(split[1], "/")
-			vertices = append(vertices, parseFaceTriple(face[0], fileVertices)...)
-			textures = append(textures, parseFaceDouble(face[1], fileTextures)...)
-			normals = append(normals, parseFaceTriple(face[2], fileNormals)...)
-			face = strings.Split(split[2], "/")
-			vertices = append(vertices, parseFaceTriple(face[0], fileVertices)...)
-			textures = append(textures, parseFaceDouble(face[1], fileTextures)...)
-			normals = append(normals, parseFaceTriple(face[2], fileNormals)...)
-			face = strings.Split(split[3], "/")
-			vertices = append(vertices, parseFaceTriple(face[0], fileVertices)...)
-			textures = append(textures, parseFaceDouble(face[1], fileTextures)...)
-			normals = append(normals, parseFaceTriple(face[2], fileNormals)...)
+// ParseObj reads Wavefront OBJ geometry. Polygons are triangulated as fans,
+// indices may be negative to count back from the last element read, and
+// faces may use any of the v, v/vt, v//vn and v/vt/vn forms. Vertices
+// without a texture coordinate get (0, 0) and faces without normals get their
+// flat face normal. Statements the loader has no use for are skipped.
+func ParseObj(reader io.Reader, fileName string) (*Mesh, error) {
+	parser := &objParser{mesh: &Mesh{Materials: map[string]*Material{}}}
+	scanner := bufio.NewScanner(reader)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if err := parser.parseLine(scanner.Text()); err != nil {
+			return nil, &ParseError{fileName, line, err}
 		}
 	}
+	if err := scanner.Err(); err != nil {
+		return nil, &ParseError{fileName, line, err}
+	}
+	parser.closeGroup()
+	return parser.mesh, nil
+}
 
-	return vertices, normals, textures
+func (parser *objParser) parseLine(line string) error {
+	if comment := strings.Index(line, "#"); comment >= 0 {
+		line = line[:comment]
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	switch fields[0] {
+	case "v":
+		values, err := parseFloats(fields[1:], 3, 3)
+		if err != nil {
+			return err
+		}
+		parser.positions = append(parser.positions, values...)
+	case "vt":
+		values, err := parseFloats(fields[1:], 1, 2)
+		if err != nil {
+			return err
+		}
+		parser.textures = append(parser.textures, values...)
+	case "vn":
+		values, err := parseFloats(fields[1:], 3, 3)
+		if err != nil {
+			return err
+		}
+		parser.norms = append(parser.norms, values...)
+	case "f":
+		return parser.parseFace(fields[1:])
+	case "o", "g":
+		parser.closeGroup()
+		parser.name = strings.Join(fields[1:], " ")
+	case "usemtl":
+		if len(fields) < 2 {
+			return fmt.Errorf("usemtl needs a material name")
+		}
+		parser.closeGroup()
+		parser.material = fields[1]
+	case "mtllib":
+		parser.mesh.MaterialLibraries = append(parser.mesh.MaterialLibraries, fields[1:]...)
+	}
+	return nil
 }
 
-func parseFaceDouble(index string, slice []float32) []float32 {
-	if index != "" {
-		x, _ := strconv.ParseInt(index, 10, 64)
-		return []float32{slice[((x - 1) * 2)], slice[((x-1)*2)+1]}
-	} else {
-		return []float32{}
+func (parser *objParser) parseFace(fields []string) error {
+	if len(fields) < 3 {
+		return fmt.Errorf("face needs at least 3 vertices, got %v", len(fields))
+	}
+	face := make([]faceVertex, len(fields))
+	for i, field := range fields {
+		vertex, err := parser.parseFaceVertex(field)
+		if err != nil {
+			return err
+		}
+		face[i] = vertex
+	}
+	for i := 1; i+1 < len(face); i++ {
+		parser.addTriangle(face[0], face[i], face[i+1])
+	}
+	return nil
+}
+
+// parseFaceVertex resolves the indices of a face vertex to zero based ones,
+// using -1 for a missing texture coordinate or normal.
+func (parser *objParser) parseFaceVertex(field string) (faceVertex, error) {
+	parts := strings.Split(field, "/")
+	if len(parts) > 3 {
+		return faceVertex{}, fmt.Errorf("bad face vertex %q", field)
+	}
+	vertex := faceVertex{-1, -1, -1}
+	var err error
+	if vertex.position, err = resolveIndex(parts[0], len(parser.positions)/3); err != nil {
+		return vertex, err
+	}
+	if len(parts) > 1 && parts[1] != "" {
+		if vertex.texture, err = resolveIndex(parts[1], len(parser.textures)/2); err != nil {
+			return vertex, err
+		}
+	}
+	if len(parts) > 2 && parts[2] != "" {
+		if vertex.normal, err = resolveIndex(parts[2], len(parser.norms)/3); err != nil {
+			return vertex, err
+		}
+	}
+	return vertex, nil
+}
+
+func (parser *objParser) addTriangle(vertices ...faceVertex) {
+	var flat [3]float32
+	if vertices[0].normal < 0 || vertices[1].normal < 0 || vertices[2].normal < 0 {
+		flat = faceNormal(parser.position(vertices[0]), parser.position(vertices[1]), parser.position(vertices[2]))
+	}
+	mesh := parser.mesh
+	for _, vertex := range vertices {
+		position := parser.position(vertex)
+		mesh.Vertices = append(mesh.Vertices, position[:]...)
+		if vertex.texture >= 0 {
+			mesh.UV = append(mesh.UV, parser.textures[vertex.texture*2], parser.textures[(vertex.texture*2)+1])
+		} else {
+			mesh.UV = append(mesh.UV, 0, 0)
+		}
+		if vertex.normal >= 0 {
+			mesh.Normals = append(mesh.Normals, parser.norms[vertex.normal*3:(vertex.normal*3)+3]...)
+		} else {
+			mesh.Normals = append(mesh.Normals, flat[:]...)
+		}
 	}
 }
 
-func parseFaceTriple(index string, slice []float32) []float32 {
-	if index != "" {
-		x, _ := strconv.ParseInt(index, 10, 64)
-		return []float32{slice[((x - 1) * 3)], slice[((x-1)*3)+1], slice[((x-1)*3)+2]}
+func (parser *objParser) position(vertex faceVertex) [3]float32 {
+	i := vertex.position * 3
+	return [3]float32{parser.positions[i], parser.positions[i+1], parser.positions[i+2]}
+}
+
+// closeGroup ends the current group at the last vertex added, dropping it if
+// it has no faces.
+func (parser *objParser) closeGroup() {
+	mesh := parser.mesh
+	first := 0
+	if len(mesh.Groups) > 0 {
+		last := mesh.Groups[len(mesh.Groups)-1]
+		first = last.First + last.Count
+	}
+	if count := (len(mesh.Vertices) / 3) - first; count > 0 {
+		mesh.Groups = append(mesh.Groups, Group{parser.name, parser.material, first, count})
+	}
+}
+
+// resolveIndex turns a one based or negative OBJ index into a zero based one.
+func resolveIndex(field string, count int) (int, error) {
+	index, err := strconv.Atoi(field)
+	if err != nil {
+		return 0, fmt.Errorf("bad index %q", field)
+	}
+	if index < 0 {
+		index += count
 	} else {
-		return []float32{}
+		index--
+	}
+	if index < 0 || index >= count {
+		return 0, fmt.Errorf("index %v out of range, %v defined", field, count)
+	}
+	return index, nil
+}
+
+// parseFloats parses at least min values, keeping max of them and padding
+// with zeros up to max.
+func parseFloats(fields []string, min, max int) ([]float32, error) {
+	if len(fields) < min {
+		return nil, fmt.Errorf("expected %v values, got %v", min, len(fields))
+	}
+	values := make([]float32, max)
+	for i := 0; i < max && i < len(fields); i++ {
+		value, err := strconv.ParseFloat(fields[i], 32)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q", fields[i])
+		}
+		values[i] = float32(value)
+	}
+	return values, nil
+}
+
+func faceNormal(a, b, c [3]float32) [3]float32 {
+	u := [3]float32{b[0] - a[0], b[1] - a[1], b[2] - a[2]}
+	v := [3]float32{c[0] - a[0], c[1] - a[1], c[2] - a[2]}
+	n := [3]float32{(u[1] * v[2]) - (u[2] * v[1]), (u[2] * v[0]) - (u[0] * v[2]), (u[0] * v[1]) - (u[1] * v[0])}
+	length := float32(m.Sqrt(float64((n[0] * n[0]) + (n[1] * n[1]) + (n[2] * n[2]))))
+	if length == 0 {
+		return n
 	}
+	return [3]float32{n[0] / length, n[1] / length, n[2] / length}
 }
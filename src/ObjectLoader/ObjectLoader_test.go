@@ -0,0 +1,170 @@
+package ObjectLoader
+
+import (
+	"errors"
+	"testing"
+)
+
+const testDirectory string = "testdata"
+
+func loadTestObj(t *testing.T, file string) *Mesh {
+	t.Helper()
+	mesh, err := loadObjFile(testDirectory, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mesh
+}
+
+func vertexAt(values []float32, size, i int) []float32 {
+	return values[i*size : (i+1)*size]
+}
+
+func equalFloats(a, b []float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if d := a[i] - b[i]; d > 1e-5 || d < -1e-5 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestTriangulateQuad(t *testing.T) {
+	mesh := loadTestObj(t, "quad.obj")
+	want := [][]float32{{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 0, 0}, {1, 1, 0}, {0, 1, 0}}
+	if len(mesh.Vertices) != len(want)*3 {
+		t.Fatalf("quad has %v vertices, want %v", len(mesh.Vertices)/3, len(want))
+	}
+	for i, position := range want {
+		if got := vertexAt(mesh.Vertices, 3, i); !equalFloats(got, position) {
+			t.Errorf("vertex %v at %v, want %v", i, got, position)
+		}
+		if got := vertexAt(mesh.UV, 2, i); !equalFloats(got, position[:2]) {
+			t.Errorf("vertex %v uv %v, want %v", i, got, position[:2])
+		}
+		if got := vertexAt(mesh.Normals, 3, i); !equalFloats(got, []float32{0, 0, 1}) {
+			t.Errorf("vertex %v normal %v, want 0 0 1", i, got)
+		}
+	}
+}
+
+func TestTriangulatePolygon(t *testing.T) {
+	mesh := loadTestObj(t, "pentagon.obj")
+	if len(mesh.Vertices) != 9*3 {
+		t.Fatalf("pentagon has %v vertices, want 9", len(mesh.Vertices)/3)
+	}
+	// A fan around the first vertex
+	xs, zs := []float32{0, 1, 1.5, 0.5, -0.5}, []float32{0, 0, -1, -1.5, -1}
+	for triangle, corners := range [][3]int{{0, 1, 2}, {0, 2, 3}, {0, 3, 4}} {
+		for k, corner := range corners {
+			want := []float32{xs[corner], 0, zs[corner]}
+			if got := vertexAt(mesh.Vertices, 3, (triangle*3)+k); !equalFloats(got, want) {
+				t.Errorf("triangle %v corner %v at %v, want %v", triangle, k, got, want)
+			}
+		}
+	}
+	for i := 0; i < 9; i++ {
+		if got := vertexAt(mesh.Normals, 3, i); !equalFloats(got, []float32{0, 1, 0}) {
+			t.Errorf("vertex %v flat normal %v, want 0 1 0", i, got)
+		}
+		if got := vertexAt(mesh.UV, 2, i); !equalFloats(got, []float32{0, 0}) {
+			t.Errorf("vertex %v uv %v, want 0 0", i, got)
+		}
+	}
+}
+
+func TestFaceVertexForms(t *testing.T) {
+	mesh := loadTestObj(t, "forms.obj")
+	tests := []struct {
+		form       string
+		uv, normal []float32
+	}{
+		{"v", []float32{0, 0}, []float32{0, 0, 1}},
+		{"v/vt", []float32{0.25, 0.5}, []float32{0, 0, 1}},
+		{"v//vn", []float32{0, 0}, []float32{0, 0, -1}},
+		{"v/vt/vn", []float32{0.25, 0.5}, []float32{0, 0, -1}},
+	}
+	if len(mesh.Vertices) != len(tests)*9 {
+		t.Fatalf("forms has %v vertices, want %v", len(mesh.Vertices)/3, len(tests)*3)
+	}
+	for i, test := range tests {
+		first := i * 3
+		if got := vertexAt(mesh.Vertices, 3, first+1); !equalFloats(got, []float32{1, 0, 0}) {
+			t.Errorf("%v: second vertex at %v, want 1 0 0", test.form, got)
+		}
+		if got := vertexAt(mesh.UV, 2, first); !equalFloats(got, test.uv) {
+			t.Errorf("%v: uv %v, want %v", test.form, got, test.uv)
+		}
+		if got := vertexAt(mesh.Normals, 3, first); !equalFloats(got, test.normal) {
+			t.Errorf("%v: normal %v, want %v", test.form, got, test.normal)
+		}
+	}
+}
+
+func TestNegativeIndices(t *testing.T) {
+	mesh := loadTestObj(t, "negative.obj")
+	want := [][]float32{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}, {5, 0, 0}, {6, 0, 0}, {5, 1, 0}}
+	if len(mesh.Vertices) != len(want)*3 {
+		t.Fatalf("negative has %v vertices, want %v", len(mesh.Vertices)/3, len(want))
+	}
+	for i, position := range want {
+		if got := vertexAt(mesh.Vertices, 3, i); !equalFloats(got, position) {
+			t.Errorf("vertex %v at %v, want %v", i, got, position)
+		}
+	}
+	if got := vertexAt(mesh.UV, 2, 1); !equalFloats(got, []float32{1, 0}) {
+		t.Errorf("second uv %v, want 1 0", got)
+	}
+}
+
+func TestGroupsAndMaterials(t *testing.T) {
+	mesh := loadTestObj(t, "groups.obj")
+	want := []Group{
+		{"", "", 0, 3},
+		{"body", "red", 3, 6},
+		{"body", "blue", 9, 3},
+		{"eyes", "blue", 12, 3},
+	}
+	if len(mesh.Groups) != len(want) {
+		t.Fatalf("groups %+v, want %+v", mesh.Groups, want)
+	}
+	for i := range want {
+		if mesh.Groups[i] != want[i] {
+			t.Errorf("group %v is %+v, want %+v", i, mesh.Groups[i], want[i])
+		}
+	}
+
+	red, blue := mesh.Materials["red"], mesh.Materials["blue"]
+	if red == nil || blue == nil {
+		t.Fatalf("materials %v, want red and blue", mesh.Materials)
+	}
+	if red.Diffuse != [3]float32{1, 0, 0} || red.Shininess != 10 || red.Opacity != 1 {
+		t.Errorf("red is %+v", red)
+	}
+	if blue.Diffuse != [3]float32{0, 0, 1} || blue.Opacity != 0.5 || blue.DiffuseMap != "testdata/textures/blue.png" || blue.BumpMap != "testdata/textures/bumps.png" {
+		t.Errorf("blue is %+v", blue)
+	}
+}
+
+func TestParseErrorLine(t *testing.T) {
+	_, err := loadObjFile(testDirectory, "bad.obj")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("loading bad.obj returned %v, want a ParseError", err)
+	}
+	if parseErr.File != "bad.obj" || parseErr.Line != 6 {
+		t.Errorf("error at %v:%v, want bad.obj:6", parseErr.File, parseErr.Line)
+	}
+	if parseErr.Error() != "bad.obj:6: index 4 out of range, 3 defined" {
+		t.Errorf("error reads %q", parseErr.Error())
+	}
+}
+
+func TestUndefinedMaterial(t *testing.T) {
+	if _, err := loadObjFile(testDirectory, "missing.obj"); err == nil {
+		t.Error("a group using an undefined material loaded")
+	}
+}
@@ -3,8 +3,13 @@ package Player
 import (
 	"fmt"
 	m "math"
+	"sync"
 	"time"
 
+	"github.com/allanks/Voxel-Engine/src/Camera"
+	"github.com/allanks/Voxel-Engine/src/Chat"
+	"github.com/allanks/Voxel-Engine/src/Movement"
+	"github.com/allanks/Voxel-Engine/src/Network"
 	"github.com/allanks/Voxel-Engine/src/Server/DataType"
 	"github.com/allanks/Voxel-Engine/src/Terrain"
 	"github.com/go-gl/glfw/v3.1/glfw"
@@ -14,185 +19,182 @@ import (
 var (
 	user          player
 	lastFrameTime float64
+	// serverStates passes the player messages the server sends to
+	// MovePlayer, which owns the player
+	serverStates = make(chan *DataType.Message, 8)
+	// loaderPosition is where the player stands for the chunk loader, which
+	// runs on its own goroutine. It is only used while loaderLock is held.
+	loaderPosition DataType.Pos
+	loaderLock     sync.Mutex
+	// Name is what other players see this player as
+	Name = "player"
 )
 
-const (
-	forward,
-	backwards,
-	turnSpeed,
-	moveSpeed,
-	stop,
-	Height,
-	terminalVelocity,
-	jumpSpeed,
-	gravity,
-	collisionDistance float64 = 1, -1, 0.5, 0.1, 0, 1, -10, 2, 9.8, 0.15
-)
+const turnSpeed, moveSpeed float64 = 0.5, 0.1
 
+// player is predicted by running its inputs through the same movement
+// step as the server, before the server has answered them.
 type player struct {
-	xPos, yPos, zPos, pitch, turn, fall float64
-	freeMovement, isFalling             bool
-	gameMap                             *Terrain.Level
+	prediction                      Movement.Prediction
+	cursorX, cursorY                float64
+	freeMovement, cursorSet, joined bool
+	gameMap                         *Terrain.Level
+	camera                          *Camera.Camera
+	canFly                          bool
 }
 
-type moveFunc func(float64)
-
-func GenPlayer(xPos, yPos, zPos float64) {
+// GenPlayer places the player with its eyes at the position.
+func GenPlayer(xPos, yPos, zPos float64, camera *Camera.Camera) {
 	lastFrameTime = glfw.GetTime()
-	user = player{xPos, yPos, zPos, -180.0, 0.0, 0.0, true, true, &Terrain.Level{}}
+	feet := DataType.Pos{XPos: float32(xPos), YPos: float32(yPos) - Movement.EyeHeight, ZPos: float32(zPos)}
+	user = player{Movement.Prediction{State: DataType.MoveState{Position: feet, Flying: true}}, 0.0, 0.0, true, false, false, &Terrain.Level{}, camera, true}
+	camera.Follow(float32(xPos), float32(yPos), float32(zPos))
 
+	Network.Handle(DataType.Welcome, receiveState)
+	Network.Handle(DataType.PlayerAck, receiveState)
+	user.gameMap.Listen()
 	Terrain.StartConnection()
+	yaw, pitch := user.look()
+	Network.Send(&DataType.Message{Type: DataType.Join, Player: &DataType.PlayerState{Name: Name, Yaw: yaw, Pitch: pitch}})
 	user.gameMap.InitChunk(int(m.Floor(float64(xPos))), int(m.Floor(float64(zPos))))
+	loaderPosition = feet
 	go user.loopChunkLoader()
 }
 
+// MovePlayer reads the controls into an input, which moves the player
+// straight away and is sent to the server. The player stays put until the
+// server has welcomed it.
 func MovePlayer(window *glfw.Window) {
+	applyServerStates()
 	frameTime := glfw.GetTime()
 	frameRate := frameTime - lastFrameTime
 	lastFrameTime = frameTime
-	if window.GetKey(glfw.KeyW) == glfw.Press {
-		move(1)
-	}
-	if window.GetKey(glfw.KeyS) == glfw.Press {
-		move(-1)
-	}
-	if window.GetKey(glfw.KeyA) == glfw.Press {
-		strafe(1)
-	}
-	if window.GetKey(glfw.KeyD) == glfw.Press {
-		strafe(-1)
-	}
+
+	input := DataType.PlayerInput{Fly: user.freeMovement, Delta: float32(frameRate)}
+	input.Yaw, input.Pitch = user.look()
 	switch {
-	case window.GetKey(glfw.KeySpace) == glfw.Press && user.freeMovement:
-		user.moveY(user.yPos + (moveSpeed))
-	case window.GetKey(glfw.KeyLeftShift) == glfw.Press && user.freeMovement:
-		user.moveY(user.yPos + (-1 * moveSpeed))
-	case !user.freeMovement:
-		user.moveY(user.yPos + (user.fall * moveSpeed) - 1)
-		if user.isFalling {
-			user.yPos = user.yPos + (user.fall * moveSpeed)
-			if user.fall > terminalVelocity {
-				user.fall = user.fall - (gravity * frameRate)
-			}
+	case Chat.IsTyping():
+		// Keys are typed into the chat
+	case user.camera.IsDetached():
+		moveCamera(window)
+	default:
+		input.Forward = axis(window, glfw.KeyW, glfw.KeyS)
+		input.Strafe = axis(window, glfw.KeyD, glfw.KeyA)
+		if user.freeMovement {
+			input.Up = axis(window, glfw.KeySpace, glfw.KeyLeftShift)
+		} else {
+			input.Jump = window.GetKey(glfw.KeySpace) == glfw.Press
 		}
 	}
+	if user.joined {
+		predict(input)
+	}
+	user.camera.Follow(user.eye())
+	loaderLock.Lock()
+	loaderPosition = user.prediction.State.Position
+	loaderLock.Unlock()
 }
 
-func GetPosition() (float64, float64, float64) {
-	return user.xPos, user.yPos, user.zPos
+// axis is 1 while the positive key is held, -1 while the negative one is
+// and 0 for both or neither.
+func axis(window *glfw.Window, positive, negative glfw.Key) float32 {
+	var value float32
+	if window.GetKey(positive) == glfw.Press {
+		value++
+	}
+	if window.GetKey(negative) == glfw.Press {
+		value--
+	}
+	return value
 }
 
-func GetPlayerSpeed() float64 {
-	return moveSpeed
+// predict applies an input straight away and sends it to the server,
+// keeping it until the server acknowledges it.
+func predict(input DataType.PlayerInput) {
+	input = user.prediction.Predict(input, user.gameMap)
+	Network.Send(&DataType.Message{Type: DataType.PlayerMove, Input: &input})
 }
 
-func GetCameraMatrix() mgl32.Mat4 {
-	xLook := float64(m.Sin(float64(user.pitch)*m.Pi/180) * m.Cos(float64(user.turn)*m.Pi/180))
-	zLook := float64(m.Sin(float64(user.pitch)*m.Pi/180) * m.Sin(float64(user.turn)*m.Pi/180))
-	yLook := -1 * float64(m.Cos(float64(-1*user.pitch)*m.Pi/180))
-	return mgl32.LookAtV(
-		mgl32.Vec3{float32(user.xPos), float32(user.yPos), float32(user.zPos)},
-		mgl32.Vec3{float32(user.xPos + xLook), float32(user.yPos + yLook), float32(user.zPos + zLook)},
-		mgl32.Vec3{0, 1, 0})
+func receiveState(message *DataType.Message) {
+	if message.Player != nil {
+		serverStates <- message
+	}
 }
 
-func checkCubes(cubes []DataType.Pos, current, final DataType.Pos) bool {
-	for _, cube := range cubes {
-		if final.XPos > cube.XPos && final.XPos < (cube.XPos+1) &&
-			final.YPos > cube.YPos && final.YPos < (cube.YPos-1) &&
-			final.ZPos > cube.ZPos && final.ZPos < (cube.ZPos+1) {
-			return false
+// applyServerStates takes on whether the server lets the player fly, moves
+// the player to where the server spawned it and reconciles the player with
+// every acknowledgement.
+func applyServerStates() {
+	for {
+		select {
+		case message := <-serverStates:
+			user.canFly = message.Player.CanFly
+			if !user.canFly {
+				user.freeMovement = false
+			}
+			switch message.Type {
+			case DataType.Welcome:
+				user.prediction.Reset(message.Player.Move)
+				user.joined = true
+			case DataType.PlayerAck:
+				user.prediction.Reconcile(message.Player.Sequence, message.Player.Move, user.gameMap)
+			}
+		default:
+			return
 		}
 	}
-	return true
 }
 
-func (user *player) moveY(newY float64) {
-	upperCube := DataType.Pos{XPos: float32(user.xPos), YPos: float32(user.yPos + 1), ZPos: float32(user.zPos)}
-	lowerCube := DataType.Pos{XPos: float32(user.xPos), YPos: float32(user.yPos - Height - 1), ZPos: float32(user.zPos)}
-
-	cubes := user.gameMap.GetCubes([]DataType.Pos{upperCube, lowerCube})
-
-	topCurrent := DataType.Pos{XPos: float32(user.xPos), YPos: float32(user.yPos), ZPos: float32(user.zPos)}
-	topFinal := DataType.Pos{XPos: float32(user.xPos), YPos: float32(newY), ZPos: float32(user.zPos)}
-	bottomCurrent := DataType.Pos{XPos: float32(user.xPos), YPos: float32(user.yPos - Height), ZPos: float32(user.zPos)}
-	bottomFinal := DataType.Pos{XPos: float32(user.xPos), YPos: float32(newY - Height), ZPos: float32(user.zPos)}
-
-	if checkCubes(cubes, topCurrent, topFinal) || checkCubes(cubes, bottomCurrent, bottomFinal) {
-		user.yPos = newY
-	} else {
-		user.fall = 0.0
-		user.yPos = m.Floor(user.yPos)
-	}
+// look returns where the camera looks as a yaw, zero facing +z, and a pitch.
+func (user *player) look() (float32, float32) {
+	look := user.camera.Forward()
+	return float32(m.Atan2(float64(look.X()), float64(look.Z()))), float32(m.Asin(float64(mgl32.Clamp(look.Y(), -1, 1))))
 }
 
-func moveXZ(newX, newZ float64) {
-	upperFrontCube := DataType.Pos{XPos: float32(user.xPos + 1), YPos: float32(user.yPos), ZPos: float32(user.zPos)}
-	lowerFrontCube := DataType.Pos{XPos: float32(user.xPos + 1), YPos: float32(user.yPos - Height), ZPos: float32(user.zPos)}
-	upperBackCube := DataType.Pos{XPos: float32(user.xPos - 1), YPos: float32(user.yPos), ZPos: float32(user.zPos)}
-	lowerBackCube := DataType.Pos{XPos: float32(user.xPos - 1), YPos: float32(user.yPos - Height), ZPos: float32(user.zPos)}
-	upperLeftCube := DataType.Pos{XPos: float32(user.xPos), YPos: float32(user.yPos), ZPos: float32(user.zPos - 1)}
-	lowerLeftCube := DataType.Pos{XPos: float32(user.xPos), YPos: float32(user.yPos - Height), ZPos: float32(user.zPos - 1)}
-	upperRightCube := DataType.Pos{XPos: float32(user.xPos), YPos: float32(user.yPos), ZPos: float32(user.zPos + 1)}
-	lowerRightCube := DataType.Pos{XPos: float32(user.xPos), YPos: float32(user.yPos - Height), ZPos: float32(user.zPos + 1)}
-
-	cubes := user.gameMap.GetCubes([]DataType.Pos{upperFrontCube, lowerFrontCube, upperBackCube, lowerBackCube, upperLeftCube, lowerLeftCube, upperRightCube, lowerRightCube})
-
-	topCurrent := DataType.Pos{XPos: float32(user.xPos), YPos: float32(user.yPos), ZPos: float32(user.zPos)}
-	topFinal := DataType.Pos{XPos: float32(newX), YPos: float32(user.yPos), ZPos: float32(user.zPos)}
-	bottomCurrent := DataType.Pos{XPos: float32(user.xPos), YPos: float32(user.yPos - Height), ZPos: float32(user.zPos)}
-	bottomFinal := DataType.Pos{XPos: float32(newX), YPos: float32(user.yPos - Height), ZPos: float32(user.zPos)}
-	if checkCubes(cubes, topCurrent, topFinal) || checkCubes(cubes, bottomCurrent, bottomFinal) {
-		user.xPos = newX
-	}
-
-	topCurrent = DataType.Pos{XPos: float32(user.xPos), YPos: float32(user.yPos), ZPos: float32(user.zPos)}
-	topFinal = DataType.Pos{XPos: float32(user.xPos), YPos: float32(user.yPos), ZPos: float32(newZ)}
-	bottomCurrent = DataType.Pos{XPos: float32(user.xPos), YPos: float32(user.yPos - Height), ZPos: float32(user.zPos)}
-	bottomFinal = DataType.Pos{XPos: float32(user.xPos), YPos: float32(user.yPos - Height), ZPos: float32(newZ)}
-	if checkCubes(cubes, topCurrent, topFinal) || checkCubes(cubes, bottomCurrent, bottomFinal) {
-		user.zPos = newZ
-	}
+func (user *player) eye() (float32, float32, float32) {
+	position := user.prediction.State.Position
+	return position.XPos, position.YPos + Movement.EyeHeight, position.ZPos
 }
 
-func move(direction float64) {
-	var xLook, zLook float64
-	if user.freeMovement {
-		xLook = -1 * float64(m.Sin(float64(user.pitch)*m.Pi/180)*m.Cos(float64(user.turn)*m.Pi/180))
-		zLook = -1 * float64(m.Sin(float64(user.pitch)*m.Pi/180)*m.Sin(float64(user.turn)*m.Pi/180))
-	} else {
-		xLook = float64(m.Cos(float64(user.turn) * m.Pi / 180))
-		zLook = float64(m.Sin(float64(user.turn) * m.Pi / 180))
-	}
-	yLook := -1 * float64(m.Cos(float64(-1*user.pitch)*m.Pi/180))
-	newX := user.xPos - (direction * xLook * moveSpeed)
-	newY := user.yPos + (direction * yLook * moveSpeed)
-	newZ := user.zPos - (direction * zLook * moveSpeed)
-	if user.freeMovement {
-		user.moveY(newY)
-	}
-	moveXZ(newX, newZ)
+func moveCamera(window *glfw.Window) {
+	var forward, right, up float32
+	if window.GetKey(glfw.KeyW) == glfw.Press {
+		forward += float32(moveSpeed)
+	}
+	if window.GetKey(glfw.KeyS) == glfw.Press {
+		forward -= float32(moveSpeed)
+	}
+	if window.GetKey(glfw.KeyA) == glfw.Press {
+		right -= float32(moveSpeed)
+	}
+	if window.GetKey(glfw.KeyD) == glfw.Press {
+		right += float32(moveSpeed)
+	}
+	if window.GetKey(glfw.KeySpace) == glfw.Press {
+		up += float32(moveSpeed)
+	}
+	if window.GetKey(glfw.KeyLeftShift) == glfw.Press {
+		up -= float32(moveSpeed)
+	}
+	user.camera.Move(forward, right, up)
 }
 
-func strafe(direction float64) {
-	xLook := float64(m.Cos(float64(user.turn) * m.Pi / 180))
-	zLook := float64(m.Sin(float64(user.turn) * m.Pi / 180))
-	newX := user.xPos + (-1 * direction * zLook * moveSpeed)
-	newZ := user.zPos + (direction * xLook * moveSpeed)
-	moveXZ(newX, newZ)
+// GetPosition returns where the eyes of the player are.
+func GetPosition() (float64, float64, float64) {
+	x, y, z := user.eye()
+	return float64(x), float64(y), float64(z)
 }
 
 func OnCursor(window *glfw.Window, xPos, yPos float64) {
-	if yPos > -5 {
-		window.SetCursorPos(xPos, -5)
-		yPos = -5
+	if user.camera == nil {
+		return
 	}
-	if yPos < -359 {
-		window.SetCursorPos(xPos, -359)
-		yPos = -359
+	if !user.cursorSet {
+		user.cursorX, user.cursorY, user.cursorSet = xPos, yPos, true
+		return
 	}
-	user.turn = float64(int32(float64(xPos)*turnSpeed) % 360)
-	user.pitch = float64(int32(float64(yPos)*turnSpeed) % 360)
+	user.camera.Rotate(float32((xPos-user.cursorX)*turnSpeed), float32((user.cursorY-yPos)*turnSpeed))
+	user.cursorX, user.cursorY = xPos, yPos
 }
 
 func OnKey(window *glfw.Window, k glfw.Key, s int, action glfw.Action, mods glfw.ModifierKey) {
@@ -200,30 +202,37 @@ func OnKey(window *glfw.Window, k glfw.Key, s int, action glfw.Action, mods glfw
 	case glfw.KeyEscape:
 		window.SetShouldClose(true)
 	case glfw.KeyRightShift:
-		if action == glfw.Press {
+		if action == glfw.Press && user.canFly {
 			user.freeMovement = !user.freeMovement
-			user.fall = 0.0
-		}
-	case glfw.KeySpace:
-		if action == glfw.Press && !user.freeMovement && user.yPos == m.Floor(user.yPos) {
-			user.fall = jumpSpeed
 		}
 	case glfw.KeyP:
-		fmt.Printf("Player X %v, Y %v, Z %v Free %v\n", int(m.Floor(user.xPos)), int(m.Floor(user.yPos)), int(m.Floor(user.zPos)), user.freeMovement)
+		x, y, z := GetPosition()
+		fmt.Printf("Player X %v, Y %v, Z %v Free %v\n", int(m.Floor(x)), int(m.Floor(y)), int(m.Floor(z)), user.freeMovement)
 	case glfw.KeyC:
-		fmt.Printf("Camera %v\n", GetCameraMatrix())
+		fmt.Printf("Camera %v\n", user.camera.ViewMatrix())
+	case glfw.KeyF5:
+		if action == glfw.Press {
+			user.camera.NextMode()
+		}
+	case glfw.KeyV:
+		drawn, culled := user.gameMap.GetRenderStats()
+		fmt.Printf("Chunks drawn %v, culled %v\n", drawn, culled)
 	case glfw.KeyG:
-		fmt.Printf("Near Y Cubes %v\n", user.gameMap.GetYCubes(user.xPos, user.yPos, user.zPos, Height))
+		x, y, z := GetPosition()
+		fmt.Printf("Near Y Cubes %v\n", user.gameMap.GetYCubes(x, y, z, float64(Movement.Height)))
 	}
 }
 
 func (p *player) loopChunkLoader() {
 	for {
-		p.gameMap.LoopChunkLoader(p.xPos, p.zPos)
+		loaderLock.Lock()
+		position := loaderPosition
+		loaderLock.Unlock()
+		p.gameMap.LoopChunkLoader(float64(position.XPos), float64(position.ZPos))
 		time.Sleep(1 * time.Second)
 	}
 }
 
 func Render() {
-	user.gameMap.RenderLevel()
+	user.gameMap.RenderLevel(user.camera.Frustum())
 }
@@ -0,0 +1,199 @@
+package Server
+
+import (
+	m "math"
+	"math/rand"
+
+	"github.com/allanks/Voxel-Engine/src/Server/DataType"
+)
+
+// Behaviours
+const (
+	BehaviourIdle = iota
+	BehaviourWander
+	BehaviourFollow
+	BehaviourFlee
+)
+
+const (
+	walkSpeed      float32 = 2
+	jumpSpeed      float32 = 5.5
+	followRange    float32 = 16
+	followDistance float32 = 2
+	fleeRange      float32 = 8
+	fleeDistance   float32 = 10
+	wanderRadius   int     = 8
+	wanderChance   float64 = 0.02
+	repathTicks    int     = 20
+	maxPathNodes   int     = 1000
+)
+
+// kindBehaviours is the behaviour each kind of entity spawns with, kinds
+// that are not listed stay idle.
+var kindBehaviours = map[int]int{
+	DataType.EntityGopher: BehaviourFollow,
+}
+
+var entityPaths = &pathfinder{
+	solid: func(x, y, z int) bool {
+		return isSolid(float32(x), float32(y), float32(z))
+	},
+	height:     1,
+	jumpHeight: 1,
+	maxDrop:    3,
+	stepHeight: 0,
+	jumpCost:   1,
+	maxNodes:   maxPathNodes,
+}
+
+// brain is the AI state of an entity. The path runs from the block the
+// entity is heading for to its goal.
+type brain struct {
+	behaviour    int
+	path         [][3]int
+	repath, idle int
+}
+
+// SetEntityBehaviour changes what an entity does on its own.
+func SetEntityBehaviour(id uint32, behaviour int) {
+	entityLock.Lock()
+	defer entityLock.Unlock()
+	if current, ok := entities[id]; ok {
+		current.behaviour, current.path = behaviour, nil
+	}
+}
+
+// think picks where the entity goes next and sets its velocity to get there.
+// Following and fleeing entities wander when no player is in range.
+func (current *entity) think() {
+	if current.behaviour == BehaviourIdle {
+		return
+	}
+	current.repath--
+	switch {
+	case current.behaviour == BehaviourFollow && current.follow():
+	case current.behaviour == BehaviourFlee && current.flee():
+	default:
+		current.wander()
+	}
+	current.walk()
+}
+
+func (current *entity) follow() bool {
+	target, ok := nearestPlayer(current.Position, followRange)
+	if !ok {
+		return false
+	}
+	if horizontalDistance(current.Position, target) <= followDistance {
+		current.path = nil
+		return true
+	}
+	if current.path == nil || current.repath <= 0 {
+		current.findPath(blockOf(target))
+	}
+	return true
+}
+
+func (current *entity) flee() bool {
+	threat, ok := nearestPlayer(current.Position, fleeRange)
+	if !ok {
+		return false
+	}
+	if current.path == nil || current.repath <= 0 {
+		dx, dz := current.Position.XPos-threat.XPos, current.Position.ZPos-threat.ZPos
+		length := float32(m.Sqrt(float64((dx * dx) + (dz * dz))))
+		if length == 0 {
+			dx, dz, length = 1, 0, 1
+		}
+		away := current.Position
+		away.XPos += (dx / length) * fleeDistance
+		away.ZPos += (dz / length) * fleeDistance
+		if goal, ok := groundNear(blockOf(away)); ok {
+			current.findPath(goal)
+		}
+	}
+	return true
+}
+
+func (current *entity) wander() {
+	if current.path != nil || rand.Float64() >= wanderChance {
+		return
+	}
+	goal := blockOf(current.Position)
+	goal[0] += rand.Intn((wanderRadius*2)+1) - wanderRadius
+	goal[2] += rand.Intn((wanderRadius*2)+1) - wanderRadius
+	if goal, ok := groundNear(goal); ok {
+		current.findPath(goal)
+	}
+}
+
+func (current *entity) findPath(goal [3]int) {
+	current.path = entityPaths.findPath(blockOf(current.Position), goal)
+	current.repath, current.idle = repathTicks, 0
+}
+
+// walk steers the entity to the centre of the next block on its path,
+// jumping when that block is higher. Paths the entity stops making progress
+// on are dropped.
+func (current *entity) walk() {
+	current.Velocity.XPos, current.Velocity.ZPos = 0, 0
+	for len(current.path) > 0 {
+		next := current.path[0]
+		dx := float32(next[0]) + 0.5 - current.Position.XPos
+		dz := float32(next[2]) + 0.5 - current.Position.ZPos
+		length := float32(m.Sqrt(float64((dx * dx) + (dz * dz))))
+		if length < 0.15 && DataType.FloorToInt(current.Position.YPos) == next[1] {
+			current.path, current.idle = current.path[1:], 0
+			continue
+		}
+		current.idle++
+		if current.idle > repathTicks*2 {
+			break
+		}
+		current.Velocity.XPos, current.Velocity.ZPos = (dx/length)*walkSpeed, (dz/length)*walkSpeed
+		if next[1] > DataType.FloorToInt(current.Position.YPos) && current.onGround {
+			current.Velocity.YPos = jumpSpeed
+			current.onGround = false
+		}
+		return
+	}
+	current.path = nil
+}
+
+// nearestPlayer returns the position of the closest player within reach.
+// entityLock has to be held.
+func nearestPlayer(position DataType.Pos, reach float32) (DataType.Pos, bool) {
+	var nearest DataType.Pos
+	found := false
+	for _, other := range entities {
+		if other.Kind != DataType.EntityPlayer {
+			continue
+		}
+		if d := horizontalDistance(position, other.Position); d <= reach {
+			nearest, reach, found = other.Position, d, true
+		}
+	}
+	return nearest, found
+}
+
+// groundNear finds a standable block in the column of position, searching
+// a few blocks up and down from it.
+func groundNear(position [3]int) ([3]int, bool) {
+	for dy := 0; dy <= 4; dy++ {
+		for _, y := range []int{position[1] + dy, position[1] - dy} {
+			if entityPaths.standable(position[0], y, position[2]) {
+				return [3]int{position[0], y, position[2]}, true
+			}
+		}
+	}
+	return position, false
+}
+
+func blockOf(position DataType.Pos) [3]int {
+	return [3]int{DataType.FloorToInt(position.XPos), DataType.FloorToInt(position.YPos), DataType.FloorToInt(position.ZPos)}
+}
+
+func horizontalDistance(a, b DataType.Pos) float32 {
+	dx, dz := a.XPos-b.XPos, a.ZPos-b.ZPos
+	return float32(m.Sqrt(float64((dx * dx) + (dz * dz))))
+}
@@ -0,0 +1,182 @@
+package Server
+
+import (
+	"errors"
+	"fmt"
+	m "math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/allanks/Voxel-Engine/src/Block"
+	"github.com/allanks/Voxel-Engine/src/Server/DataType"
+)
+
+// Permission levels, each can run the commands of the ones before it
+const (
+	PermissionPlayer = iota
+	PermissionOperator
+	PermissionConsole
+)
+
+// Caller is who runs a command. Player is the entity of the caller, zero
+// when it is not a player.
+type Caller struct {
+	Name       string
+	Permission int
+	Player     uint32
+}
+
+// Command is run with the words that followed its name. Run returns the
+// line to answer the caller with, or errUsage to show the caller Usage.
+type Command struct {
+	Usage, Help string
+	Permission  int
+	Run         func(caller *Caller, args []string) (string, error)
+}
+
+var errUsage = errors.New("usage")
+
+var commands = map[string]*Command{}
+
+func init() {
+	RegisterCommand("help", &Command{"/help", "lists the commands you can run", PermissionPlayer, commandHelp})
+	RegisterCommand("who", &Command{"/who", "lists the players online", PermissionPlayer, commandWho})
+	RegisterCommand("seed", &Command{"/seed", "shows the terrain seed", PermissionPlayer, commandSeed})
+	RegisterCommand("time", &Command{"/time", "shows the server time and uptime", PermissionPlayer, commandTime})
+	RegisterCommand("tp", &Command{"/tp [player] <x> <y> <z> | /tp <player>", "teleports a player", PermissionOperator, commandTeleport})
+	RegisterCommand("setblock", &Command{"/setblock <x> <y> <z> <block>", "places a block, by name or id", PermissionOperator, commandSetBlock})
+}
+
+// RegisterCommand adds a command, replacing any command with the same name.
+func RegisterCommand(name string, command *Command) {
+	commands[name] = command
+}
+
+// RunCommand runs a command line without its leading / and returns what to
+// answer the caller with.
+func RunCommand(caller *Caller, line string) string {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "Type /help for a list of commands"
+	}
+	command, ok := commands[fields[0]]
+	if !ok {
+		return fmt.Sprintf("Unknown command /%v, type /help for a list of commands", fields[0])
+	}
+	if caller.Permission < command.Permission {
+		return fmt.Sprintf("You are not allowed to run /%v", fields[0])
+	}
+	reply, err := command.Run(caller, fields[1:])
+	if err == errUsage {
+		return "Usage: " + command.Usage
+	}
+	if err != nil {
+		return "Error: " + err.Error()
+	}
+	return reply
+}
+
+func commandHelp(caller *Caller, args []string) (string, error) {
+	lines := []string{}
+	for _, command := range commands {
+		if caller.Permission >= command.Permission {
+			lines = append(lines, fmt.Sprintf("%v - %v", command.Usage, command.Help))
+		}
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n"), nil
+}
+
+func commandWho(caller *Caller, args []string) (string, error) {
+	names := playerNames()
+	return fmt.Sprintf("%v online: %v", len(names), strings.Join(names, ", ")), nil
+}
+
+func commandSeed(caller *Caller, args []string) (string, error) {
+	return fmt.Sprintf("Seed %v", Seed), nil
+}
+
+func commandTime(caller *Caller, args []string) (string, error) {
+	now := time.Now()
+	return fmt.Sprintf("Server time %v, up %v", now.Format("15:04:05"), now.Sub(started).Truncate(time.Second)), nil
+}
+
+// commandTeleport moves the caller, or the player named first, to a
+// position or to another player.
+func commandTeleport(caller *Caller, args []string) (string, error) {
+	target, name := caller.Player, caller.Name
+	named := len(args) == 1 || len(args) == 4
+	if named {
+		var ok bool
+		if target, ok = findPlayer(args[0]); !ok {
+			return "", fmt.Errorf("%v is not online", args[0])
+		}
+		name, args = args[0], args[1:]
+	}
+
+	var position DataType.Pos
+	switch {
+	case named && len(args) == 0:
+		position, _ = entityPosition(target)
+		target, name = caller.Player, caller.Name
+	case len(args) == 3:
+		coordinates, err := parseCoordinates(args)
+		if err != nil {
+			return "", err
+		}
+		position = DataType.Pos{XPos: float32(coordinates[0]), YPos: float32(coordinates[1]), ZPos: float32(coordinates[2])}
+	default:
+		return "", errUsage
+	}
+	if target == 0 {
+		return "", errUsage
+	}
+	if !teleportEntity(target, position) {
+		return "", fmt.Errorf("%v is not in the world", name)
+	}
+	return fmt.Sprintf("Teleported %v to %.1f %.1f %.1f", name, position.XPos, position.YPos, position.ZPos), nil
+}
+
+// commandSetBlock changes a block and sends the chunk it is in to every
+// player.
+func commandSetBlock(caller *Caller, args []string) (string, error) {
+	if len(args) != 4 {
+		return "", errUsage
+	}
+	coordinates, err := parseCoordinates(args[:3])
+	if err != nil {
+		return "", err
+	}
+	definition, ok := Block.ByName(args[3])
+	if id, err := strconv.Atoi(args[3]); !ok && err == nil {
+		definition = Block.Get(id)
+	}
+	if definition == nil || definition.ID == Block.SkyBox {
+		return "", fmt.Errorf("there is no block %v", args[3])
+	}
+
+	x, y, z := int(m.Floor(coordinates[0])), int(m.Floor(coordinates[1])), int(m.Floor(coordinates[2]))
+	changed, err := setBlocks([]BlockEdit{{x, y, z, definition.ID}})
+	if err != nil {
+		return "", err
+	}
+	forgetChunks(changed)
+	for _, c := range changed {
+		sendJoined(&DataType.Message{Type: DataType.ChunkUpdate, Cubes: c})
+	}
+	return fmt.Sprintf("Set %v %v %v to %v", x, y, z, definition.Name), nil
+}
+
+func parseCoordinates(args []string) ([]float64, error) {
+	coordinates := make([]float64, len(args))
+	for i, arg := range args {
+		value, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a coordinate", arg)
+		}
+		coordinates[i] = value
+	}
+	return coordinates, nil
+}
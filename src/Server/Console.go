@@ -0,0 +1,124 @@
+package Server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const consoleHelp = `Console commands:
+	list                      lists the connected players
+	kick <player> [reason]    disconnects a player
+	op <player>               gives an online player operator permission until it leaves
+	deop <player>             takes operator permission from a player
+	save                      waits for chunks being stored and flushes the storage
+	pregen <radius> [x z]     generates the chunks within radius chunks of chunk x z, 0 0 by default
+	pregen <x0> <z0> <x1> <z1>
+	                          generates the chunks from chunk x0 z0 to chunk x1 z1
+	stop                      stops the server
+	/<command>                runs a player command with every permission, /help lists them`
+
+// RunConsole reads admin commands, one a line, until input ends or the
+// server stops.
+func RunConsole(input io.Reader) {
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if strings.HasPrefix(fields[0], "/") {
+			fmt.Println(RunCommand(&Caller{Name: "console", Permission: PermissionConsole}, strings.TrimPrefix(scanner.Text(), "/")))
+			continue
+		}
+		switch fields[0] {
+		case "list":
+			consoleList()
+		case "kick":
+			consoleKick(fields[1:])
+		case "op":
+			consolePermission(fields[1:], PermissionOperator)
+		case "deop":
+			consolePermission(fields[1:], PermissionPlayer)
+		case "save":
+			if err := Save(); err != nil {
+				fmt.Printf("Save failed: %v\n", err)
+				continue
+			}
+			fmt.Println("Saved")
+		case "pregen":
+			consolePregenerate(fields[1:])
+		case "stop":
+			Stop()
+			return
+		default:
+			fmt.Println(consoleHelp)
+		}
+	}
+}
+
+func consoleList() {
+	type player struct {
+		id            uint32
+		name, address string
+	}
+	sessionLock.Lock()
+	players := []player{}
+	for _, current := range sessions {
+		if current.player != 0 {
+			players = append(players, player{current.player, current.name, current.conn.RemoteAddr().String()})
+		}
+	}
+	connected := len(sessions)
+	sessionLock.Unlock()
+
+	fmt.Printf("%v players, %v connections\n", len(players), connected)
+	for _, current := range players {
+		position, _ := entityPosition(current.id)
+		fmt.Printf("\t%v from %v at %.1f %.1f %.1f\n", current.name, current.address, position.XPos, position.YPos, position.ZPos)
+	}
+}
+
+func consoleKick(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: kick <player> [reason]")
+		return
+	}
+	reason := "kicked by the console"
+	if len(args) > 1 {
+		reason = strings.Join(args[1:], " ")
+	}
+	if !kickPlayer(args[0], reason) {
+		fmt.Printf("%v is not online\n", args[0])
+		return
+	}
+	fmt.Printf("Kicked %v\n", args[0])
+}
+
+func consolePermission(args []string, permission int) {
+	if len(args) != 1 {
+		fmt.Println("Usage: op <player> | deop <player>")
+		return
+	}
+	if !setPermission(args[0], permission) {
+		fmt.Printf("%v is not online\n", args[0])
+		return
+	}
+	logf(LogInfo, "%v was given permission %v by the console", args[0], permission)
+	fmt.Printf("Set the permission of %v\n", args[0])
+}
+
+func consolePregenerate(args []string) {
+	x0, z0, x1, z1, err := ParsePregenRegion(args)
+	if err != nil {
+		fmt.Printf("%v\nUsage: pregen <radius> [x z] | pregen <x0> <z0> <x1> <z1>\n", err)
+		return
+	}
+	summary, err := Pregenerate(x0, z0, x1, z1)
+	if err != nil {
+		fmt.Printf("Pregeneration stopped after %v: %v\n", summary, err)
+		return
+	}
+	fmt.Printf("Pregenerated %v\n", summary)
+}
@@ -0,0 +1,136 @@
+package DataType
+
+import (
+	"encoding/gob"
+	"net"
+	"sync"
+	"time"
+)
+
+// Message types
+const (
+	ChunkRequest = iota
+	ChunkData
+	EntitySpawn
+	EntityUpdate
+	EntityDespawn
+	Join
+	Welcome
+	PlayerMove
+	PlayerAck
+	ChunkUpdate
+	Text
+	Disconnect
+)
+
+// Entity kinds
+const (
+	EntityGopher = iota
+	EntityPlayer
+)
+
+// Message is the envelope for everything sent between the client and the
+// server. Only the field its Type carries is set. ChunkUpdate carries the
+// Cubes of a chunk that changed without the client asking for it. Text is a
+// chat line or command from a client and a line to show from the server,
+// Disconnect carries the reason the server closed the connection in Text.
+type Message struct {
+	Type   int
+	Chunk  *Chunk
+	Cubes  *CubeChunk
+	Entity *Entity
+	Player *PlayerState
+	Input  *PlayerInput
+	Text   string
+}
+
+// Entity is the replicated state of a server entity. Yaw is in radians
+// around the y axis, zero facing +z. Only players have a Name.
+type Entity struct {
+	ID                 uint32
+	Kind               int
+	Name               string
+	Position, Velocity Pos
+	Yaw                float32
+}
+
+// PlayerState is what a client joins with and what the server answers it
+// with. Position is at the player's feet. Acknowledgements carry the
+// Sequence of the last input the server applied and the Move it left the
+// player in. CanFly is only set by the server.
+type PlayerState struct {
+	ID         uint32
+	Name       string
+	Position   Pos
+	Yaw, Pitch float32
+	CanFly     bool
+	Sequence   uint32
+	Move       MoveState
+}
+
+// PlayerInput is the controls of a player over one frame of Delta seconds.
+// Forward, Strafe and Up run from -1 to 1, Strafe is positive to the right.
+// Inputs are numbered so the server can acknowledge the ones it applied.
+type PlayerInput struct {
+	Sequence            uint32
+	Forward, Strafe, Up float32
+	Jump, Fly           bool
+	Yaw, Pitch, Delta   float32
+}
+
+// MoveState is everything the next step of a player depends on besides its
+// input. Fall is the vertical speed of a walking player.
+type MoveState struct {
+	Position         Pos
+	Fall             float32
+	OnGround, Flying bool
+}
+
+// Connection keeps one gob encoder and decoder for the lifetime of a
+// connection so type information is only sent once. Send may be called from
+// several goroutines, Receive from one.
+type Connection struct {
+	conn    net.Conn
+	encoder *gob.Encoder
+	decoder *gob.Decoder
+	lock    sync.Mutex
+}
+
+func CreateConnection(conn net.Conn) *Connection {
+	return &Connection{conn: conn, encoder: gob.NewEncoder(conn), decoder: gob.NewDecoder(conn)}
+}
+
+func (connection *Connection) Send(message *Message) error {
+	connection.lock.Lock()
+	defer connection.lock.Unlock()
+	return connection.encoder.Encode(message)
+}
+
+// SendBefore is Send failing once deadline passes, a connection whose send
+// failed this way cannot be used any more. The deadline only applies to
+// this message.
+func (connection *Connection) SendBefore(message *Message, deadline time.Time) error {
+	connection.lock.Lock()
+	defer connection.lock.Unlock()
+	if err := connection.conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+	defer connection.conn.SetWriteDeadline(time.Time{})
+	return connection.encoder.Encode(message)
+}
+
+func (connection *Connection) Receive() (*Message, error) {
+	message := &Message{}
+	if err := connection.decoder.Decode(message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func (connection *Connection) RemoteAddr() net.Addr {
+	return connection.conn.RemoteAddr()
+}
+
+func (connection *Connection) Close() error {
+	return connection.conn.Close()
+}
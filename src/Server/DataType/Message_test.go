@@ -0,0 +1,43 @@
+package DataType
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSendBeforeOnlyLimitsItsMessage(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	sender, receiver := CreateConnection(server), CreateConnection(client)
+	received := make(chan string)
+	go func() {
+		for {
+			message, err := receiver.Receive()
+			if err != nil {
+				close(received)
+				return
+			}
+			received <- message.Text
+		}
+	}()
+
+	if err := sender.SendBefore(&Message{Type: Text, Text: "first"}, time.Now().Add(50*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if text := <-received; text != "first" {
+		t.Fatalf("received %q, want first", text)
+	}
+	// Past the deadline of the first message
+	time.Sleep(100 * time.Millisecond)
+	go func() {
+		if err := sender.Send(&Message{Type: Text, Text: "second"}); err != nil {
+			t.Errorf("Send after a SendBefore deadline passed returned %v", err)
+			client.Close()
+		}
+	}()
+	if text := <-received; text != "second" {
+		t.Errorf("received %q, want second", text)
+	}
+}
@@ -20,17 +20,6 @@ type Pos struct {
 	XPos, YPos, ZPos float32
 }
 
-const (
-	// Cube Types
-	Empty = iota
-	SkyBox
-	Dirt
-	Grass
-	Stone
-	CobbleStone
-	Gravel
-)
-
 func FloorToInt(x float32) int {
 	return int(m.Floor(float64(x)))
 }
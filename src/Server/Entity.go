@@ -0,0 +1,337 @@
+package Server
+
+import (
+	m "math"
+	"sync"
+	"time"
+
+	"github.com/allanks/Voxel-Engine/src/Block"
+	"github.com/allanks/Voxel-Engine/src/Movement"
+	"github.com/allanks/Voxel-Engine/src/Server/DataType"
+)
+
+const (
+	entityTick            = 50 * time.Millisecond
+	entityGravity float32 = 9.8
+	// terrainLoads is how many chunks are loaded for entities after a tick
+	terrainLoads = 9
+	// viewChunks is how many chunks from a player terrain stays loaded
+	viewChunks = (int(viewDistance) / chunkSize) + 1
+)
+
+// entity is a server entity. Controlled entities are players, which are
+// moved by their clients instead of by the tick.
+type entity struct {
+	DataType.Entity
+	brain
+	movement
+	onGround, controlled, moved bool
+	lastMove                    time.Time
+}
+
+// entityState is an entity as the tick left it, for replication. Players
+// with inputs the client has not been told about carry an ack.
+type entityState struct {
+	DataType.Entity
+	moved bool
+	ack   *DataType.PlayerState
+}
+
+var (
+	entityLock   sync.Mutex
+	entities     = map[uint32]*entity{}
+	nextEntityID uint32
+	// terrain caches the blocks entities collide with and wantedChunks the
+	// chunks they ran into that are not loaded yet. terrainEdits counts the
+	// chunks forgotten, so a chunk read before it changed is not cached.
+	// They are only used while entityLock is held.
+	terrain      = blockCache{}
+	wantedChunks = map[[2]int]bool{}
+	terrainEdits int
+	// clock is when player inputs arrive
+	clock = time.Now
+)
+
+// SpawnEntity adds an entity standing at position. Clients near it learn
+// about it on the next tick.
+func SpawnEntity(kind int, position DataType.Pos) uint32 {
+	return addEntity(&entity{Entity: DataType.Entity{Kind: kind, Position: position}, brain: brain{behaviour: kindBehaviours[kind]}})
+}
+
+// spawnPlayer adds the entity of a player, which only its client moves.
+func spawnPlayer(name string, position DataType.Pos) uint32 {
+	return addEntity(&entity{
+		Entity:     DataType.Entity{Kind: DataType.EntityPlayer, Name: name, Position: position},
+		movement:   movement{state: DataType.MoveState{Position: position}, acked: true},
+		controlled: true,
+		lastMove:   clock()})
+}
+
+func addEntity(spawned *entity) uint32 {
+	entityLock.Lock()
+	defer entityLock.Unlock()
+	nextEntityID++
+	spawned.ID = nextEntityID
+	entities[spawned.ID] = spawned
+	return spawned.ID
+}
+
+func DespawnEntity(id uint32) {
+	entityLock.Lock()
+	defer entityLock.Unlock()
+	delete(entities, id)
+}
+
+// SetEntityVelocity changes the velocity of an entity, the y component is
+// replaced by gravity while the entity is falling.
+func SetEntityVelocity(id uint32, velocity DataType.Pos) {
+	entityLock.Lock()
+	defer entityLock.Unlock()
+	if current, ok := entities[id]; ok {
+		current.Velocity = velocity
+	}
+}
+
+// entityPosition returns where an entity is.
+func entityPosition(id uint32) (DataType.Pos, bool) {
+	entityLock.Lock()
+	defer entityLock.Unlock()
+	current, ok := entities[id]
+	if !ok {
+		return DataType.Pos{}, false
+	}
+	return current.Position, true
+}
+
+// teleportEntity moves an entity to a position at once. Players are moved
+// by acknowledging their last input from there, which their client
+// reconciles to.
+func teleportEntity(id uint32, position DataType.Pos) bool {
+	entityLock.Lock()
+	defer entityLock.Unlock()
+	current, ok := entities[id]
+	if !ok {
+		return false
+	}
+	current.Position, current.Velocity = position, DataType.Pos{}
+	current.path, current.moved = nil, true
+	if current.controlled {
+		current.state = DataType.MoveState{Position: position, Flying: current.state.Flying}
+		current.acked = false
+	}
+	return true
+}
+
+// forgetChunks drops the cached blocks of chunks that were changed so
+// entities collide with the new blocks.
+func forgetChunks(changed []*DataType.CubeChunk) {
+	entityLock.Lock()
+	defer entityLock.Unlock()
+	for _, c := range changed {
+		delete(terrain, [2]int{c.XPos, c.ZPos})
+	}
+	terrainEdits++
+}
+
+// applyInput simulates an input of a player, working out its velocity
+// from the move. Inputs that break a rule are refused or changed to follow
+// it, the rule and a description are returned. Refused inputs are still
+// acknowledged so the client falls back to where the server has it.
+func applyInput(id uint32, input *DataType.PlayerInput) (string, string) {
+	entityLock.Lock()
+	defer entityLock.Unlock()
+	current, ok := entities[id]
+	if !ok || !current.controlled {
+		return "", ""
+	}
+	now := clock()
+	elapsed := float32(now.Sub(current.lastMove).Seconds())
+	current.lastMove = now
+	current.sequence, current.acked = input.Sequence, false
+
+	rule, detail := current.validateInput(input, elapsed)
+	if rule == violationSpeed || rule == violationInvalid {
+		return rule, detail
+	}
+	previous := current.Entity
+	current.state = Movement.Step(current.state, *input, serverWorld{})
+	if delta := minFloat(input.Delta, Movement.MaxDelta); delta > 0 {
+		position := current.state.Position
+		current.Velocity = DataType.Pos{
+			XPos: (position.XPos - previous.Position.XPos) / delta,
+			YPos: (position.YPos - previous.Position.YPos) / delta,
+			ZPos: (position.ZPos - previous.Position.ZPos) / delta}
+	}
+	current.Position, current.Yaw = current.state.Position, input.Yaw
+	current.moved = current.moved || current.Entity != previous
+	return rule, detail
+}
+
+// runEntities ticks the entities until Shutdown stops it.
+func runEntities() {
+	ticker := time.NewTicker(entityTick)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !entityTicks.begin() {
+			return
+		}
+		tickEntities(float32(entityTick.Seconds()))
+		entityTicks.end()
+	}
+}
+
+// tickEntities lets every entity think, moves it and replicates the
+// result to the players.
+func tickEntities(delta float32) {
+	entityLock.Lock()
+	states := make([]entityState, 0, len(entities))
+	for _, current := range entities {
+		moved := current.moved
+		if !current.controlled {
+			current.think()
+			moved = current.step(delta)
+		}
+		state := entityState{Entity: current.Entity, moved: moved}
+		if current.controlled && !current.acked {
+			state.ack = &DataType.PlayerState{ID: current.ID, Name: current.Name, CanFly: AllowFlight, Sequence: current.sequence, Move: current.state}
+			current.acked = true
+		}
+		current.moved = false
+		states = append(states, state)
+	}
+	entityLock.Unlock()
+
+	replicate(states)
+	loadTerrain(states)
+}
+
+// loadTerrain drops the cached chunks out of every player's view and loads
+// the chunks around players and the ones entities ran into, a few at a
+// time. Chunks are read without entityLock so players are not held up by
+// the storage.
+func loadTerrain(states []entityState) {
+	players := [][2]int{}
+	for _, state := range states {
+		if state.Kind == DataType.EntityPlayer {
+			x, _ := chunkOf(DataType.FloorToInt(state.Position.XPos))
+			z, _ := chunkOf(DataType.FloorToInt(state.Position.ZPos))
+			players = append(players, [2]int{x, z})
+		}
+	}
+	inView := func(position [2]int) bool {
+		for _, player := range players {
+			if abs(position[0]-player[0]) <= viewChunks && abs(position[1]-player[1]) <= viewChunks {
+				return true
+			}
+		}
+		return false
+	}
+
+	entityLock.Lock()
+	for position := range terrain {
+		if !inView(position) {
+			delete(terrain, position)
+		}
+	}
+	for _, player := range players {
+		for x := player[0] - 1; x <= player[0]+1; x++ {
+			for z := player[1] - 1; z <= player[1]+1; z++ {
+				if _, ok := terrain[[2]int{x, z}]; !ok {
+					wantedChunks[[2]int{x, z}] = true
+				}
+			}
+		}
+	}
+	load := [][2]int{}
+	for position := range wantedChunks {
+		if !inView(position) {
+			delete(wantedChunks, position)
+		} else if len(load) < terrainLoads {
+			load = append(load, position)
+			delete(wantedChunks, position)
+		}
+	}
+	edits := terrainEdits
+	entityLock.Unlock()
+
+	for _, position := range load {
+		blocks, err := chunkBlocks(position[0], position[1])
+		if err == errStopping {
+			return
+		}
+		if err != nil {
+			logf(LogError, "LoadTerrain : ERROR : %s", err)
+			continue
+		}
+		entityLock.Lock()
+		if terrainEdits == edits {
+			terrain[position] = blocks
+		} else {
+			wantedChunks[position] = true
+		}
+		entityLock.Unlock()
+	}
+}
+
+// step moves the entity by its velocity, stopping it at solid blocks and
+// pulling it down until it stands on one. It reports whether the entity
+// moved.
+func (current *entity) step(delta float32) bool {
+	velocity := &current.Velocity
+	if !current.onGround {
+		velocity.YPos -= entityGravity * delta
+	}
+	if velocity.XPos == 0 && velocity.YPos == 0 && velocity.ZPos == 0 {
+		return false
+	}
+
+	position := current.Position
+	next := DataType.Pos{
+		XPos: position.XPos + (velocity.XPos * delta),
+		YPos: position.YPos + (velocity.YPos * delta),
+		ZPos: position.ZPos + (velocity.ZPos * delta)}
+	if isSolid(next.XPos, position.YPos, next.ZPos) {
+		next.XPos, next.ZPos = position.XPos, position.ZPos
+		velocity.XPos, velocity.ZPos = 0, 0
+	}
+	if velocity.YPos < 0 && isSolid(next.XPos, next.YPos, next.ZPos) {
+		next.YPos = float32(DataType.FloorToInt(next.YPos) + 1)
+		velocity.YPos = 0
+	}
+	current.onGround = next.YPos == float32(DataType.FloorToInt(next.YPos)) && isSolid(next.XPos, next.YPos-1, next.ZPos)
+	if velocity.XPos != 0 || velocity.ZPos != 0 {
+		current.Yaw = float32(m.Atan2(float64(velocity.XPos), float64(velocity.ZPos)))
+	}
+	current.Position = next
+	return next != position
+}
+
+// isSolid reports whether the block containing a point is solid. Blocks of
+// chunks that are not loaded are taken as solid so entities do not fall
+// through the world or find paths through it, the chunk is loaded after
+// the tick when a player is near it. entityLock has to be held.
+func isSolid(x, y, z float32) bool {
+	blockX, blockY, blockZ := DataType.FloorToInt(x), DataType.FloorToInt(y), DataType.FloorToInt(z)
+	block, ok := terrain.loaded(blockX, blockY, blockZ)
+	if !ok {
+		chunkX, _ := chunkOf(blockX)
+		chunkZ, _ := chunkOf(blockZ)
+		wantedChunks[[2]int{chunkX, chunkZ}] = true
+		return true
+	}
+	return Block.IsSolid(int(block))
+}
+
+// groundLevel returns the height of the first empty block above the highest
+// solid block in a column. It reads the chunk itself, so it works where no
+// player is. Blocks that could not be read are taken as solid.
+func groundLevel(x, z int) int {
+	column := blockCache{}
+	for y := maxHeight - 1; y >= 0; y-- {
+		block, err := column.get(x, y, z)
+		if err != nil || Block.IsSolid(int(block)) {
+			return y + 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,179 @@
+package Server
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/allanks/Voxel-Engine/src/Block"
+	"github.com/allanks/Voxel-Engine/src/Movement"
+	"github.com/allanks/Voxel-Engine/src/Server/DataType"
+)
+
+// useTestWorld stores the world in a temporary directory and starts the
+// entities with nothing loaded.
+func useTestWorld(t *testing.T) {
+	store, err := openFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := world
+	world, terrain, wantedChunks = store, blockCache{}, map[[2]int]bool{}
+	t.Cleanup(func() {
+		world, terrain, wantedChunks = previous, blockCache{}, map[[2]int]bool{}
+	})
+}
+
+// useTestBlocks generates terrain out of the game's blocks.
+func useTestBlocks(t *testing.T) {
+	if err := Block.LoadBlocks(filepath.Join("..", "..", "resource", "blocks", "blocks.json")); err != nil {
+		t.Fatal(err)
+	}
+	serverNoise[0] = CreateSimplexNoise(Seed, 255.0, 0.5)
+	grass, dirt = Block.ID("grass"), Block.ID("dirt")
+	gravel, stone = Block.ID("gravel"), Block.ID("stone")
+}
+
+func TestIsSolidWantsUnloadedChunks(t *testing.T) {
+	useTestWorld(t)
+	terrain[[2]int{0, 0}] = map[[3]int]uint8{}
+
+	if isSolid(3, 100, 3) {
+		t.Errorf("empty block in a loaded chunk is solid")
+	}
+	if !isSolid(-3, 100, 40) {
+		t.Errorf("block in an unloaded chunk is not solid")
+	}
+	want := map[[2]int]bool{{-1, 2}: true}
+	if len(wantedChunks) != 1 || !wantedChunks[[2]int{-1, 2}] {
+		t.Errorf("wanted chunks = %v, want %v", wantedChunks, want)
+	}
+}
+
+func TestLoadTerrain(t *testing.T) {
+	useTestWorld(t)
+	terrain[[2]int{100, 100}] = map[[3]int]uint8{}
+	wantedChunks[[2]int{50, 50}] = true
+	wantedChunks[[2]int{3, 0}] = true
+	players := []entityState{{Entity: DataType.Entity{Kind: DataType.EntityPlayer, Position: DataType.Pos{XPos: 8, YPos: 70, ZPos: 8}}}}
+
+	loadTerrain(players)
+	if _, ok := terrain[[2]int{100, 100}]; ok {
+		t.Errorf("chunk out of view was kept")
+	}
+	if wantedChunks[[2]int{50, 50}] {
+		t.Errorf("chunk out of view is still wanted")
+	}
+	// The nine chunks around the player and the one an entity ran into,
+	// loaded terrainLoads at a time
+	if len(terrain) != terrainLoads || len(wantedChunks) != 1 {
+		t.Errorf("loaded %v chunks with %v wanted, want %v with 1", len(terrain), len(wantedChunks), terrainLoads)
+	}
+	loadTerrain(players)
+	if len(terrain) != 10 || len(wantedChunks) != 0 {
+		t.Errorf("loaded %v chunks with %v wanted, want 10 with 0", len(terrain), len(wantedChunks))
+	}
+	for x := -1; x <= 1; x++ {
+		for z := -1; z <= 1; z++ {
+			if _, ok := terrain[[2]int{x, z}]; !ok {
+				t.Errorf("chunk %v %v next to the player was not loaded", x, z)
+			}
+		}
+	}
+}
+
+// clientWorld is the client's own copy of the world, read from the storage
+// the way the client's chunks are.
+type clientWorld blockCache
+
+func (world clientWorld) IsSolid(x, y, z int) bool {
+	block, err := blockCache(world).get(x, y, z)
+	return err == nil && Block.IsSolid(int(block))
+}
+
+// delayed is an input or acknowledgement on its way, arriving on a frame.
+type delayed struct {
+	arrives  int
+	input    DataType.PlayerInput
+	sequence uint32
+	state    DataType.MoveState
+}
+
+func TestPredictionConvergesOverLatency(t *testing.T) {
+	useTestWorld(t)
+	useTestBlocks(t)
+	for x := -3; x <= 3; x++ {
+		for z := -3; z <= 3; z++ {
+			blocks, err := chunkBlocks(x, z)
+			if err != nil {
+				t.Fatal(err)
+			}
+			terrain[[2]int{x, z}] = blocks
+		}
+	}
+	began := time.Unix(0, 0)
+	now := began
+	clock = func() time.Time { return now }
+	t.Cleanup(func() { clock = time.Now })
+
+	spawn := DataType.Pos{XPos: 0.5, YPos: float32(groundLevel(0, 0)), ZPos: 0.5}
+	id := spawnPlayer("tester", spawn)
+	t.Cleanup(func() { DespawnEntity(id) })
+	client := Movement.Prediction{}
+	client.Reset(DataType.MoveState{Position: spawn})
+	world := clientWorld{}
+
+	// Messages take 3 to 7 frames each way but stay in order, like they
+	// would over TCP. The player walks in a circle, jumping now and then.
+	const frames, frame = 600, float32(1.0 / 60)
+	arrival := func(sent, last int) int {
+		return maxInt(sent+3+((sent*7)%5), last)
+	}
+	inputs, acks := []delayed{}, []delayed{}
+	lastInput, lastAck := 0, 0
+	for f := 0; f < frames+30; f++ {
+		now = began.Add(time.Duration(f) * time.Second / 60)
+		if f < frames {
+			input := client.Predict(DataType.PlayerInput{Forward: 1, Yaw: float32(f) * 0.01, Jump: f%45 == 0, Delta: frame}, world)
+			lastInput = arrival(f, lastInput)
+			inputs = append(inputs, delayed{arrives: lastInput, input: input})
+		}
+
+		for len(inputs) > 0 && inputs[0].arrives <= f {
+			if rule, detail := applyInput(id, &inputs[0].input); rule != "" {
+				t.Fatalf("frame %v: input %v broke %v, %v", f, inputs[0].input.Sequence, rule, detail)
+			}
+			inputs = inputs[1:]
+		}
+		// The entity tick acknowledges every third frame
+		if f%3 == 0 {
+			entityLock.Lock()
+			current := entities[id]
+			if !current.acked {
+				lastAck = arrival(f, lastAck)
+				acks = append(acks, delayed{arrives: lastAck, sequence: current.sequence, state: current.state})
+				current.acked = true
+			}
+			entityLock.Unlock()
+		}
+
+		for len(acks) > 0 && acks[0].arrives <= f {
+			predicted := client.State
+			client.Reconcile(acks[0].sequence, acks[0].state, world)
+			if client.State != predicted {
+				t.Fatalf("frame %v: reconciling with input %v snapped the player from %v to %v", f, acks[0].sequence, predicted.Position, client.State.Position)
+			}
+			acks = acks[1:]
+		}
+	}
+
+	entityLock.Lock()
+	server := entities[id].state
+	entityLock.Unlock()
+	if client.State != server {
+		t.Errorf("client ended at %v, server at %v", client.State.Position, server.Position)
+	}
+	if distance3(server.Position, spawn) < 1 {
+		t.Errorf("player stayed at %v", server.Position)
+	}
+}
@@ -0,0 +1,109 @@
+package Server
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/allanks/Voxel-Engine/src/Server/DataType"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// fileStorage keeps every chunk with its cubes in a gob file of its own.
+// Files are replaced whole, so a chunk is never left half written, and are
+// only written with the cubes, so a chunk is not found before its cubes are
+// stored.
+type fileStorage struct {
+	directory string
+}
+
+type chunkFile struct {
+	Chunk DataType.Chunk
+	Cubes []*cube
+}
+
+func openFileStorage(directory string) (*fileStorage, error) {
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, err
+	}
+	return &fileStorage{directory}, nil
+}
+
+func (store *fileStorage) file(c *DataType.Chunk) string {
+	return filepath.Join(store.directory, fmt.Sprintf("chunk.%v.%v.gob", c.XPos, c.ZPos))
+}
+
+// read returns the stored chunk, nil if the chunk has no file.
+func (store *fileStorage) read(c *DataType.Chunk) (*chunkFile, error) {
+	file, err := os.Open(store.file(c))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	stored := &chunkFile{}
+	if err = gob.NewDecoder(file).Decode(stored); err != nil {
+		return nil, fmt.Errorf("%v: %v", file.Name(), err)
+	}
+	return stored, nil
+}
+
+func (store *fileStorage) write(stored *chunkFile) error {
+	temporary, err := ioutil.TempFile(store.directory, "chunk")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporary.Name())
+	err = gob.NewEncoder(temporary).Encode(stored)
+	if err == nil {
+		err = temporary.Sync()
+	}
+	if closeErr := temporary.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(temporary.Name(), store.file(&stored.Chunk))
+}
+
+func (store *fileStorage) findChunk(c *DataType.Chunk) (bool, error) {
+	stored, err := store.read(c)
+	if stored == nil || err != nil {
+		return false, err
+	}
+	c.ID = stored.Chunk.ID
+	return true, nil
+}
+
+func (store *fileStorage) createChunk(c *DataType.Chunk) error {
+	c.ID = bson.NewObjectId()
+	return nil
+}
+
+func (store *fileStorage) loadCubes(c *DataType.Chunk) ([]*cube, error) {
+	stored, err := store.read(c)
+	if stored == nil || err != nil {
+		return []*cube{}, err
+	}
+	return stored.Cubes, nil
+}
+
+func (store *fileStorage) saveCubes(c *DataType.Chunk, cubes []*cube) error {
+	for _, current := range cubes {
+		current.ChunkID = c.ID
+	}
+	return store.write(&chunkFile{*c, cubes})
+}
+
+func (store *fileStorage) flush() error {
+	return nil
+}
+
+func (store *fileStorage) close() error {
+	return nil
+}
@@ -0,0 +1,40 @@
+package Server
+
+import (
+	"fmt"
+	"log"
+)
+
+// Log levels, messages below LogLevel are dropped. Debug and info messages
+// are printed, warnings and errors go to the log file.
+const (
+	LogDebug = iota
+	LogInfo
+	LogWarn
+	LogError
+)
+
+// LogLevel is the least level logged
+var LogLevel = LogInfo
+
+var logLevels = map[string]int{"debug": LogDebug, "info": LogInfo, "warn": LogWarn, "error": LogError}
+
+// ParseLogLevel returns the level called name.
+func ParseLogLevel(name string) (int, error) {
+	level, ok := logLevels[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", name)
+	}
+	return level, nil
+}
+
+func logf(level int, format string, args ...interface{}) {
+	if level < LogLevel {
+		return
+	}
+	if level >= LogWarn {
+		log.Printf(format, args...)
+		return
+	}
+	fmt.Printf(format+"\n", args...)
+}
@@ -0,0 +1,81 @@
+package Server
+
+import (
+	"github.com/allanks/Voxel-Engine/src/Server/DataType"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// mongoStorage keeps chunks and cubes in two collections of a MongoDB
+// database, cubes are indexed by the ID of their chunk.
+type mongoStorage struct {
+	session *mgo.Session
+}
+
+func openMongoStorage(address string) (*mongoStorage, error) {
+	session, err := mgo.Dial(address)
+	if err != nil {
+		return nil, err
+	}
+	index := mgo.Index{
+		Key: []string{"chunkid"},
+	}
+	if err = session.DB("GameDatabase").C("Cubes").EnsureIndex(index); err != nil {
+		session.Close()
+		return nil, err
+	}
+	return &mongoStorage{session}, nil
+}
+
+func (store *mongoStorage) findChunk(c *DataType.Chunk) (bool, error) {
+	session := store.session.Copy()
+	defer session.Close()
+	err := session.DB("GameDatabase").C("Chunks").Find(bson.M{"xpos": c.XPos, "zpos": c.ZPos}).One(c)
+	if err == mgo.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (store *mongoStorage) createChunk(c *DataType.Chunk) error {
+	session := store.session.Copy()
+	defer session.Close()
+	c.ID = bson.NewObjectId()
+	return session.DB("GameDatabase").C("Chunks").Insert(c)
+}
+
+func (store *mongoStorage) loadCubes(c *DataType.Chunk) ([]*cube, error) {
+	session := store.session.Copy()
+	defer session.Close()
+	cubes := []*cube{}
+	err := session.DB("GameDatabase").C("Cubes").Find(bson.M{"chunkid": c.ID}).All(&cubes)
+	return cubes, err
+}
+
+func (store *mongoStorage) saveCubes(c *DataType.Chunk, cubes []*cube) error {
+	session := store.session.Copy()
+	defer session.Close()
+	collection := session.DB("GameDatabase").C("Cubes")
+	if _, err := collection.RemoveAll(bson.M{"chunkid": c.ID}); err != nil {
+		return err
+	}
+	if len(cubes) == 0 {
+		return nil
+	}
+	bulk := collection.Bulk()
+	for _, current := range cubes {
+		current.ChunkID = c.ID
+		bulk.Insert(current)
+	}
+	_, err := bulk.Run()
+	return err
+}
+
+func (store *mongoStorage) flush() error {
+	return store.session.Fsync(false)
+}
+
+func (store *mongoStorage) close() error {
+	store.session.Close()
+	return nil
+}
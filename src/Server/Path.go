@@ -0,0 +1,151 @@
+package Server
+
+import "container/heap"
+
+// pathfinder searches the block grid for a route an entity can walk. A
+// position is standable when the block below it is solid and the height
+// blocks from it up are not. Entities walk to the four neighbouring
+// columns, climbing up to jumpHeight blocks and dropping up to maxDrop.
+type pathfinder struct {
+	solid                       func(x, y, z int) bool
+	height, jumpHeight, maxDrop int
+	// stepHeight is the climb walking takes without a jump, climbs above it
+	// cost jumpCost
+	stepHeight, jumpCost int
+	maxNodes             int
+}
+
+type pathNode struct {
+	position  [3]int
+	cost      int
+	estimate  int
+	index     int
+	parent    *pathNode
+	processed bool
+}
+
+type nodeQueue []*pathNode
+
+func (queue nodeQueue) Len() int { return len(queue) }
+func (queue nodeQueue) Less(i, j int) bool {
+	return queue[i].cost+queue[i].estimate < queue[j].cost+queue[j].estimate
+}
+func (queue nodeQueue) Swap(i, j int) {
+	queue[i], queue[j] = queue[j], queue[i]
+	queue[i].index, queue[j].index = i, j
+}
+func (queue *nodeQueue) Push(x interface{}) {
+	node := x.(*pathNode)
+	node.index = len(*queue)
+	*queue = append(*queue, node)
+}
+func (queue *nodeQueue) Pop() interface{} {
+	old := *queue
+	node := old[len(old)-1]
+	*queue = old[:len(old)-1]
+	return node
+}
+
+var pathDirections = [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+
+// standable reports whether an entity can stand with its feet in a block.
+func (finder *pathfinder) standable(x, y, z int) bool {
+	return finder.solid(x, y-1, z) && finder.clear(x, y, y+finder.height-1, z)
+}
+
+// clear reports whether the blocks from bottom to top in a column are free.
+func (finder *pathfinder) clear(x, bottom, top, z int) bool {
+	for y := bottom; y <= top; y++ {
+		if finder.solid(x, y, z) {
+			return false
+		}
+	}
+	return true
+}
+
+// findPath returns the positions from start to goal, both included, or nil
+// when goal cannot be reached within maxNodes expanded positions.
+func (finder *pathfinder) findPath(start, goal [3]int) [][3]int {
+	if !finder.standable(goal[0], goal[1], goal[2]) {
+		return nil
+	}
+	nodes := map[[3]int]*pathNode{}
+	open := &nodeQueue{}
+	first := &pathNode{position: start, estimate: distance(start, goal)}
+	nodes[start] = first
+	heap.Push(open, first)
+
+	for expanded := 0; open.Len() > 0 && expanded < finder.maxNodes; expanded++ {
+		current := heap.Pop(open).(*pathNode)
+		current.processed = true
+		if current.position == goal {
+			return current.path()
+		}
+		for _, next := range finder.neighbours(current.position) {
+			cost := current.cost + 1
+			if next[1]-current.position[1] > finder.stepHeight {
+				cost += finder.jumpCost
+			}
+			node, seen := nodes[next]
+			if !seen {
+				node = &pathNode{position: next, cost: cost, estimate: distance(next, goal), parent: current}
+				nodes[next] = node
+				heap.Push(open, node)
+			} else if !node.processed && cost < node.cost {
+				node.cost, node.parent = cost, current
+				heap.Fix(open, node.index)
+			}
+		}
+	}
+	return nil
+}
+
+// neighbours returns the standable positions reachable in one move.
+func (finder *pathfinder) neighbours(position [3]int) [][3]int {
+	x, y, z := position[0], position[1], position[2]
+	reachable := [][3]int{}
+	for _, direction := range pathDirections {
+		nx, nz := x+direction[0], z+direction[1]
+		// climbing needs headroom above the current position, dropping needs
+		// the column being dropped down to be free
+		for dy := finder.jumpHeight; dy >= -finder.maxDrop; dy-- {
+			if dy > 0 && !finder.clear(x, y+finder.height, y+finder.height+dy-1, z) {
+				continue
+			}
+			if dy < 0 && !finder.clear(nx, y+dy+finder.height, y+finder.height-1, nz) {
+				break
+			}
+			if finder.standable(nx, y+dy, nz) {
+				reachable = append(reachable, [3]int{nx, y + dy, nz})
+				break
+			}
+		}
+	}
+	return reachable
+}
+
+func (node *pathNode) path() [][3]int {
+	length := 0
+	for current := node; current != nil; current = current.parent {
+		length++
+	}
+	path := make([][3]int, length)
+	for current := node; current != nil; current = current.parent {
+		length--
+		path[length] = current.position
+	}
+	return path
+}
+
+// distance estimates the cost between two positions. It leaves out the
+// height, a single move can drop several blocks, so it never overestimates.
+func distance(a, b [3]int) int {
+	return abs(a[0]-b[0]) + abs(a[2]-b[2])
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,95 @@
+package Server
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testTerrain is a world of columns, each solid up to its height and empty
+// above. Columns without a height are floor high.
+type testTerrain struct {
+	heights map[[2]int]int
+	floor   int
+}
+
+func (terrain testTerrain) solid(x, y, z int) bool {
+	height, ok := terrain.heights[[2]int{x, z}]
+	if !ok {
+		height = terrain.floor
+	}
+	return y < height
+}
+
+// createCorridor returns a corridor along x from 0 at z 0 with the column
+// heights given, walled in on every other side.
+func createCorridor(heights ...int) testTerrain {
+	terrain := testTerrain{heights: map[[2]int]int{}, floor: maxHeight}
+	for x, height := range heights {
+		terrain.heights[[2]int{x, 0}] = height
+	}
+	return terrain
+}
+
+// createTestFinder returns the pathfinder entities use, walking solid.
+func createTestFinder(solid func(x, y, z int) bool) *pathfinder {
+	finder := *entityPaths
+	finder.solid = solid
+	return &finder
+}
+
+func TestFindPath(t *testing.T) {
+	tests := []struct {
+		name        string
+		terrain     testTerrain
+		start, goal [3]int
+		want        [][3]int
+	}{
+		{"flat", createCorridor(1, 1, 1, 1), [3]int{0, 1, 0}, [3]int{3, 1, 0},
+			[][3]int{{0, 1, 0}, {1, 1, 0}, {2, 1, 0}, {3, 1, 0}}},
+		{"step up", createCorridor(1, 1, 2, 2), [3]int{0, 1, 0}, [3]int{3, 2, 0},
+			[][3]int{{0, 1, 0}, {1, 1, 0}, {2, 2, 0}, {3, 2, 0}}},
+		{"wall above jump height", createCorridor(1, 1, 3, 3), [3]int{0, 1, 0}, [3]int{3, 3, 0}, nil},
+		{"drop within max drop", createCorridor(4, 4, 1, 1), [3]int{0, 4, 0}, [3]int{3, 1, 0},
+			[][3]int{{0, 4, 0}, {1, 4, 0}, {2, 1, 0}, {3, 1, 0}}},
+		{"drop beyond max drop", createCorridor(5, 5, 1, 1), [3]int{0, 5, 0}, [3]int{3, 1, 0}, nil},
+		{"goal inside a wall", createCorridor(1, 1, 1, 1), [3]int{0, 1, 0}, [3]int{3, 0, 0}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := createTestFinder(test.terrain.solid).findPath(test.start, test.goal)
+			if !reflect.DeepEqual(path, test.want) {
+				t.Errorf("path = %v, want %v", path, test.want)
+			}
+		})
+	}
+}
+
+func TestFindPathUnreachableStopsAtMaxNodes(t *testing.T) {
+	// An open floor with the goal walled in, the search runs out of nodes
+	// before it runs out of floor
+	terrain := testTerrain{heights: map[[2]int]int{}, floor: 1}
+	goal := [3]int{20, 1, 20}
+	for x := goal[0] - 1; x <= goal[0]+1; x++ {
+		for z := goal[2] - 1; z <= goal[2]+1; z++ {
+			if x != goal[0] || z != goal[2] {
+				terrain.heights[[2]int{x, z}] = 4
+			}
+		}
+	}
+	calls := 0
+	finder := createTestFinder(func(x, y, z int) bool {
+		calls++
+		return terrain.solid(x, y, z)
+	})
+	finder.maxNodes = 200
+
+	if path := finder.findPath([3]int{0, 1, 0}, goal); path != nil {
+		t.Fatalf("path = %v, want nil", path)
+	}
+	// Every expanded node looks at four columns, each at most a climb and
+	// a drop high
+	perNode := len(pathDirections) * (finder.jumpHeight + finder.maxDrop + 1) * (finder.height + finder.jumpHeight + finder.maxDrop + 1)
+	if limit := (finder.maxNodes * perNode) + finder.height + 1; calls > limit {
+		t.Errorf("solid called %v times, want at most %v", calls, limit)
+	}
+}
@@ -0,0 +1,266 @@
+package Server
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/allanks/Voxel-Engine/src/Server/DataType"
+)
+
+const (
+	pregenJournalFile string = "pregen.journal"
+	pregenProgress           = time.Second
+)
+
+// PregenWorkers is how many chunks are pregenerated at once
+var PregenWorkers = runtime.NumCPU()
+
+// PregenSummary is what a pregeneration did. Chunks counts every chunk it
+// went through, Generated the ones that were not stored yet.
+type PregenSummary struct {
+	Chunks, Generated int
+	Elapsed           time.Duration
+}
+
+func (summary PregenSummary) String() string {
+	return fmt.Sprintf("%v chunks, %v generated, in %v", summary.Chunks, summary.Generated, summary.Elapsed.Truncate(time.Millisecond))
+}
+
+type pregenJob struct {
+	position [2]int
+	redo     bool
+}
+
+type pregenResult struct {
+	generated bool
+	err       error
+}
+
+// ParsePregenRegion reads a region in chunk coordinates given as
+// "radius [x z]", around chunk 0 0 by default, or as "x0 z0 x1 z1".
+func ParsePregenRegion(args []string) (int, int, int, int, error) {
+	values := make([]int, len(args))
+	for i, arg := range args {
+		value, err := strconv.Atoi(arg)
+		if err != nil {
+			return 0, 0, 0, 0, fmt.Errorf("%q is not a chunk coordinate", arg)
+		}
+		values[i] = value
+	}
+	switch len(values) {
+	case 1:
+		values = append(values, 0, 0)
+		fallthrough
+	case 3:
+		radius, x, z := values[0], values[1], values[2]
+		if radius < 0 {
+			return 0, 0, 0, 0, fmt.Errorf("radius %v is negative", radius)
+		}
+		return x - radius, z - radius, x + radius, z + radius, nil
+	case 4:
+		return values[0], values[1], values[2], values[3], nil
+	}
+	return 0, 0, 0, 0, fmt.Errorf("expected radius [x z] or x0 z0 x1 z1")
+}
+
+// Pregenerate generates and stores every chunk from x0, z0 to x1, z1 in
+// chunk coordinates that was never generated, nearest the centre first,
+// with PregenWorkers workers. Chunks an interrupted pregeneration left
+// half stored are generated again first, so running it again after it was
+// interrupted finishes the job. It stops early when the server stops.
+func Pregenerate(x0, z0, x1, z1 int) (PregenSummary, error) {
+	if PregenWorkers < 1 {
+		return PregenSummary{}, fmt.Errorf("%v workers cannot pregenerate, at least 1 is needed", PregenWorkers)
+	}
+	began := time.Now()
+	journal, err := openPregenJournal(filepath.Join(WorldDirectory, pregenJournalFile))
+	if err != nil {
+		return PregenSummary{}, err
+	}
+	damaged := journal.unfinished()
+	skip := map[[2]int]bool{}
+	total := len(damaged) + regionArea(x0, z0, x1, z1)
+	for _, position := range damaged {
+		if !skip[position] && inRegion(position, x0, z0, x1, z1) {
+			total--
+		}
+		skip[position] = true
+	}
+	logf(LogInfo, "Pregenerating %v chunks from %v %v to %v %v with %v workers, %v left unfinished before", total, x0, z0, x1, z1, PregenWorkers, len(damaged))
+
+	jobs := make(chan pregenJob)
+	results := make(chan pregenResult)
+	cancel := make(chan struct{})
+	var cancelOnce sync.Once
+	go func() {
+		defer close(jobs)
+		send := func(job pregenJob) bool {
+			select {
+			case jobs <- job:
+				return true
+			case <-cancel:
+			case <-stopped:
+			}
+			return false
+		}
+		for _, position := range damaged {
+			if !send(pregenJob{position, true}) {
+				return
+			}
+		}
+		walkRegion(x0, z0, x1, z1, func(position [2]int) bool {
+			return skip[position] || send(pregenJob{position, false})
+		})
+	}()
+
+	var workers sync.WaitGroup
+	for i := 0; i < PregenWorkers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for job := range jobs {
+				generated, err := pregenerateChunk(job.position, journal, job.redo)
+				results <- pregenResult{generated, err}
+			}
+		}()
+	}
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+
+	summary := PregenSummary{}
+	ticker := time.NewTicker(pregenProgress)
+	defer ticker.Stop()
+	for results != nil {
+		select {
+		case result, ok := <-results:
+			if !ok {
+				results = nil
+				continue
+			}
+			if result.err != nil {
+				if err == nil {
+					err = result.err
+				}
+				cancelOnce.Do(func() { close(cancel) })
+				continue
+			}
+			summary.Chunks++
+			if result.generated {
+				summary.Generated++
+			}
+		case <-ticker.C:
+			elapsed := time.Since(began).Seconds()
+			logf(LogInfo, "Pregenerated %v of %v chunks (%v%%), %.1f chunks a second", summary.Chunks, total, (summary.Chunks*100)/maxInt(total, 1), float64(summary.Chunks)/elapsed)
+		}
+	}
+	summary.Elapsed = time.Since(began)
+
+	if err == nil && summary.Chunks < total {
+		err = errStopping
+	}
+	if err != nil {
+		journal.close()
+		return summary, err
+	}
+	return summary, journal.remove()
+}
+
+// pregenerateChunk generates and stores a chunk unless it is stored already,
+// reporting whether it did. Chunks are generated again when redo is set.
+// The chunk is locked throughout, so players loading it while the server
+// runs wait for it instead of generating it as well.
+func pregenerateChunk(position [2]int, journal *pregenJournal, redo bool) (bool, error) {
+	unlock := lockChunk(position[0], position[1])
+	defer unlock()
+	c := &DataType.Chunk{XPos: position[0], ZPos: position[1]}
+	found, err := world.findChunk(c)
+	if err != nil || (found && !redo) {
+		return false, err
+	}
+	if !inFlight.begin() {
+		return false, errStopping
+	}
+	defer inFlight.end()
+
+	if err = journal.record(journalStart, position); err != nil {
+		return false, err
+	}
+	if !found {
+		if err = world.createChunk(c); err != nil {
+			return false, err
+		}
+	}
+	cubes := generateCubes(c)
+	filter(cubes)
+	if err = world.saveCubes(c, cubes); err != nil {
+		return false, err
+	}
+	return true, journal.record(journalDone, position)
+}
+
+// walkRegion visits the chunks from x0, z0 to x1, z1 by their distance
+// from the centre of the region, nearest first, until visit returns false.
+// Chunks as far from the centre are visited by x and then z. The chunks are
+// worked out ring by ring, so regions of any size take no memory.
+func walkRegion(x0, z0, x1, z1 int, visit func([2]int) bool) {
+	minX, maxX, minZ, maxZ := minInt(x0, x1), maxInt(x0, x1), minInt(z0, z1), maxInt(z0, z1)
+	// Doubled so the centre of an even region stays whole
+	centreX, centreZ := minX+maxX, minZ+maxZ
+	for distance := 0; distance <= (maxX-minX)+(maxZ-minZ); distance++ {
+		// x has to be within distance of the centre but not so near it that
+		// the rest of the distance leaves the region along z. >> rounds down
+		// for negative coordinates as well.
+		first, last := maxInt(minX, (centreX-distance+1)>>1), minInt(maxX, (centreX+distance)>>1)
+		spans := [][2]int{{first, last}}
+		if inner := distance - (maxZ - minZ); inner > 0 {
+			spans = [][2]int{{first, minInt(last, (centreX-inner)>>1)}, {maxInt(first, (centreX+inner+1)>>1), last}}
+		}
+		for _, span := range spans {
+			for x := span[0]; x <= span[1]; x++ {
+				rest := distance - abs((x*2)-centreX)
+				if (centreZ+rest)%2 != 0 {
+					continue
+				}
+				sides := []int{(centreZ - rest) / 2, (centreZ + rest) / 2}
+				if rest == 0 {
+					sides = sides[:1]
+				}
+				for _, z := range sides {
+					if z >= minZ && z <= maxZ && !visit([2]int{x, z}) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+// regionArea returns how many chunks there are from x0, z0 to x1, z1.
+func regionArea(x0, z0, x1, z1 int) int {
+	return (abs(x1-x0) + 1) * (abs(z1-z0) + 1)
+}
+
+func inRegion(position [2]int, x0, z0, x1, z1 int) bool {
+	return position[0] >= minInt(x0, x1) && position[0] <= maxInt(x0, x1) &&
+		position[1] >= minInt(z0, z1) && position[1] <= maxInt(z0, z1)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,86 @@
+package Server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	journalStart string = "start"
+	journalDone         = "done"
+)
+
+// pregenJournal records which chunks a pregeneration started and finished,
+// so chunks it was interrupted storing can be generated again.
+type pregenJournal struct {
+	lock    sync.Mutex
+	file    *os.File
+	started map[[2]int]bool
+}
+
+// openPregenJournal opens the journal at path, reading what an earlier
+// pregeneration left in it.
+func openPregenJournal(path string) (*pregenJournal, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+	contents, err := ioutil.ReadAll(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	journal := &pregenJournal{file: file, started: map[[2]int]bool{}}
+	lines := strings.Split(string(contents), "\n")
+	// The last line is empty, or torn if writing it was interrupted
+	for _, line := range lines[:len(lines)-1] {
+		var entry string
+		var position [2]int
+		if _, err := fmt.Sscan(line, &entry, &position[0], &position[1]); err == nil {
+			journal.started[position] = entry == journalStart
+		}
+	}
+	if torn := lines[len(lines)-1]; torn != "" {
+		logf(LogWarn, "Pregenerate : WARN : ignoring torn journal entry %q", torn)
+		// Later entries have to start on a line of their own
+		if _, err = file.WriteString("\n"); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return journal, nil
+}
+
+// unfinished returns the chunks that were started but never done.
+func (journal *pregenJournal) unfinished() [][2]int {
+	chunks := [][2]int{}
+	for position, started := range journal.started {
+		if started {
+			chunks = append(chunks, position)
+		}
+	}
+	return chunks
+}
+
+// record appends an entry for a chunk and syncs it to disk.
+func (journal *pregenJournal) record(entry string, position [2]int) error {
+	journal.lock.Lock()
+	defer journal.lock.Unlock()
+	if _, err := fmt.Fprintf(journal.file, "%v %v %v\n", entry, position[0], position[1]); err != nil {
+		return err
+	}
+	return journal.file.Sync()
+}
+
+func (journal *pregenJournal) close() error {
+	return journal.file.Close()
+}
+
+// remove deletes the journal once the pregeneration it records is finished.
+func (journal *pregenJournal) remove() error {
+	journal.close()
+	return os.Remove(journal.file.Name())
+}
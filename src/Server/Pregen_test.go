@@ -0,0 +1,142 @@
+package Server
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/allanks/Voxel-Engine/src/Server/DataType"
+)
+
+// sortedRegion returns every chunk of a region sorted the way walkRegion
+// visits them.
+func sortedRegion(x0, z0, x1, z1 int) [][2]int {
+	chunks := [][2]int{}
+	for x := minInt(x0, x1); x <= maxInt(x0, x1); x++ {
+		for z := minInt(z0, z1); z <= maxInt(z0, z1); z++ {
+			chunks = append(chunks, [2]int{x, z})
+		}
+	}
+	distance := func(position [2]int) int {
+		return abs((position[0]*2)-(x0+x1)) + abs((position[1]*2)-(z0+z1))
+	}
+	sort.SliceStable(chunks, func(i, j int) bool {
+		return distance(chunks[i]) < distance(chunks[j])
+	})
+	return chunks
+}
+
+func TestWalkRegion(t *testing.T) {
+	regions := [][4]int{
+		{0, 0, 0, 0},
+		{-1, -1, 1, 1},
+		{-3, -2, 4, 5},
+		{5, 5, -2, -1},
+		{0, 0, 9, 0},
+		{0, -7, 0, 0},
+		{-10, -3, -1, 8},
+		{-20, 3, 20, 6},
+	}
+	for _, region := range regions {
+		visited := [][2]int{}
+		walkRegion(region[0], region[1], region[2], region[3], func(position [2]int) bool {
+			visited = append(visited, position)
+			return true
+		})
+		if want := sortedRegion(region[0], region[1], region[2], region[3]); !reflect.DeepEqual(visited, want) {
+			t.Errorf("region %v visited %v, want %v", region, visited, want)
+		}
+		if area := regionArea(region[0], region[1], region[2], region[3]); area != len(visited) {
+			t.Errorf("region %v has an area of %v, visited %v", region, area, len(visited))
+		}
+	}
+}
+
+func TestWalkRegionStreams(t *testing.T) {
+	// Far too large to hold in memory, only the centre is walked
+	visited := 0
+	walkRegion(-100000, -100000, 100000, 100000, func(position [2]int) bool {
+		if visited == 0 && position != [2]int{0, 0} {
+			t.Errorf("first visited %v, want the centre", position)
+		}
+		visited++
+		return visited < 1000
+	})
+	if visited != 1000 {
+		t.Errorf("visited %v chunks, want 1000", visited)
+	}
+
+	// A long thin region does not walk the rings that left it
+	visited = 0
+	walkRegion(-200000, 0, 200000, 1, func(position [2]int) bool {
+		visited++
+		return true
+	})
+	if visited != 800002 {
+		t.Errorf("visited %v chunks, want 800002", visited)
+	}
+}
+
+func TestPregenerate(t *testing.T) {
+	useTestWorld(t)
+	useTestBlocks(t)
+	directory := WorldDirectory
+	WorldDirectory = t.TempDir()
+	t.Cleanup(func() { WorldDirectory = directory })
+
+	summary, err := Pregenerate(-1, -1, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Chunks != 9 || summary.Generated != 9 {
+		t.Errorf("pregenerated %v, want 9 chunks generated", summary)
+	}
+	summary, err = Pregenerate(-2, -1, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Chunks != 12 || summary.Generated != 3 {
+		t.Errorf("pregenerated %v, want 12 chunks with 3 generated", summary)
+	}
+}
+
+func TestPregenerateNeedsWorkers(t *testing.T) {
+	workers := PregenWorkers
+	PregenWorkers = 0
+	t.Cleanup(func() { PregenWorkers = workers })
+	if _, err := Pregenerate(0, 0, 0, 0); err == nil {
+		t.Error("pregenerated without workers")
+	}
+}
+
+func TestPregenerateWhilePlayersLoad(t *testing.T) {
+	store := useMemoryStorage(t, time.Millisecond)
+	useTestShutdown(t)
+	directory := WorldDirectory
+	WorldDirectory = t.TempDir()
+	t.Cleanup(func() { WorldDirectory = directory })
+
+	var players sync.WaitGroup
+	for player := 0; player < 4; player++ {
+		players.Add(1)
+		go func() {
+			defer players.Done()
+			walkRegion(-1, -1, 1, 1, func(position [2]int) bool {
+				if chunk := loadChunk(&DataType.Chunk{XPos: position[0], ZPos: position[1]}); len(chunk.Cubes) == 0 {
+					t.Errorf("player loaded chunk %v empty", position)
+				}
+				return true
+			})
+		}()
+	}
+	if _, err := Pregenerate(-1, -1, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	players.Wait()
+	inFlight.wait()
+	if store.creates != 9 {
+		t.Errorf("%v chunks created, want each of the 9 once", store.creates)
+	}
+}
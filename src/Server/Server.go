@@ -1,30 +1,44 @@
 package Server
 
 import (
-	"encoding/gob"
 	"fmt"
-	"log"
 	"net"
 	"os"
+	"sync"
+	"time"
 
+	"github.com/allanks/Voxel-Engine/src/Block"
 	"github.com/allanks/Voxel-Engine/src/Server/DataType"
-	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
 const (
-	mongodb   string = "localhost:27017"
-	chunkSize int    = 16
-	maxHeight int    = 128
-	seaLevel  int    = 64
+	chunkSize int = 16
+	maxHeight int = 128
+	seaLevel  int = 64
 )
 
 var (
-	logFile      *os.File
-	mongoSession *mgo.Session
-	cubeLoader   []cube
+	logFile *os.File
+	// Seed is the seed of the terrain noise
+	Seed int64 = 200
+	// Port is the TCP port clients connect to
+	Port    = 8080
+	started time.Time
+	// listener is closed by Stop, it is only used while listenLock is held
+	listener   net.Listener
+	listenLock sync.Mutex
+	stopped    = make(chan struct{})
+	stopOnce   sync.Once
 )
 
+// Blocks the terrain generator places, resolved from the block registry by
+// LoadGameMap
+var grass, dirt, gravel, stone uint8
+
+// sides are the offsets to the six neighbours of a cube
+var sides = [][3]int{{1, 0, 0}, {-1, 0, 0}, {0, 1, 0}, {0, -1, 0}, {0, 0, 1}, {0, 0, -1}}
+
 var serverNoise = []*SimplexNoise{
 	&SimplexNoise{},
 }
@@ -37,64 +51,145 @@ type cube struct {
 	Visible          bool
 }
 
+// InitServer serves clients until Stop is called, then shuts the server
+// down.
 func InitServer() {
-	createDatabaseLink()
-	fmt.Println("Listening")
-	ln, err := net.Listen("tcp", ":8080")
+	started = time.Now()
+	if world == nil {
+		openStorage()
+	}
+	SpawnEntity(DataType.EntityGopher, DataType.Pos{XPos: 3.5, YPos: float32(groundLevel(3, 5)), ZPos: 5.5})
+	go runEntities()
+
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%v", Port))
 	if err != nil {
 		panic(err)
 	}
+	listenLock.Lock()
+	listener = ln
+	listenLock.Unlock()
+	select {
+	case <-stopped:
+		ln.Close()
+	default:
+		logf(LogInfo, "Listening on port %v", Port)
+	}
 	for {
 		conn, err := ln.Accept() // this blocks until connection or error
 		if err != nil {
-			fmt.Printf("Error accepting connection %v\n", err)
+			select {
+			case <-stopped:
+				Shutdown()
+				return
+			default:
+			}
+			logf(LogError, "Error accepting connection %v", err)
 			continue
 		}
-		go getChunk(conn) // a goroutine handles conn so that the loop can accept other connections
+		go serveConnection(DataType.CreateConnection(conn)) // a goroutine handles conn so that the loop can accept other connections
 	}
 }
 
-func getChunk(conn net.Conn) {
-	fmt.Println("Serving Connection")
+// Stop stops InitServer accepting connections.
+func Stop() {
+	stopOnce.Do(func() {
+		close(stopped)
+		listenLock.Lock()
+		defer listenLock.Unlock()
+		if listener != nil {
+			listener.Close()
+		}
+	})
+}
+
+// Save waits for the chunks being generated and stored and flushes the
+// storage.
+func Save() error {
+	inFlight.wait()
+	return world.flush()
+}
+
+// serveConnection answers the requests of a client until its connection
+// fails.
+func serveConnection(conn *DataType.Connection) {
+	logf(LogDebug, "Serving Connection")
+	current := addSession(conn)
+	defer removeSession(current)
 
 	for {
-		dec := gob.NewDecoder(conn)
-		c := &DataType.Chunk{}
-		err := dec.Decode(c)
-		if err == nil {
-			loadChunk(c, conn)
-		} else if neterr, ok := err.(net.Error); ok {
-			fmt.Printf("Recieved error %v\n", neterr)
-			break
+		message, err := conn.Receive()
+		if err != nil {
+			logf(LogDebug, "Recieved error %v", err)
+			return
+		}
+		switch message.Type {
+		case DataType.ChunkRequest:
+			if message.Chunk != nil {
+				current.reply(&DataType.Message{Type: DataType.ChunkData, Cubes: loadChunk(message.Chunk)})
+			}
+		case DataType.Join:
+			if message.Player != nil {
+				current.join(message.Player)
+			}
+		case DataType.PlayerMove:
+			if message.Input != nil {
+				current.input(message.Input)
+			}
+		case DataType.Text:
+			current.say(message.Text)
 		}
 	}
 }
 
-func loadChunk(c *DataType.Chunk, conn net.Conn) {
-	session := mongoSession.Copy()
-	defer session.Close()
-	collection := session.DB("GameDatabase").C("Chunks")
-	encoder := gob.NewEncoder(conn)
-
-	err := collection.Find(bson.M{"xpos": c.XPos, "zpos": c.ZPos}).One(c)
+// loadChunk reads a chunk under its lock, generating it if it was never
+// generated. Once the server is stopping chunks are empty.
+func loadChunk(c *DataType.Chunk) *DataType.CubeChunk {
+	if !inFlight.begin() {
+		return &DataType.CubeChunk{XPos: c.XPos, ZPos: c.ZPos}
+	}
+	defer inFlight.end()
+	unlock := lockChunk(c.XPos, c.ZPos)
+	found, err := world.findChunk(c)
 	if err != nil {
-		encoder.Encode(genChunk(c))
-	} else {
-		encoder.Encode(fetchChunk(c))
+		logf(LogError, "RunQuery : ERROR : %s", err)
 	}
+	if !found {
+		return genChunk(c, unlock)
+	}
+	defer unlock()
+	return fetchChunk(c)
 }
 
-func genChunk(c *DataType.Chunk) *DataType.CubeChunk {
-	session := mongoSession.Copy()
-	defer session.Close()
-	collection := session.DB("GameDatabase").C("Chunks")
-	err := collection.Insert(c)
-	mongoSession.Fsync(false)
-	collection.Find(c).One(c)
-	if err != nil {
-		log.Printf("RunQuery : ERROR : %s\n", err)
+// genChunk generates a chunk and stores it in the background, unlocking the
+// chunk once it is stored. Nothing is generated once the server is
+// stopping.
+func genChunk(c *DataType.Chunk, unlock func()) *DataType.CubeChunk {
+	if !inFlight.begin() {
+		unlock()
+		return &DataType.CubeChunk{XPos: c.XPos, ZPos: c.ZPos}
+	}
+	cubes := createChunk(c)
+	filteredCubes := filter(cubes)
+	go func() {
+		defer inFlight.end()
+		defer unlock()
+		persistChunk(c, cubes)
+	}()
+
+	logf(LogDebug, "Created Chunk at X %v Z %v", c.XPos, c.ZPos)
+	return &DataType.CubeChunk{XPos: c.XPos, ZPos: c.ZPos, Cubes: filteredCubes}
+}
+
+// createChunk stores the chunk, filling in its ID, and generates its cubes.
+// The cubes are not stored.
+func createChunk(c *DataType.Chunk) []*cube {
+	if err := world.createChunk(c); err != nil {
+		logf(LogError, "RunQuery : ERROR : %s", err)
 	}
+	return generateCubes(c)
+}
 
+func generateCubes(c *DataType.Chunk) []*cube {
 	var cubes []*cube
 	for x := 0; x < chunkSize; x++ {
 		for z := 0; z < chunkSize; z++ {
@@ -104,106 +199,82 @@ func genChunk(c *DataType.Chunk) *DataType.CubeChunk {
 			var cubeType uint8
 			for y := -1; y < h+1; y++ {
 				if y >= h || y < 0 {
-					cubeType = DataType.Empty
+					cubeType = Block.Empty
 				} else if (y + 1) >= h {
-					cubeType = DataType.Grass
+					cubeType = grass
 				} else if (y + 5) >= h {
-					cubeType = DataType.Dirt
+					cubeType = dirt
 				} else if (y + 10) >= h {
-					cubeType = DataType.Gravel
+					cubeType = gravel
 				} else {
-					cubeType = DataType.Stone
+					cubeType = stone
 				}
 				cubes = append(cubes, &cube{ChunkID: c.ID, XPos: int8(x), YPos: int8(y), ZPos: int8(z), CubeType: uint8(cubeType)})
 			}
 		}
 	}
-	filteredCubes := filter(cubes)
-
-	fmt.Printf("Created Chunk at X %v Z %v\n", c.XPos, c.ZPos)
-	return &DataType.CubeChunk{XPos: c.XPos, ZPos: c.ZPos, Cubes: filteredCubes}
+	return cubes
 }
 
+// filter marks the cubes with a face open to a transparent neighbour as
+// visible and returns them as drawables. Positions inside the chunk without
+// a cube are open, faces on the chunk's sides are left to the neighbouring
+// chunk.
 func filter(cubes []*cube) []float32 {
+	blocks := map[[3]int]uint8{}
+	for _, current := range cubes {
+		blocks[[3]int{int(current.XPos), int(current.YPos), int(current.ZPos)}] = current.CubeType
+	}
+
 	drawables := []float32{}
 	for _, current := range cubes {
 		current.Visible = false
-		if current.CubeType == DataType.Empty {
+		if current.CubeType == Block.Empty {
 			continue
 		}
-		for _, other := range cubes {
-			xDiff := (other.XPos-current.XPos) == 1 || (other.XPos-current.XPos) == -1
-			yDiff := (other.YPos-current.YPos) == 1 || (other.YPos-current.YPos) == -1
-			zDiff := (other.ZPos-current.ZPos) == 1 || (other.ZPos-current.ZPos) == -1
-			xSame := (other.XPos - current.XPos) == 0
-			ySame := (other.YPos - current.YPos) == 0
-			zSame := (other.ZPos - current.ZPos) == 0
-			if other.CubeType == DataType.Empty && ((xDiff && ySame && zSame) || (xSame && yDiff && zSame) || (xSame && ySame && zDiff)) {
+		for _, side := range sides {
+			x, y, z := int(current.XPos)+side[0], int(current.YPos)+side[1], int(current.ZPos)+side[2]
+			if x < 0 || x >= chunkSize || z < 0 || z >= chunkSize {
+				continue
+			}
+			if other, ok := blocks[[3]int{x, y, z}]; !ok || Block.IsTransparent(int(other)) {
 				current.Visible = true
 				drawables = append(drawables, float32(current.XPos), float32(current.YPos), float32(current.ZPos), float32(current.CubeType))
 				break
 			}
 		}
 	}
-	go persistChunk(cubes)
 	return drawables
 }
 
-func persistChunk(cubes []*cube) {
-	session := mongoSession.Copy()
-	defer session.Close()
-	collection := session.DB("GameDatabase").C("Cubes")
-	bulk := collection.Bulk()
-	for _, current := range cubes {
-		bulk.Insert(current)
-	}
-	_, err := bulk.Run()
-	if err != nil {
-		log.Printf("RunQuery : ERROR : %s\n", err)
+func persistChunk(c *DataType.Chunk, cubes []*cube) {
+	if err := world.saveCubes(c, cubes); err != nil {
+		logf(LogError, "RunQuery : ERROR : %s", err)
 	}
 }
 
 func fetchChunk(c *DataType.Chunk) *DataType.CubeChunk {
-	session := mongoSession.Copy()
-	defer session.Close()
-	collection := session.DB("GameDatabase").C("Cubes")
-	collection.Find(bson.M{"chunkid": c.ID}).All(&cubeLoader)
+	cubes, err := world.loadCubes(c)
+	if err != nil {
+		logf(LogError, "RunQuery : ERROR : %s", err)
+	}
 	drawables := []float32{}
-	for _, current := range cubeLoader {
+	for _, current := range cubes {
 		if current.Visible {
 			drawables = append(drawables, float32(current.XPos), float32(current.YPos), float32(current.ZPos), float32(current.CubeType))
 		}
 	}
-	fmt.Printf("Loaded Chunk at X %v Z %v\n", c.XPos, c.ZPos)
+	logf(LogDebug, "Loaded Chunk at X %v Z %v", c.XPos, c.ZPos)
 	return &DataType.CubeChunk{XPos: c.XPos, ZPos: c.ZPos, Cubes: drawables}
 }
 
 func LoadGameMap() {
-	if mongoSession == nil {
-		createDatabaseLink()
-	}
-	serverNoise[0] = CreateSimplexNoise(200, 255.0, 0.5)
-}
-
-func createDatabaseLink() {
-	var err error
-	logFile, err = os.OpenFile("ErrorLog.txt", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
-	if err != nil {
-		log.Fatalf("error opening file: %v", err)
-	}
-	log.SetOutput(logFile)
-	mongoSession, err = mgo.Dial(mongodb)
-	if err != nil {
-		log.Fatalf("CreateSession: %s\n", err)
-	}
-	index := mgo.Index{
-		Key: []string{"chunkid"},
+	if world == nil {
+		openStorage()
 	}
-	collection := mongoSession.DB("GameDatabase").C("Cubes")
-	collection.EnsureIndex(index)
-}
+	serverNoise[0] = CreateSimplexNoise(Seed, 255.0, 0.5)
 
-func closeMongoSession() {
-	logFile.Close()
-	mongoSession.Close()
+	Block.InitBlocks()
+	grass, dirt = Block.ID("grass"), Block.ID("dirt")
+	gravel, stone = Block.ID("gravel"), Block.ID("stone")
 }
@@ -0,0 +1,402 @@
+package Server
+
+import (
+	"fmt"
+	m "math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/allanks/Voxel-Engine/src/Server/DataType"
+)
+
+const (
+	// viewDistance is how far from a player entities are replicated to it
+	viewDistance float32 = 128
+	// sessionQueue is how many messages a client can fall behind by before
+	// it is disconnected
+	sessionQueue = 1024
+	// spawnX and spawnZ are the column players join in
+	spawnX, spawnZ int = 0, 0
+	// maxName and maxChat are how many characters names and chat lines
+	// can have
+	maxName, maxChat = 16, 256
+)
+
+// sendTimeout is how long a message may take to reach a client before the
+// client is disconnected.
+var sendTimeout = 5 * time.Second
+
+// session is a connected client. player is the entity of the client's
+// player, zero until it joins and not changed after. visible holds the entities the client has
+// been told about and is only used by the entity tick. Messages to the
+// client are queued in outbox and written by the session's own goroutine,
+// which stops once done is closed.
+type session struct {
+	conn       *DataType.Connection
+	player     uint32
+	name       string
+	permission int
+	visible    map[uint32]bool
+	violations map[string]int
+	outbox     chan *DataType.Message
+	done       chan struct{}
+	closeOnce  sync.Once
+}
+
+var (
+	sessionLock sync.Mutex
+	sessions    = map[*DataType.Connection]*session{}
+	// openSessions counts the sessions that have not been removed yet
+	openSessions = createTracker()
+)
+
+func addSession(conn *DataType.Connection) *session {
+	sessionLock.Lock()
+	defer sessionLock.Unlock()
+	current := &session{conn: conn, visible: map[uint32]bool{}, violations: map[string]int{},
+		outbox: make(chan *DataType.Message, sessionQueue), done: make(chan struct{})}
+	sessions[conn] = current
+	openSessions.begin()
+	go current.write(sendTimeout)
+	return current
+}
+
+// write sends the queued messages to the client. A client that takes longer
+// than timeout for one is disconnected, as is one that was sent Disconnect.
+func (current *session) write(timeout time.Duration) {
+	for {
+		select {
+		case message := <-current.outbox:
+			if err := current.conn.SendBefore(message, time.Now().Add(timeout)); err != nil {
+				logf(LogDebug, "Sending to %v failed %v", current.conn.RemoteAddr(), err)
+				current.close()
+				return
+			}
+			if message.Type == DataType.Disconnect {
+				current.close()
+				return
+			}
+		case <-current.done:
+			return
+		}
+	}
+}
+
+// send queues a message for the client without waiting. A client too far
+// behind to queue it is disconnected.
+func (current *session) send(message *DataType.Message) {
+	select {
+	case current.outbox <- message:
+	default:
+		logf(LogWarn, "%v fell %v messages behind, disconnecting", current.conn.RemoteAddr(), sessionQueue)
+		current.close()
+	}
+}
+
+// reply queues a message for the client, waiting for room. Only the
+// session's own goroutine replies, so a slow client only holds up itself.
+func (current *session) reply(message *DataType.Message) {
+	select {
+	case current.outbox <- message:
+	case <-current.done:
+	}
+}
+
+// close closes the connection, which ends the session once its goroutine
+// sees it fail.
+func (current *session) close() {
+	current.closeOnce.Do(func() {
+		current.conn.Close()
+	})
+}
+
+// removeSession closes the connection and removes the player from the world.
+func removeSession(current *session) {
+	sessionLock.Lock()
+	delete(sessions, current.conn)
+	player, name := current.player, current.name
+	sessionLock.Unlock()
+
+	close(current.done)
+	current.close()
+	if player != 0 {
+		DespawnEntity(player)
+		logf(LogInfo, "%v left", name)
+		broadcast(fmt.Sprintf("%v left the game", name))
+	}
+	openSessions.end()
+}
+
+// join spawns the player of a session on the ground at the spawn and tells
+// the client the ID it was given and where it stands. Where the client says
+// it stands is ignored. Names have to be printable without spaces and not
+// online already, sessions asking for other names are disconnected. Sessions only join once
+// and join as players, only the console makes them operators.
+func (current *session) join(state *DataType.PlayerState) {
+	name := state.Name
+	if name == "" {
+		name = "player"
+	}
+	if cleanText(name, maxName) != name || strings.Contains(name, " ") {
+		logf(LogWarn, "%v tried to join as %q", current.conn.RemoteAddr(), name)
+		current.disconnect(fmt.Sprintf("Names are at most %v printable characters without spaces", maxName))
+		return
+	}
+	sessionLock.Lock()
+	if current.name != "" {
+		sessionLock.Unlock()
+		return
+	}
+	for _, other := range sessions {
+		if other.name == name {
+			sessionLock.Unlock()
+			logf(LogInfo, "%v tried to join as %v who is online", current.conn.RemoteAddr(), name)
+			current.disconnect(fmt.Sprintf("%v is already online", name))
+			return
+		}
+	}
+	// The name is taken from here on, before the player spawns
+	current.name = name
+	sessionLock.Unlock()
+
+	spawn := DataType.Pos{XPos: float32(spawnX) + 0.5, YPos: float32(groundLevel(spawnX, spawnZ)), ZPos: float32(spawnZ) + 0.5}
+	player := spawnPlayer(name, spawn)
+	sessionLock.Lock()
+	current.player, current.permission = player, PermissionPlayer
+	sessionLock.Unlock()
+
+	logf(LogInfo, "%v joined from %v", name, current.conn.RemoteAddr())
+	current.reply(&DataType.Message{Type: DataType.Welcome, Player: &DataType.PlayerState{ID: player, Name: name, Position: spawn, CanFly: AllowFlight, Move: DataType.MoveState{Position: spawn}}})
+	broadcast(fmt.Sprintf("%v joined the game", name))
+}
+
+// say runs a line starting with / as a command, answering only the
+// session, and shows any other line to every player. Control characters
+// are dropped and lines are cut to maxChat characters.
+func (current *session) say(text string) {
+	sessionLock.Lock()
+	caller := &Caller{Name: current.name, Permission: current.permission, Player: current.player}
+	sessionLock.Unlock()
+	text = strings.TrimSpace(cleanText(text, maxChat))
+	if caller.Player == 0 || text == "" {
+		return
+	}
+
+	if strings.HasPrefix(text, "/") {
+		logf(LogInfo, "%v ran %v", caller.Name, text)
+		current.reply(&DataType.Message{Type: DataType.Text, Text: RunCommand(caller, text[1:])})
+		return
+	}
+	line := fmt.Sprintf("<%v> %v", caller.Name, text)
+	logf(LogInfo, "%v", line)
+	broadcast(line)
+}
+
+// cleanText drops the characters of text that are not printable and cuts
+// it to length characters.
+func cleanText(text string, length int) string {
+	clean := []rune{}
+	for _, character := range text {
+		if len(clean) == length {
+			break
+		}
+		if unicode.IsPrint(character) {
+			clean = append(clean, character)
+		}
+	}
+	return string(clean)
+}
+
+// broadcast shows a line to every player that has joined.
+func broadcast(text string) {
+	sendJoined(&DataType.Message{Type: DataType.Text, Text: text})
+}
+
+func sendJoined(message *DataType.Message) {
+	for _, current := range joinedSessions() {
+		current.send(message)
+	}
+}
+
+// joinedSessions returns the sessions whose player has joined, their player
+// can be read without sessionLock.
+func joinedSessions() []*session {
+	sessionLock.Lock()
+	defer sessionLock.Unlock()
+	joined := []*session{}
+	for _, current := range sessions {
+		if current.player != 0 {
+			joined = append(joined, current)
+		}
+	}
+	return joined
+}
+
+// findPlayer returns the entity of the player with a name, names are only
+// online once.
+func findPlayer(name string) (uint32, bool) {
+	sessionLock.Lock()
+	defer sessionLock.Unlock()
+	for _, current := range sessions {
+		if current.player != 0 && current.name == name {
+			return current.player, true
+		}
+	}
+	return 0, false
+}
+
+// setPermission gives the player with a name a permission, telling it.
+func setPermission(name string, permission int) bool {
+	sessionLock.Lock()
+	var found *session
+	for _, current := range sessions {
+		if current.player != 0 && current.name == name {
+			current.permission, found = permission, current
+			break
+		}
+	}
+	sessionLock.Unlock()
+	if found == nil {
+		return false
+	}
+	if permission >= PermissionOperator {
+		found.send(&DataType.Message{Type: DataType.Text, Text: "You are now an operator"})
+	} else {
+		found.send(&DataType.Message{Type: DataType.Text, Text: "You are no longer an operator"})
+	}
+	return true
+}
+
+// kickPlayer disconnects the player with a name, telling it why.
+func kickPlayer(name, reason string) bool {
+	sessionLock.Lock()
+	var kicked *session
+	for _, current := range sessions {
+		if current.player != 0 && current.name == name {
+			kicked = current
+			break
+		}
+	}
+	sessionLock.Unlock()
+	if kicked == nil {
+		return false
+	}
+	kicked.disconnect("Kicked: " + reason)
+	return true
+}
+
+// disconnectAll disconnects every client, telling it why, and waits for
+// their sessions to end. Clients that are not told within sendTimeout are
+// dropped without being told.
+func disconnectAll(reason string) {
+	sessionLock.Lock()
+	all := make([]*session, 0, len(sessions))
+	for _, current := range sessions {
+		all = append(all, current)
+	}
+	sessionLock.Unlock()
+	for _, current := range all {
+		current.disconnect(reason)
+	}
+	if !openSessions.waitTimeout(sendTimeout) {
+		for _, current := range all {
+			current.close()
+		}
+	}
+}
+
+// disconnect tells the client why it is being disconnected, its connection
+// is closed once that was sent and the session is removed once its
+// connection fails.
+func (current *session) disconnect(reason string) {
+	current.send(&DataType.Message{Type: DataType.Disconnect, Text: reason})
+}
+
+// playerNames returns the names of the players that have joined, sorted.
+func playerNames() []string {
+	sessionLock.Lock()
+	defer sessionLock.Unlock()
+	names := []string{}
+	for _, current := range sessions {
+		if current.player != 0 {
+			names = append(names, current.name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// input applies a player input, logging the inputs that break a rule. The
+// client is corrected by the next acknowledgement.
+func (current *session) input(input *DataType.PlayerInput) {
+	sessionLock.Lock()
+	player, name := current.player, current.name
+	sessionLock.Unlock()
+	if player == 0 {
+		return
+	}
+	rule, detail := applyInput(player, input)
+	if rule == "" {
+		return
+	}
+
+	sessionLock.Lock()
+	current.violations[rule]++
+	count := current.violations[rule]
+	sessionLock.Unlock()
+	logf(LogWarn, "Violation : %v from %v : %v, %v (%v so far)", name, current.conn.RemoteAddr(), rule, detail, count)
+}
+
+// replicate brings every joined client up to date with the entities within
+// viewDistance of its player. Entities coming into view are spawned, ones
+// leaving it or leaving the world are despawned and ones in view that moved
+// are updated. Players are not sent their own entity, only the
+// acknowledgement of their inputs.
+func replicate(states []entityState) {
+	positions := map[uint32]DataType.Pos{}
+	for _, state := range states {
+		positions[state.ID] = state.Position
+	}
+
+	for _, current := range joinedSessions() {
+		player := current.player
+		center, ok := positions[player]
+		if !ok {
+			continue
+		}
+		for i := range states {
+			state := &states[i]
+			if state.ID == player {
+				if state.ack != nil {
+					current.send(&DataType.Message{Type: DataType.PlayerAck, Player: state.ack})
+				}
+				continue
+			}
+			near := distance3(center, state.Position) <= viewDistance
+			switch {
+			case near && !current.visible[state.ID]:
+				current.visible[state.ID] = true
+				current.send(&DataType.Message{Type: DataType.EntitySpawn, Entity: &state.Entity})
+			case !near && current.visible[state.ID]:
+				delete(current.visible, state.ID)
+				current.send(&DataType.Message{Type: DataType.EntityDespawn, Entity: &DataType.Entity{ID: state.ID}})
+			case near && state.moved:
+				current.send(&DataType.Message{Type: DataType.EntityUpdate, Entity: &state.Entity})
+			}
+		}
+		for id := range current.visible {
+			if _, ok := positions[id]; !ok {
+				delete(current.visible, id)
+				current.send(&DataType.Message{Type: DataType.EntityDespawn, Entity: &DataType.Entity{ID: id}})
+			}
+		}
+	}
+}
+
+func distance3(a, b DataType.Pos) float32 {
+	dx, dy, dz := a.XPos-b.XPos, a.YPos-b.YPos, a.ZPos-b.ZPos
+	return float32(m.Sqrt(float64((dx * dx) + (dy * dy) + (dz * dz))))
+}
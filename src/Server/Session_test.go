@@ -0,0 +1,190 @@
+package Server
+
+import (
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/allanks/Voxel-Engine/src/Server/DataType"
+)
+
+// createTestSession adds a session served over a pipe and returns the
+// client's end of it.
+func createTestSession(t *testing.T) (*session, net.Conn) {
+	server, client := net.Pipe()
+	current := addSession(DataType.CreateConnection(server))
+	t.Cleanup(func() {
+		removeSession(current)
+		client.Close()
+	})
+	return current, client
+}
+
+// useSendTimeout shortens sendTimeout for a test.
+func useSendTimeout(t *testing.T, timeout time.Duration) {
+	previous := sendTimeout
+	sendTimeout = timeout
+	t.Cleanup(func() { sendTimeout = previous })
+}
+
+// closedWithin reports whether the server closed its end of the pipe
+// within timeout.
+func closedWithin(client net.Conn, timeout time.Duration) bool {
+	client.SetWriteDeadline(time.Now().Add(timeout))
+	_, err := client.Write([]byte{0})
+	return err == io.ErrClosedPipe
+}
+
+func TestSessionSendsInOrder(t *testing.T) {
+	current, client := createTestSession(t)
+	conn := DataType.CreateConnection(client)
+	for _, text := range []string{"one", "two", "three"} {
+		current.send(&DataType.Message{Type: DataType.Text, Text: text})
+	}
+	current.disconnect("bye")
+
+	for _, want := range []string{"one", "two", "three", "bye"} {
+		message, err := conn.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if message.Text != want {
+			t.Errorf("received %q, want %q", message.Text, want)
+		}
+	}
+	if _, err := conn.Receive(); err == nil {
+		t.Error("connection still open after Disconnect")
+	}
+}
+
+func TestStalledClientIsDisconnected(t *testing.T) {
+	useSendTimeout(t, 50*time.Millisecond)
+	current, client := createTestSession(t)
+
+	current.send(&DataType.Message{Type: DataType.Text, Text: "hello"})
+	if !closedWithin(client, time.Second) {
+		t.Error("client that never read was not disconnected")
+	}
+}
+
+func TestFloodedClientDoesNotBlockSenders(t *testing.T) {
+	useSendTimeout(t, time.Hour)
+	current, client := createTestSession(t)
+
+	began := time.Now()
+	for i := 0; i < sessionQueue*2; i++ {
+		current.send(&DataType.Message{Type: DataType.Text, Text: "spam"})
+	}
+	if elapsed := time.Since(began); elapsed > time.Second {
+		t.Errorf("sending to a client that never read took %v", elapsed)
+	}
+	if !closedWithin(client, time.Second) {
+		t.Error("client that fell behind was not disconnected")
+	}
+}
+
+func TestJoinSpawnsOnTheGround(t *testing.T) {
+	useTestWorld(t)
+	useTestBlocks(t)
+	current, client := createTestSession(t)
+	conn := DataType.CreateConnection(client)
+
+	current.join(&DataType.PlayerState{Name: "tester", Position: DataType.Pos{XPos: 1000, YPos: 1000, ZPos: 1000}})
+	message, err := conn.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ground := groundLevel(spawnX, spawnZ)
+	if ground <= seaLevel-10 || ground >= maxHeight {
+		t.Fatalf("ground at the spawn is %v high", ground)
+	}
+	want := DataType.Pos{XPos: float32(spawnX) + 0.5, YPos: float32(ground), ZPos: float32(spawnZ) + 0.5}
+	if message.Type != DataType.Welcome || message.Player.Position != want || message.Player.Move.Position != want {
+		t.Errorf("welcomed at %v moving from %v, want %v", message.Player.Position, message.Player.Move.Position, want)
+	}
+	if position, _ := entityPosition(current.player); position != want {
+		t.Errorf("spawned at %v, want %v", position, want)
+	}
+}
+
+// joinTestSession joins a session as name and reads the welcome and the
+// line telling the players it joined.
+func joinTestSession(t *testing.T, name string) (*session, *DataType.Connection) {
+	current, client := createTestSession(t)
+	conn := DataType.CreateConnection(client)
+	current.join(&DataType.PlayerState{Name: name})
+	for _, want := range []int{DataType.Welcome, DataType.Text} {
+		message, err := conn.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if message.Type != want {
+			t.Fatalf("received message %v joining, want %v", message.Type, want)
+		}
+	}
+	return current, conn
+}
+
+func TestJoinRefusesNames(t *testing.T) {
+	useTestWorld(t)
+	useTestBlocks(t)
+	joinTestSession(t, "tester")
+	for _, name := range []string{"tester", "two words", "line\nbreak", "escape\x1b[31m", strings.Repeat("x", maxName+1)} {
+		current, client := createTestSession(t)
+		current.join(&DataType.PlayerState{Name: name})
+		message, err := DataType.CreateConnection(client).Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if message.Type != DataType.Disconnect {
+			t.Errorf("joined as %q", name)
+		}
+	}
+}
+
+func TestSayCleansLines(t *testing.T) {
+	useTestWorld(t)
+	useTestBlocks(t)
+	current, conn := joinTestSession(t, "tester")
+
+	current.say("hello\n<admin> fake line\x1b[31m" + strings.Repeat("x", maxChat*2))
+	message, err := conn.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := strings.TrimPrefix(message.Text, "<tester> ")
+	if !strings.HasPrefix(text, "hello<admin> fake line[31m") {
+		t.Errorf("said %q", message.Text)
+	}
+	if length := len([]rune(text)); length != maxChat {
+		t.Errorf("said %v characters, want %v", length, maxChat)
+	}
+}
+
+func TestOperatorsOnlyFromConsole(t *testing.T) {
+	useTestWorld(t)
+	useTestBlocks(t)
+	current, conn := joinTestSession(t, "tester")
+	current.say("/setblock 0 1 0 stone")
+	if message, err := conn.Receive(); err != nil || !strings.HasPrefix(message.Text, "You are not allowed") {
+		t.Errorf("player ran an operator command, answered %q, %v", message.Text, err)
+	}
+
+	if !setPermission("tester", PermissionOperator) {
+		t.Fatal("tester is not online")
+	}
+	if message, err := conn.Receive(); err != nil || message.Text != "You are now an operator" {
+		t.Errorf("answered %q, %v", message.Text, err)
+	}
+	sessionLock.Lock()
+	permission := current.permission
+	sessionLock.Unlock()
+	if permission != PermissionOperator {
+		t.Errorf("permission %v, want %v", permission, PermissionOperator)
+	}
+	if setPermission("nobody", PermissionOperator) {
+		t.Error("gave a player that is not online permission")
+	}
+}
@@ -0,0 +1,102 @@
+package Server
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ShutdownTimeout is how long a stopping server waits for the chunks being
+// generated and stored before it closes the storage anyway.
+var ShutdownTimeout = 10 * time.Second
+
+var errStopping = errors.New("the server is stopping")
+
+// inFlight counts the chunks being generated, stored or read, and
+// entityTicks the ticks of runEntities.
+var inFlight, entityTicks = createTracker(), createTracker()
+
+// tracker counts work in progress. Once it is closed no more work begins,
+// so waiting on it after closing it cannot be outlasted by new work.
+type tracker struct {
+	lock    sync.Mutex
+	changed *sync.Cond
+	count   int
+	closed  bool
+}
+
+func createTracker() *tracker {
+	work := &tracker{}
+	work.changed = sync.NewCond(&work.lock)
+	return work
+}
+
+// begin counts a piece of work, reporting false if the tracker is closed.
+func (work *tracker) begin() bool {
+	work.lock.Lock()
+	defer work.lock.Unlock()
+	if work.closed {
+		return false
+	}
+	work.count++
+	return true
+}
+
+func (work *tracker) end() {
+	work.lock.Lock()
+	defer work.lock.Unlock()
+	work.count--
+	work.changed.Broadcast()
+}
+
+func (work *tracker) close() {
+	work.lock.Lock()
+	defer work.lock.Unlock()
+	work.closed = true
+}
+
+// wait returns once no work is in progress.
+func (work *tracker) wait() {
+	work.lock.Lock()
+	defer work.lock.Unlock()
+	for work.count > 0 {
+		work.changed.Wait()
+	}
+}
+
+// waitTimeout waits for the work in progress, reporting false if it was
+// still going after timeout.
+func (work *tracker) waitTimeout(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		work.wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Shutdown disconnects every client, stops the entities, waits for the
+// chunks being generated, stored and read and closes the storage. Storage
+// that is still in use after ShutdownTimeout is left open, closing it would
+// fail the work still using it.
+func Shutdown() {
+	disconnectAll("The server is stopping")
+	deadline := time.Now().Add(ShutdownTimeout)
+	entityTicks.close()
+	inFlight.close()
+	logf(LogInfo, "Waiting for chunks being generated, stored and read")
+	if !entityTicks.waitTimeout(time.Until(deadline)) || !inFlight.waitTimeout(time.Until(deadline)) {
+		logf(LogError, "Shutdown : ERROR : chunks were still being generated, stored or read after %v, leaving the storage open", ShutdownTimeout)
+		return
+	}
+	if err := world.flush(); err != nil {
+		logf(LogError, "Shutdown : ERROR : %s", err)
+	}
+	closeStorage()
+	logf(LogInfo, "Stopped")
+}
@@ -0,0 +1,131 @@
+package Server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/allanks/Voxel-Engine/src/Server/DataType"
+)
+
+// closeCounter is storage that counts how often it was closed.
+type closeCounter struct {
+	storage
+	closes int
+}
+
+func (store *closeCounter) close() error {
+	store.closes++
+	return nil
+}
+
+// useTestShutdown gives a test its own work trackers and a short
+// ShutdownTimeout.
+func useTestShutdown(t *testing.T) {
+	previous, ticks, timeout := inFlight, entityTicks, ShutdownTimeout
+	inFlight, entityTicks, ShutdownTimeout = createTracker(), createTracker(), 50*time.Millisecond
+	t.Cleanup(func() { inFlight, entityTicks, ShutdownTimeout = previous, ticks, timeout })
+}
+
+// stalledReads is storage whose chunk lookups wait for release.
+type stalledReads struct {
+	closeCounter
+	reading, release chan struct{}
+}
+
+func (store *stalledReads) findChunk(c *DataType.Chunk) (bool, error) {
+	close(store.reading)
+	<-store.release
+	return store.storage.findChunk(c)
+}
+
+func TestShutdownLeavesStorageOpenForWrites(t *testing.T) {
+	useTestWorld(t)
+	useTestShutdown(t)
+	store := &closeCounter{storage: world}
+	world = store
+
+	inFlight.begin()
+	Shutdown()
+	if store.closes != 0 {
+		t.Error("storage closed while a chunk was being stored")
+	}
+	inFlight.end()
+
+	inFlight = createTracker()
+	Shutdown()
+	if store.closes != 1 {
+		t.Errorf("storage closed %v times once nothing was stored, want 1", store.closes)
+	}
+}
+
+func TestDisconnectAllDropsStalledClients(t *testing.T) {
+	useSendTimeout(t, 50*time.Millisecond)
+	server, client := net.Pipe()
+	defer client.Close()
+	conn := DataType.CreateConnection(server)
+	current := addSession(conn)
+	go func() {
+		defer removeSession(current)
+		for {
+			if _, err := conn.Receive(); err != nil {
+				return
+			}
+		}
+	}()
+
+	began := time.Now()
+	disconnectAll("The server is stopping")
+	if elapsed := time.Since(began); elapsed > time.Second {
+		t.Errorf("disconnecting a client that never read took %v", elapsed)
+	}
+	if !closedWithin(client, time.Second) {
+		t.Error("client that never read was not disconnected")
+	}
+}
+
+func TestShutdownLeavesStorageOpenForReads(t *testing.T) {
+	useTestWorld(t)
+	useTestShutdown(t)
+	store := &stalledReads{closeCounter: closeCounter{storage: world}, reading: make(chan struct{}), release: make(chan struct{})}
+	world = store
+
+	loaded := make(chan struct{})
+	go func() {
+		defer close(loaded)
+		chunkBlocks(0, 0)
+	}()
+	<-store.reading
+	Shutdown()
+	if store.closes != 0 {
+		t.Error("storage closed while a chunk was being read")
+	}
+	close(store.release)
+	<-loaded
+
+	if chunk := loadChunk(&DataType.Chunk{XPos: 0, ZPos: 0}); len(chunk.Cubes) != 0 {
+		t.Error("chunk loaded after the server stopped")
+	}
+}
+
+func TestShutdownStopsEntities(t *testing.T) {
+	useTestWorld(t)
+	useTestShutdown(t)
+	store := &closeCounter{storage: world}
+	world = store
+
+	ticking := make(chan struct{})
+	go func() {
+		defer close(ticking)
+		runEntities()
+	}()
+	Shutdown()
+	if store.closes != 1 {
+		t.Errorf("storage closed %v times, want 1", store.closes)
+	}
+	select {
+	case <-ticking:
+	case <-time.After(time.Second):
+		t.Error("entities still ticking after the server stopped")
+	}
+}
@@ -0,0 +1,72 @@
+package Server
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/allanks/Voxel-Engine/src/Server/DataType"
+)
+
+// Storage backends
+const (
+	StorageMongo string = "mongo"
+	StorageFile  string = "file"
+)
+
+var (
+	// StorageBackend is where the world is kept, StorageMongo or StorageFile
+	StorageBackend = StorageMongo
+	// WorldDirectory holds the log and, for StorageFile, the chunks
+	WorldDirectory = "world"
+	// MongoAddress is the server StorageMongo connects to
+	MongoAddress = "localhost:27017"
+	world        storage
+)
+
+// storage keeps the chunks of the world and their cubes. Chunks are given an
+// ID when they are created, which their cubes refer to.
+type storage interface {
+	// findChunk fills in the ID of the chunk at the chunk's position,
+	// reporting false if it was never created
+	findChunk(c *DataType.Chunk) (bool, error)
+	createChunk(c *DataType.Chunk) error
+	loadCubes(c *DataType.Chunk) ([]*cube, error)
+	// saveCubes replaces every cube of a chunk
+	saveCubes(c *DataType.Chunk, cubes []*cube) error
+	flush() error
+	close() error
+}
+
+// openStorage opens the log and the storage backend of the world.
+func openStorage() {
+	if err := os.MkdirAll(WorldDirectory, 0755); err != nil {
+		log.Fatalf("error creating world directory: %v", err)
+	}
+	var err error
+	logFile, err = os.OpenFile(filepath.Join(WorldDirectory, "ErrorLog.txt"), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		log.Fatalf("error opening file: %v", err)
+	}
+	log.SetOutput(logFile)
+
+	switch StorageBackend {
+	case StorageMongo:
+		world, err = openMongoStorage(MongoAddress)
+	case StorageFile:
+		world, err = openFileStorage(filepath.Join(WorldDirectory, "chunks"))
+	default:
+		err = fmt.Errorf("unknown storage backend %q", StorageBackend)
+	}
+	if err != nil {
+		log.Fatalf("CreateStorage: %s\n", err)
+	}
+}
+
+func closeStorage() {
+	if err := world.close(); err != nil {
+		logf(LogError, "CloseStorage : ERROR : %s", err)
+	}
+	logFile.Close()
+}
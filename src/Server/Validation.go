@@ -0,0 +1,85 @@
+package Server
+
+import (
+	"fmt"
+
+	"github.com/allanks/Voxel-Engine/src/Movement"
+	"github.com/allanks/Voxel-Engine/src/Server/DataType"
+)
+
+// AllowFlight lets players move freely through the air instead of falling.
+var AllowFlight = false
+
+// Players earn a second of input time every second, saving up at most
+// maxInputBudget, so inputs that arrive bunched together are not mistaken
+// for a client running its clock fast.
+const maxInputBudget float32 = 0.5
+
+// Violations
+const (
+	violationSpeed   = "speed"
+	violationFlight  = "flight"
+	violationInvalid = "invalid"
+	violationAxis    = "axis"
+)
+
+// movement is what the server keeps of a player to simulate its inputs.
+// sequence is the last input applied and acked whether the client has been
+// told about it.
+type movement struct {
+	state    DataType.MoveState
+	sequence uint32
+	acked    bool
+	budget   float32
+}
+
+// serverWorld lets the movement code collide with the blocks entities see.
+// entityLock has to be held while it is used.
+type serverWorld struct{}
+
+func (serverWorld) IsSolid(x, y, z int) bool {
+	return isSolid(float32(x), float32(y), float32(z))
+}
+
+// validateInput checks an input before it is simulated, reporting the rule
+// it breaks with a description. Inputs with numbers that are not finite or
+// that take more time than the client had are refused, flying without
+// AllowFlight is turned into walking and Movement.Step holds axes beyond
+// -1 to 1 back. Movement itself cannot break the rules, the server
+// simulates it with its own copy of the world.
+func (current *entity) validateInput(input *DataType.PlayerInput, elapsed float32) (string, string) {
+	if !Movement.Finite(*input) {
+		return violationInvalid, fmt.Sprintf("input of %v", *input)
+	}
+	current.budget = minFloat(current.budget+elapsed, maxInputBudget)
+	delta := minFloat(input.Delta, Movement.MaxDelta)
+	if delta > current.budget {
+		return violationSpeed, fmt.Sprintf("input of %.3f seconds with %.3f left", delta, current.budget)
+	}
+	current.budget -= delta
+	rule, detail := "", ""
+	if axes := []float32{input.Forward, input.Strafe, input.Up}; !withinAxis(axes...) {
+		rule, detail = violationAxis, fmt.Sprintf("axes %v beyond -1 to 1", axes)
+	}
+	if input.Fly && !AllowFlight {
+		input.Fly = false
+		rule, detail = violationFlight, "flew without permission"
+	}
+	return rule, detail
+}
+
+func withinAxis(values ...float32) bool {
+	for _, value := range values {
+		if value < -1 || value > 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func minFloat(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
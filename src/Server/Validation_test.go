@@ -0,0 +1,38 @@
+package Server
+
+import (
+	m "math"
+	"testing"
+
+	"github.com/allanks/Voxel-Engine/src/Server/DataType"
+)
+
+func TestValidateInput(t *testing.T) {
+	nan := float32(m.NaN())
+	tests := []struct {
+		name   string
+		input  DataType.PlayerInput
+		budget float32
+		rule   string
+	}{
+		{"walking", DataType.PlayerInput{Forward: 1, Delta: 0.05}, 0.1, ""},
+		{"more time than the budget", DataType.PlayerInput{Forward: 1, Delta: 0.05}, 0.01, violationSpeed},
+		{"delta not a number", DataType.PlayerInput{Delta: nan}, 0.1, violationInvalid},
+		{"yaw not a number", DataType.PlayerInput{Yaw: nan, Delta: 0.05}, 0.1, violationInvalid},
+		{"axis not a number", DataType.PlayerInput{Strafe: nan, Delta: 0.05}, 0.1, violationInvalid},
+		{"axis beyond one", DataType.PlayerInput{Up: 1000, Delta: 0.05}, 0.1, violationAxis},
+		{"flying", DataType.PlayerInput{Fly: true, Delta: 0.05}, 0.1, violationFlight},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			current := &entity{movement: movement{budget: test.budget}}
+			input := test.input
+			if rule, detail := current.validateInput(&input, 0); rule != test.rule {
+				t.Errorf("broke %q (%v), want %q", rule, detail, test.rule)
+			}
+			if input.Fly {
+				t.Error("input still flies without AllowFlight")
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package Server
+
+import (
+	"fmt"
+
+	"github.com/allanks/Voxel-Engine/src/Block"
+	"github.com/allanks/Voxel-Engine/src/Vox"
+)
+
+// StampVox places the first model of a .vox file with its lowest corner at
+// x, y, z. Palette colours become the block with the nearest colour, and
+// empty voxels leave the world as it was.
+func StampVox(file string, x, y, z int) error {
+	vox, err := Vox.LoadFile(file)
+	if err != nil {
+		return err
+	}
+	region, err := vox.ToRegion(0, Vox.PaletteBlocks(vox.Palette))
+	if err != nil {
+		return err
+	}
+
+	edits := []BlockEdit{}
+	for ry := 0; ry < region.SizeY; ry++ {
+		for rz := 0; rz < region.SizeZ; rz++ {
+			for rx := 0; rx < region.SizeX; rx++ {
+				if block := region.At(rx, ry, rz); block != Block.Empty {
+					edits = append(edits, BlockEdit{x + rx, y + ry, z + rz, block})
+				}
+			}
+		}
+	}
+	if err = SetBlocks(edits); err != nil {
+		return err
+	}
+	fmt.Printf("Stamped %v blocks from %v at %v %v %v\n", len(edits), file, x, y, z)
+	return nil
+}
+
+// ExportVox writes the blocks between two corners, both included, to a .vox
+// file coloured by the block colours. The region can be 256 blocks a side at
+// most, as large as a .vox model can be.
+func ExportVox(file string, x0, y0, z0, x1, y1, z1 int) error {
+	if x1 < x0 {
+		x0, x1 = x1, x0
+	}
+	if y1 < y0 {
+		y0, y1 = y1, y0
+	}
+	if z1 < z0 {
+		z0, z1 = z1, z0
+	}
+	if err := Vox.CheckSize(x1-x0+1, y1-y0+1, z1-z0+1); err != nil {
+		return err
+	}
+	region := Vox.CreateRegion(x1-x0+1, y1-y0+1, z1-z0+1)
+	cache := blockCache{}
+	for y := y0; y <= y1; y++ {
+		for z := z0; z <= z1; z++ {
+			for x := x0; x <= x1; x++ {
+				block, err := cache.get(x, y, z)
+				if err != nil {
+					return err
+				}
+				region.Set(x-x0, y-y0, z-z0, block)
+			}
+		}
+	}
+
+	palette, colors := Vox.BlockPalette()
+	vox, err := Vox.FromRegion(region, palette, colors)
+	if err != nil {
+		return err
+	}
+	if err = Vox.SaveFile(file, vox); err != nil {
+		return err
+	}
+	fmt.Printf("Exported %vx%vx%v blocks to %v\n", region.SizeX, region.SizeY, region.SizeZ, file)
+	return nil
+}
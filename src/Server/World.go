@@ -0,0 +1,205 @@
+package Server
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/allanks/Voxel-Engine/src/Server/DataType"
+)
+
+// BlockEdit sets the block at a world position.
+type BlockEdit struct {
+	X, Y, Z int
+	Block   uint8
+}
+
+// chunkLocks hold the lock of every chunk being worked on. A chunk is
+// created, has its cubes read or written and is edited under its lock, so
+// nobody reads a chunk that is created but not stored yet or loses an edit
+// to another writer. Locks are dropped once nobody holds or waits for them.
+var (
+	chunkLocks     = map[[2]int]*chunkLock{}
+	chunkLocksLock sync.Mutex
+)
+
+type chunkLock struct {
+	sync.Mutex
+	users int
+}
+
+// lockChunk waits for the lock of a chunk and returns what unlocks it.
+func lockChunk(x, z int) func() {
+	position := [2]int{x, z}
+	chunkLocksLock.Lock()
+	lock, ok := chunkLocks[position]
+	if !ok {
+		lock = &chunkLock{}
+		chunkLocks[position] = lock
+	}
+	lock.users++
+	chunkLocksLock.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		chunkLocksLock.Lock()
+		defer chunkLocksLock.Unlock()
+		if lock.users--; lock.users == 0 {
+			delete(chunkLocks, position)
+		}
+	}
+}
+
+// chunkOf splits a world coordinate into the chunk coordinate and the
+// position inside that chunk, rounding down for negative coordinates.
+func chunkOf(position int) (int, int) {
+	chunk := position / chunkSize
+	if position < 0 && position%chunkSize != 0 {
+		chunk--
+	}
+	return chunk, position - (chunk * chunkSize)
+}
+
+// SetBlocks applies edits chunk by chunk, generating chunks that do not
+// exist yet, and rewrites the stored cubes of every chunk it touched.
+// Clients see the changes the next time they load those chunks.
+func SetBlocks(edits []BlockEdit) error {
+	_, err := setBlocks(edits)
+	return err
+}
+
+// setBlocks is SetBlocks returning the drawables of every chunk it touched.
+func setBlocks(edits []BlockEdit) ([]*DataType.CubeChunk, error) {
+	if !inFlight.begin() {
+		return nil, errStopping
+	}
+	defer inFlight.end()
+	byChunk := map[[2]int][]BlockEdit{}
+	for _, edit := range edits {
+		if edit.Y < 0 || edit.Y >= maxHeight {
+			return nil, fmt.Errorf("block %v,%v,%v is outside the world height 0 to %v", edit.X, edit.Y, edit.Z, maxHeight-1)
+		}
+		x, _ := chunkOf(edit.X)
+		z, _ := chunkOf(edit.Z)
+		byChunk[[2]int{x, z}] = append(byChunk[[2]int{x, z}], edit)
+	}
+
+	changed := []*DataType.CubeChunk{}
+	for position, chunkEdits := range byChunk {
+		chunk, err := editChunk(position, chunkEdits)
+		if err != nil {
+			return nil, err
+		}
+		changed = append(changed, chunk)
+	}
+	return changed, nil
+}
+
+// editChunk applies the edits of one chunk under its lock and returns its
+// drawables.
+func editChunk(position [2]int, chunkEdits []BlockEdit) (*DataType.CubeChunk, error) {
+	unlock := lockChunk(position[0], position[1])
+	defer unlock()
+	c, cubes, err := loadChunkCubes(position[0], position[1], true)
+	if err != nil {
+		return nil, err
+	}
+	index := map[[3]int]*cube{}
+	for _, current := range cubes {
+		index[[3]int{int(current.XPos), int(current.YPos), int(current.ZPos)}] = current
+	}
+	for _, edit := range chunkEdits {
+		_, x := chunkOf(edit.X)
+		_, z := chunkOf(edit.Z)
+		if existing, ok := index[[3]int{x, edit.Y, z}]; ok {
+			existing.CubeType = edit.Block
+			continue
+		}
+		added := &cube{ChunkID: c.ID, XPos: int8(x), YPos: int8(edit.Y), ZPos: int8(z), CubeType: edit.Block}
+		index[[3]int{x, edit.Y, z}] = added
+		cubes = append(cubes, added)
+	}
+	drawables := filter(cubes)
+	if err = world.saveCubes(c, cubes); err != nil {
+		return nil, err
+	}
+	return &DataType.CubeChunk{XPos: c.XPos, ZPos: c.ZPos, Cubes: drawables}, nil
+}
+
+// blockCache keeps the blocks of every chunk it has read, keyed by chunk
+// and then by position inside the chunk.
+type blockCache map[[2]int]map[[3]int]uint8
+
+// GetBlock returns the block at a world position, generating the terrain in
+// memory where the chunk has not been stored.
+func GetBlock(x, y, z int) (uint8, error) {
+	return blockCache{}.get(x, y, z)
+}
+
+func (cache blockCache) get(x, y, z int) (uint8, error) {
+	chunkX, localX := chunkOf(x)
+	chunkZ, localZ := chunkOf(z)
+	blocks, ok := cache[[2]int{chunkX, chunkZ}]
+	if !ok {
+		var err error
+		if blocks, err = chunkBlocks(chunkX, chunkZ); err != nil {
+			return 0, err
+		}
+		cache[[2]int{chunkX, chunkZ}] = blocks
+	}
+	return blocks[[3]int{localX, y, localZ}], nil
+}
+
+// loaded returns the block at a world position without reading the chunk,
+// reporting false when the chunk is not in the cache.
+func (cache blockCache) loaded(x, y, z int) (uint8, bool) {
+	chunkX, localX := chunkOf(x)
+	chunkZ, localZ := chunkOf(z)
+	blocks, ok := cache[[2]int{chunkX, chunkZ}]
+	if !ok {
+		return 0, false
+	}
+	return blocks[[3]int{localX, y, localZ}], true
+}
+
+// chunkBlocks reads the blocks of a chunk, keyed by position inside it.
+func chunkBlocks(x, z int) (map[[3]int]uint8, error) {
+	if !inFlight.begin() {
+		return nil, errStopping
+	}
+	defer inFlight.end()
+	unlock := lockChunk(x, z)
+	_, cubes, err := loadChunkCubes(x, z, false)
+	unlock()
+	if err != nil {
+		return nil, err
+	}
+	blocks := map[[3]int]uint8{}
+	for _, current := range cubes {
+		blocks[[3]int{int(current.XPos), int(current.YPos), int(current.ZPos)}] = current.CubeType
+	}
+	return blocks, nil
+}
+
+// loadChunkCubes returns every stored cube of a chunk. Chunks that were never
+// generated are generated, and stored as well when create is set. The
+// chunk's lock has to be held.
+func loadChunkCubes(x, z int, create bool) (*DataType.Chunk, []*cube, error) {
+	c := &DataType.Chunk{XPos: x, ZPos: z}
+	found, err := world.findChunk(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !found {
+		if create {
+			return c, createChunk(c), nil
+		}
+		return c, generateCubes(c), nil
+	}
+
+	cubes, err := world.loadCubes(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, cubes, nil
+}
@@ -0,0 +1,144 @@
+package Server
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/allanks/Voxel-Engine/src/Server/DataType"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// memoryStorage keeps chunks in memory the way mongoStorage keeps them: a
+// chunk is found as soon as it is created, and storing cubes removes the
+// old ones a while before the new ones are in.
+type memoryStorage struct {
+	lock    sync.Mutex
+	chunks  map[[2]int]bson.ObjectId
+	cubes   map[bson.ObjectId][]cube
+	creates int
+	delay   time.Duration
+}
+
+// useMemoryStorage stores the world of a test in memory, taking delay to
+// store a chunk's cubes.
+func useMemoryStorage(t *testing.T, delay time.Duration) *memoryStorage {
+	useTestWorld(t)
+	useTestBlocks(t)
+	store := &memoryStorage{chunks: map[[2]int]bson.ObjectId{}, cubes: map[bson.ObjectId][]cube{}, delay: delay}
+	world = store
+	return store
+}
+
+func (store *memoryStorage) findChunk(c *DataType.Chunk) (bool, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	id, ok := store.chunks[[2]int{c.XPos, c.ZPos}]
+	c.ID = id
+	return ok, nil
+}
+
+func (store *memoryStorage) createChunk(c *DataType.Chunk) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	store.creates++
+	c.ID = bson.NewObjectId()
+	store.chunks[[2]int{c.XPos, c.ZPos}] = c.ID
+	return nil
+}
+
+func (store *memoryStorage) loadCubes(c *DataType.Chunk) ([]*cube, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	cubes := []*cube{}
+	for _, stored := range store.cubes[c.ID] {
+		current := stored
+		cubes = append(cubes, &current)
+	}
+	return cubes, nil
+}
+
+func (store *memoryStorage) saveCubes(c *DataType.Chunk, cubes []*cube) error {
+	stored := make([]cube, len(cubes))
+	for i, current := range cubes {
+		stored[i] = *current
+	}
+	store.lock.Lock()
+	delete(store.cubes, c.ID)
+	store.lock.Unlock()
+	time.Sleep(store.delay)
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	store.cubes[c.ID] = stored
+	return nil
+}
+
+func (store *memoryStorage) flush() error {
+	return nil
+}
+
+func (store *memoryStorage) close() error {
+	return nil
+}
+
+func TestConcurrentEditsKeepEveryEdit(t *testing.T) {
+	store := useMemoryStorage(t, 10*time.Millisecond)
+	var edits sync.WaitGroup
+	for x := 0; x < 8; x++ {
+		edits.Add(1)
+		go func(x int) {
+			defer edits.Done()
+			if err := SetBlocks([]BlockEdit{{X: x, Y: 100, Z: 3, Block: stone}}); err != nil {
+				t.Error(err)
+			}
+		}(x)
+	}
+	edits.Wait()
+
+	blocks, err := chunkBlocks(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x := 0; x < 8; x++ {
+		if blocks[[3]int{x, 100, 3}] != stone {
+			t.Errorf("edit at %v,100,3 was lost", x)
+		}
+	}
+	if store.creates != 1 {
+		t.Errorf("chunk created %v times, want once", store.creates)
+	}
+}
+
+func TestChunkBeingStoredIsWaitedFor(t *testing.T) {
+	store := useMemoryStorage(t, 20*time.Millisecond)
+	useTestShutdown(t)
+
+	generated := loadChunk(&DataType.Chunk{XPos: 0, ZPos: 0})
+	// The generated cubes are still being stored in the background
+	loaded := loadChunk(&DataType.Chunk{XPos: 0, ZPos: 0})
+	if len(loaded.Cubes) != len(generated.Cubes) {
+		t.Errorf("chunk loaded while it was stored has %v drawables, want %v", len(loaded.Cubes)/4, len(generated.Cubes)/4)
+	}
+
+	loadChunk(&DataType.Chunk{XPos: 1, ZPos: 0})
+	if err := SetBlocks([]BlockEdit{{X: 17, Y: 100, Z: 3, Block: stone}}); err != nil {
+		t.Fatal(err)
+	}
+	inFlight.wait()
+	blocks, err := chunkBlocks(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blocks[[3]int{1, 100, 3}] != stone {
+		t.Error("edit made while the chunk was stored was lost")
+	}
+	if blocks[[3]int{1, 0, 3}] != stone {
+		t.Error("generated terrain lost to an edit made while the chunk was stored")
+	}
+	if store.creates != 2 {
+		t.Errorf("%v chunks created, want 2", store.creates)
+	}
+	if len(chunkLocks) != 0 {
+		t.Errorf("%v chunk locks kept after every chunk was unlocked", len(chunkLocks))
+	}
+}
@@ -1,8 +1,130 @@
 package main
 
-import "github.com/allanks/Voxel-Engine/src/Server"
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/allanks/Voxel-Engine/src/Server"
+)
+
+const usage = `usage:
+	ServerStarter [flags]                                       run the server, reading console commands from stdin
+	ServerStarter [flags] stamp <file.vox> <x> <y> <z>          place a .vox model in the world
+	ServerStarter [flags] export <file.vox> <x0> <y0> <z0> <x1> <y1> <z1>
+	                                                            write a world region to a .vox file
+	ServerStarter [flags] pregen <radius> [x z]                 generate the chunks within radius chunks of chunk x z
+	ServerStarter [flags] pregen <x0> <z0> <x1> <z1>            generate the chunks from chunk x0 z0 to chunk x1 z1,
+	                                                            an interrupted pregen carries on when run again
+flags:`
 
 func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.IntVar(&Server.Port, "port", Server.Port, "TCP port clients connect to")
+	flag.StringVar(&Server.WorldDirectory, "world", Server.WorldDirectory, "directory of the world's log and, with file storage, its chunks")
+	flag.Int64Var(&Server.Seed, "seed", Server.Seed, "terrain seed, has to match the seed the world's chunks were generated with")
+	flag.StringVar(&Server.StorageBackend, "storage", Server.StorageBackend, "where the world is kept, "+Server.StorageMongo+" or "+Server.StorageFile)
+	flag.StringVar(&Server.MongoAddress, "mongo", Server.MongoAddress, "MongoDB server used by mongo storage")
+	flag.BoolVar(&Server.AllowFlight, "fly", Server.AllowFlight, "let players fly")
+	flag.IntVar(&Server.PregenWorkers, "workers", Server.PregenWorkers, "how many chunks pregen generates at once")
+	flag.DurationVar(&Server.ShutdownTimeout, "shutdown-timeout", Server.ShutdownTimeout, "how long stopping waits for chunks being generated and stored")
+	logLevel := flag.String("log", "info", "least level logged, debug, info, warn or error")
+	flag.Parse()
+
+	level, err := Server.ParseLogLevel(*logLevel)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	Server.LogLevel = level
+	if Server.PregenWorkers < 1 {
+		fmt.Printf("-workers has to be at least 1, got %v\n", Server.PregenWorkers)
+		os.Exit(2)
+	}
+
 	Server.LoadGameMap()
-	Server.InitServer()
+	args := flag.Args()
+	if len(args) == 0 {
+		go stopOnSignal()
+		go Server.RunConsole(os.Stdin)
+		Server.InitServer()
+		return
+	}
+
+	switch args[0] {
+	case "stamp":
+		coordinates := parseCoordinates(args[1:], 3)
+		err = Server.StampVox(args[1], coordinates[0], coordinates[1], coordinates[2])
+	case "export":
+		coordinates := parseCoordinates(args[1:], 6)
+		err = Server.ExportVox(args[1], coordinates[0], coordinates[1], coordinates[2], coordinates[3], coordinates[4], coordinates[5])
+	case "pregen":
+		err = pregenerate(args[1:])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err == nil {
+		err = Server.Save()
+	}
+	Server.Shutdown()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// pregenerate generates a region of the world until it is done or a signal
+// stops it, printing what it did.
+func pregenerate(args []string) error {
+	x0, z0, x1, z1, err := Server.ParsePregenRegion(args)
+	if err != nil {
+		fmt.Println(err)
+		flag.Usage()
+		os.Exit(2)
+	}
+	go stopOnSignal()
+	summary, err := Server.Pregenerate(x0, z0, x1, z1)
+	if err != nil {
+		return fmt.Errorf("pregeneration stopped after %v: %v", summary, err)
+	}
+	fmt.Printf("Pregenerated %v\n", summary)
+	return nil
+}
+
+// stopOnSignal stops the server on SIGINT or SIGTERM, a second signal exits
+// without waiting for the server to finish stopping.
+func stopOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	received := <-signals
+	fmt.Printf("Received %v, stopping\n", received)
+	Server.Stop()
+	<-signals
+	fmt.Println("Exiting without stopping")
+	os.Exit(1)
+}
+
+// parseCoordinates reads count integers following the file argument.
+func parseCoordinates(args []string, count int) []int {
+	if len(args) != count+1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	coordinates := make([]int, count)
+	for i := range coordinates {
+		value, err := strconv.Atoi(args[i+1])
+		if err != nil {
+			fmt.Printf("%q is not a coordinate\n", args[i+1])
+			os.Exit(2)
+		}
+		coordinates[i] = value
+	}
+	return coordinates
 }
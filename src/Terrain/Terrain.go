@@ -1,26 +1,31 @@
 package Terrain
 
 import (
-	"encoding/gob"
 	"fmt"
-	"log"
 	m "math"
-	"net"
 
+	"github.com/allanks/Voxel-Engine/src/Block"
+	"github.com/allanks/Voxel-Engine/src/Camera"
 	"github.com/allanks/Voxel-Engine/src/Model"
+	"github.com/allanks/Voxel-Engine/src/Network"
 	"github.com/allanks/Voxel-Engine/src/Server/DataType"
+	"github.com/go-gl/mathgl/mgl32"
 )
 
 const (
-	chunkSize  int = 16
-	renderSize int = 8
-	viewSize   int = 32
+	chunkSize   int = 16
+	chunkHeight int = 128
+	renderSize  int = 8
+	viewSize    int = 32
 )
 
-var conn net.Conn
+// chunkData passes the chunks the server sends to the goroutine that
+// requested them. The server answers requests in order.
+var chunkData = make(chan *DataType.CubeChunk)
 
 type Level struct {
-	chunks []*clientChunk
+	chunks                    []*clientChunk
+	drawnChunks, culledChunks int
 }
 
 type clientChunk struct {
@@ -41,7 +46,7 @@ func (gameMap *Level) GetYCubes(xPos, yPos, zPos, height float64) []float32 {
 		}
 		if isInRange(pX/chunkSize, c.XPos) && isInRange(pZ/chunkSize, c.ZPos) {
 			for i := 0; i < len(c.drawables)/4; i++ {
-				if int(m.Floor(float64(c.drawables[(i*4)+3]))) != DataType.Empty &&
+				if Block.IsSolid(DataType.FloorToInt(c.drawables[(i*4)+3])) &&
 					int(m.Floor(float64(c.drawables[i*4])))+(c.XPos*chunkSize) == pX &&
 					int(m.Floor(float64(c.drawables[(i*4)+2])))+(c.ZPos*chunkSize) == pZ &&
 					(isInRange(int(m.Floor(float64(c.drawables[(i*4)+1]))), pY) ||
@@ -61,7 +66,7 @@ func (gameMap *Level) GetCubes(query []DataType.Pos) []DataType.Pos {
 		for _, chunk := range gameMap.chunks {
 			if qx/chunkSize == chunk.XPos && qz/chunkSize == chunk.ZPos {
 				for i := 0; i < len(chunk.drawables)/4; i++ {
-					if DataType.FloorToInt(chunk.drawables[(i*4)+3]) != DataType.Empty &&
+					if Block.IsSolid(DataType.FloorToInt(chunk.drawables[(i*4)+3])) &&
 						DataType.FloorToInt(chunk.drawables[i*4])+(chunk.XPos*chunkSize) == qx &&
 						DataType.FloorToInt(chunk.drawables[(i*4)+1]) == qy &&
 						DataType.FloorToInt(chunk.drawables[(i*4)+2])+(chunk.ZPos*chunkSize) == qz {
@@ -77,6 +82,32 @@ func (gameMap *Level) GetCubes(query []DataType.Pos) []DataType.Pos {
 	return cubes
 }
 
+// IsSolid reports whether a solid block fills the cell, blocks in chunks
+// that have not arrived yet are not solid.
+func (gameMap *Level) IsSolid(x, y, z int) bool {
+	cX, cZ := floorDiv(x, chunkSize), floorDiv(z, chunkSize)
+	for _, c := range gameMap.chunks {
+		if c == nil || c.XPos != cX || c.ZPos != cZ {
+			continue
+		}
+		for i := 0; i < len(c.drawables)/4; i++ {
+			if DataType.FloorToInt(c.drawables[i*4])+(cX*chunkSize) == x &&
+				DataType.FloorToInt(c.drawables[(i*4)+1]) == y &&
+				DataType.FloorToInt(c.drawables[(i*4)+2])+(cZ*chunkSize) == z {
+				return Block.IsSolid(DataType.FloorToInt(c.drawables[(i*4)+3]))
+			}
+		}
+	}
+	return false
+}
+
+func floorDiv(value, size int) int {
+	if value < 0 {
+		return ((value + 1) / size) - 1
+	}
+	return value / size
+}
+
 func (gameMap *Level) GetXZCubes(xPos, yPos, zPos float64) []float32 {
 	pX := int(m.Floor(xPos))
 	pY := int(m.Floor(yPos))
@@ -88,7 +119,7 @@ func (gameMap *Level) GetXZCubes(xPos, yPos, zPos float64) []float32 {
 		}
 		if isInRange(pX/chunkSize, c.XPos) && isInRange(pZ/chunkSize, c.ZPos) {
 			for i := 0; i < len(c.drawables)/4; i++ {
-				if int(m.Floor(float64(c.drawables[(i*4)+3]))) != DataType.Empty &&
+				if Block.IsSolid(DataType.FloorToInt(c.drawables[(i*4)+3])) &&
 					int(m.Floor(float64(c.drawables[(i*4)+1]))) == pY &&
 					isInRange(int(m.Floor(float64(c.drawables[i*4])))+(c.XPos*chunkSize), pX) &&
 					isInRange(int(m.Floor(float64(c.drawables[(i*4)+2])))+(c.ZPos*chunkSize), pZ) {
@@ -104,16 +135,42 @@ func isInRange(static, dynamic int) bool {
 	return static == dynamic || static == dynamic+1 || static == dynamic-1
 }
 
-func (gameMap *Level) RenderLevel() {
+func (gameMap *Level) RenderLevel(frustum Camera.Frustum) {
 
-	for _, c := range gameMap.chunks {
+	visible, culled := cullChunks(gameMap.chunks, frustum)
+	for _, c := range visible {
+		Model.BindBuffers([]float32{float32(c.XPos * chunkSize), 0.0, float32(c.ZPos * chunkSize)}, Model.Cube)
+		Model.Render(c.drawables, Model.Cube)
+	}
+	gameMap.drawnChunks, gameMap.culledChunks = len(visible), culled
+}
+
+// GetRenderStats returns how many chunks the last RenderLevel drew and how
+// many it skipped for being outside the view frustum.
+func (gameMap *Level) GetRenderStats() (int, int) {
+	return gameMap.drawnChunks, gameMap.culledChunks
+}
+
+func cullChunks(chunks []*clientChunk, frustum Camera.Frustum) ([]*clientChunk, int) {
+	visible := []*clientChunk{}
+	culled := 0
+	for _, c := range chunks {
 		if c == nil || len(c.drawables) == 0 {
 			continue
 		}
-
-		Model.BindBuffers([]float32{float32(c.XPos * chunkSize), 0.0, float32(c.ZPos * chunkSize)}, Model.Cube)
-		Model.Render(c.drawables, Model.Cube)
+		if frustum.IntersectsBox(chunkBounds(c.XPos, c.ZPos)) {
+			visible = append(visible, c)
+		} else {
+			culled++
+		}
 	}
+	return visible, culled
+}
+
+func chunkBounds(x, z int) (mgl32.Vec3, mgl32.Vec3) {
+	min := mgl32.Vec3{float32(x * chunkSize), -1, float32(z * chunkSize)}
+	max := mgl32.Vec3{float32((x + 1) * chunkSize), float32(chunkHeight), float32((z + 1) * chunkSize)}
+	return min, max
 }
 
 func (gameMap *Level) removeOldChunks(x, z int) {
@@ -159,13 +216,8 @@ func (gameMap *Level) updateChunk(cubes *DataType.CubeChunk) {
 }
 
 func (gameMap *Level) loadChunkFromServer(c *clientChunk) {
-	encoder := gob.NewEncoder(conn)
-	encoder.Encode(c.Chunk)
-	cubes := &DataType.CubeChunk{}
-	decoder := gob.NewDecoder(conn)
-	err := decoder.Decode(cubes)
-	if err == nil {
-		gameMap.updateChunk(cubes)
+	if err := Network.Send(&DataType.Message{Type: DataType.ChunkRequest, Chunk: &c.Chunk}); err == nil {
+		gameMap.updateChunk(<-chunkData)
 	}
 }
 
@@ -187,16 +239,26 @@ func (gameMap *Level) LoopChunkLoader(pX, pZ float64) {
 	}
 }
 
+// Listen replaces the drawables of loaded chunks the server changed.
+func (gameMap *Level) Listen() {
+	Network.Handle(DataType.ChunkUpdate, func(message *DataType.Message) {
+		if message.Cubes != nil {
+			gameMap.updateChunk(message.Cubes)
+		}
+	})
+}
+
 func StartConnection() {
-	var err error
-	conn, err = net.Dial("tcp", "localhost:8080")
-	if err != nil {
-		log.Fatal("Connection error", err)
-	}
+	Network.Handle(DataType.ChunkData, func(message *DataType.Message) {
+		if message.Cubes != nil {
+			chunkData <- message.Cubes
+		}
+	})
+	Network.Connect("localhost:8080")
 }
 
 func CloseConnection() {
-	conn.Close()
+	Network.Close()
 }
 
 func (gameMap *Level) PrintChunks() {
@@ -0,0 +1,68 @@
+package Terrain
+
+import (
+	"testing"
+
+	"github.com/allanks/Voxel-Engine/src/Camera"
+	"github.com/allanks/Voxel-Engine/src/Graphics/Game/Headless"
+	controlHeadless "github.com/allanks/Voxel-Engine/src/Graphics/Headless"
+	"github.com/allanks/Voxel-Engine/src/Model"
+	"github.com/allanks/Voxel-Engine/src/Server/DataType"
+)
+
+func TestCullChunks(t *testing.T) {
+	camera := createTestCamera()
+	chunks := []*clientChunk{
+		createTestChunk(0, 0, 1),
+		createTestChunk(0, -2, 1),
+		createTestChunk(0, 1, 1),
+		createTestChunk(-3, -3, 1),
+		createTestChunk(0, -1, 0),
+		nil,
+	}
+
+	visible, culled := cullChunks(chunks, camera.Frustum())
+	if len(visible) != 2 || culled != 2 {
+		t.Fatalf("cullChunks drew %v and culled %v chunks, want 2 and 2", len(visible), culled)
+	}
+	for _, c := range visible {
+		if c.ZPos > 0 {
+			t.Errorf("chunk %v %v behind the camera was drawn", c.XPos, c.ZPos)
+		}
+	}
+}
+
+func TestRenderLevelDrawsVisibleChunks(t *testing.T) {
+	game := &Headless.HeadlessGame{Control: &controlHeadless.HeadlessControl{}}
+	game.StartPrograms()
+	Model.Controller = game
+
+	gameMap := &Level{chunks: []*clientChunk{
+		createTestChunk(0, 0, 2),
+		createTestChunk(0, -2, 3),
+		createTestChunk(0, 2, 1),
+	}}
+	gameMap.RenderLevel(createTestCamera().Frustum())
+
+	if drawn, culled := gameMap.GetRenderStats(); drawn != 2 || culled != 1 {
+		t.Fatalf("RenderLevel drew %v and culled %v chunks, want 2 and 1", drawn, culled)
+	}
+	if len(game.DrawCalls) != 2 || game.Instances() != 5 {
+		t.Fatalf("recorded %v draw calls of %v instances, want 2 of 5", len(game.DrawCalls), game.Instances())
+	}
+	if offset := game.DrawCalls[1].Uniforms.Offset; offset != [3]float32{0, 0, float32(-2 * chunkSize)} {
+		t.Errorf("second chunk drawn at offset %v, want 0 0 %v", offset, -2*chunkSize)
+	}
+}
+
+// createTestCamera looks down -Z from the middle of chunk 0 0 with a narrow
+// field of view, so chunks far to the side fall outside it.
+func createTestCamera() *Camera.Camera {
+	camera := Camera.CreateCamera(45, 1, 0.1, 200)
+	camera.Follow(8, 64, 8)
+	return camera
+}
+
+func createTestChunk(x, z, cubes int) *clientChunk {
+	return &clientChunk{Chunk: DataType.Chunk{XPos: x, ZPos: z}, loaded: true, drawables: make([]float32, cubes*4)}
+}
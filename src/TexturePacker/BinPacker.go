@@ -0,0 +1,162 @@
+package TexturePacker
+
+import (
+	"fmt"
+	"image"
+	"sort"
+)
+
+type placement struct {
+	page int
+	rect image.Rectangle
+}
+
+// skyline packs rectangles bottom-left into a fixed page by tracking the
+// top edge of everything placed so far as a list of horizontal segments.
+type skyline struct {
+	width, height int
+	segments      []segment
+}
+
+type segment struct {
+	x, y, width int
+}
+
+func createSkyline(width, height int) *skyline {
+	return &skyline{width, height, []segment{segment{0, 0, width}}}
+}
+
+// insert finds the lowest, then leftmost, position the rectangle fits at.
+func (line *skyline) insert(size image.Point) (image.Point, bool) {
+	best, bestY, bestX := -1, line.height, line.width
+	for i := range line.segments {
+		y, ok := line.fits(i, size)
+		if ok && (y < bestY || (y == bestY && line.segments[i].x < bestX)) {
+			best, bestY, bestX = i, y, line.segments[i].x
+		}
+	}
+	if best == -1 {
+		return image.ZP, false
+	}
+
+	placed := segment{bestX, bestY + size.Y, size.X}
+	line.segments = append(line.segments[:best], append([]segment{placed}, line.segments[best:]...)...)
+
+	// Trim the segments now hidden under the new one
+	for i := best + 1; i < len(line.segments); i++ {
+		current := &line.segments[i]
+		overlap := (placed.x + placed.width) - current.x
+		if overlap <= 0 {
+			break
+		}
+		if overlap < current.width {
+			current.x += overlap
+			current.width -= overlap
+			break
+		}
+		line.segments = append(line.segments[:i], line.segments[i+1:]...)
+		i--
+	}
+	line.merge()
+	return image.Point{bestX, bestY}, true
+}
+
+// fits returns the height a rectangle would sit at when its left edge is
+// placed on segment index, or false if it would leave the page.
+func (line *skyline) fits(index int, size image.Point) (int, bool) {
+	x := line.segments[index].x
+	if x+size.X > line.width {
+		return 0, false
+	}
+	y := 0
+	remaining := size.X
+	for i := index; remaining > 0; i++ {
+		if i == len(line.segments) {
+			return 0, false
+		}
+		if line.segments[i].y > y {
+			y = line.segments[i].y
+		}
+		remaining -= line.segments[i].width
+	}
+	return y, y+size.Y <= line.height
+}
+
+func (line *skyline) merge() {
+	for i := 0; i+1 < len(line.segments); i++ {
+		if line.segments[i].y == line.segments[i+1].y {
+			line.segments[i].width += line.segments[i+1].width
+			line.segments = append(line.segments[:i+1], line.segments[i+2:]...)
+			i--
+		}
+	}
+}
+
+// packRectangles places every size and returns the page sizes used. It looks
+// for the smallest power of two page, square or twice as wide as it is tall,
+// that holds everything and only spills onto more pages once maxAtlasSize is
+// reached.
+func packRectangles(sizes []image.Point) ([]image.Point, []placement, error) {
+	order := make([]int, len(sizes))
+	for i := range order {
+		order[i] = i
+		if sizes[i].X > maxAtlasSize || sizes[i].Y > maxAtlasSize {
+			return nil, nil, fmt.Errorf("texture of %vx%v is larger than the %v atlas", sizes[i].X, sizes[i].Y, maxAtlasSize)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := sizes[order[i]], sizes[order[j]]
+		if a.Y != b.Y {
+			return a.Y > b.Y
+		}
+		return a.X > b.X
+	})
+
+	for size := minAtlasSize; size <= maxAtlasSize; size *= 2 {
+		for _, page := range []image.Point{image.Point{size, size / 2}, image.Point{size, size}} {
+			if page.X == maxAtlasSize && page.Y == maxAtlasSize {
+				break
+			}
+			if placements, ok := packPage(sizes, order, page); ok {
+				return []image.Point{page}, placements, nil
+			}
+		}
+	}
+
+	pages := []*skyline{}
+	placements := make([]placement, len(sizes))
+	for _, i := range order {
+		placed := false
+		for page, line := range pages {
+			if at, ok := line.insert(sizes[i]); ok {
+				placements[i] = placement{page, image.Rectangle{at, at.Add(sizes[i])}}
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			line := createSkyline(maxAtlasSize, maxAtlasSize)
+			at, _ := line.insert(sizes[i])
+			pages = append(pages, line)
+			placements[i] = placement{len(pages) - 1, image.Rectangle{at, at.Add(sizes[i])}}
+		}
+	}
+	pageSizes := make([]image.Point, len(pages))
+	for i := range pages {
+		pageSizes[i] = image.Point{maxAtlasSize, maxAtlasSize}
+	}
+	return pageSizes, placements, nil
+}
+
+func packPage(sizes []image.Point, order []int, page image.Point) ([]placement, bool) {
+	line := createSkyline(page.X, page.Y)
+	placements := make([]placement, len(sizes))
+	for _, i := range order {
+		at, ok := line.insert(sizes[i])
+		if !ok {
+			return nil, false
+		}
+		placements[i] = placement{0, image.Rectangle{at, at.Add(sizes[i])}}
+	}
+	return placements, true
+}
@@ -0,0 +1,59 @@
+package TexturePacker
+
+import (
+	"image"
+)
+
+// paddedSize grows a texture by the padding on every side and rounds it up
+// to a multiple of the padding, which keeps tiles aligned at every mip level.
+func paddedSize(size image.Point) image.Point {
+	align := func(value int) int {
+		return ((value + (2 * tilePadding) + tilePadding - 1) / tilePadding) * tilePadding
+	}
+	return image.Point{align(size.X), align(size.Y)}
+}
+
+// extrude fills the padding between inner and outer by repeating the edge
+// pixels of inner outwards, so filtering near a tile edge never picks up a
+// neighbouring tile.
+func extrude(atlas *image.RGBA, outer, inner image.Rectangle) {
+	for y := inner.Min.Y; y < inner.Max.Y; y++ {
+		left, right := atlas.RGBAAt(inner.Min.X, y), atlas.RGBAAt(inner.Max.X-1, y)
+		for x := outer.Min.X; x < inner.Min.X; x++ {
+			atlas.SetRGBA(x, y, left)
+		}
+		for x := inner.Max.X; x < outer.Max.X; x++ {
+			atlas.SetRGBA(x, y, right)
+		}
+	}
+	rowLength := outer.Dx() * 4
+	copyRow := func(from, to int) {
+		start := atlas.PixOffset(outer.Min.X, from)
+		copy(atlas.Pix[atlas.PixOffset(outer.Min.X, to):], atlas.Pix[start:start+rowLength])
+	}
+	for y := outer.Min.Y; y < inner.Min.Y; y++ {
+		copyRow(inner.Min.Y, y)
+	}
+	for y := inner.Max.Y; y < outer.Max.Y; y++ {
+		copyRow(inner.Max.Y-1, y)
+	}
+}
+
+// downsample halves the image with a 2x2 box filter. Tiles sit on even
+// coordinates at every level we generate so no box straddles two tiles.
+func downsample(src *image.RGBA) *image.RGBA {
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx()/2, bounds.Dy()/2))
+	for y := 0; y < dst.Rect.Dy(); y++ {
+		for x := 0; x < dst.Rect.Dx(); x++ {
+			i := src.PixOffset(bounds.Min.X+(x*2), bounds.Min.Y+(y*2))
+			j := i + src.Stride
+			o := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				sum := int(src.Pix[i+c]) + int(src.Pix[i+4+c]) + int(src.Pix[j+c]) + int(src.Pix[j+4+c])
+				dst.Pix[o+c] = uint8((sum + 2) / 4)
+			}
+		}
+	}
+	return dst
+}
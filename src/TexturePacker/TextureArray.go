@@ -0,0 +1,134 @@
+package TexturePacker
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"io/ioutil"
+	"path/filepath"
+)
+
+const (
+	ArrayManifestFile string = "resource/texture/textureArray.json"
+	arrayName         string = "textureArray"
+)
+
+// ArrayManifest describes the layered form of the textures: one image with
+// every layer stacked top to bottom, uploaded as a GL_TEXTURE_2D_ARRAY.
+type ArrayManifest struct {
+	File        string         `json:"file"`
+	LayerWidth  int            `json:"layerWidth"`
+	LayerHeight int            `json:"layerHeight"`
+	Layers      map[string]int `json:"layers"`
+	Source      string         `json:"source"`
+	directory   string
+}
+
+func (manifest *ArrayManifest) ArrayFile() string {
+	return filepath.Join(manifest.directory, manifest.File)
+}
+
+func (manifest *ArrayManifest) LayerCount() int {
+	return len(manifest.Layers)
+}
+
+// ArrayUpToDate reports whether the texture array of manifestFile was packed
+// from the textures now in directory.
+func ArrayUpToDate(directory, manifestFile string) bool {
+	manifest, err := LoadArrayManifest(manifestFile)
+	if err != nil {
+		return false
+	}
+	source, err := sourceHash(directory)
+	return err == nil && source == manifest.Source
+}
+
+func PackTextureArray() {
+	if _, err := PackDirectoryLayers(TextureDirectory, ArrayManifestFile); err != nil {
+		panic(err)
+	}
+}
+
+// PackDirectoryLayers writes every PNG below directory as one layer of a
+// texture array. Layers share the most common texture size, other textures
+// are scaled to fit it. Layers are numbered in name order.
+func PackDirectoryLayers(directory, manifestFile string) (*ArrayManifest, error) {
+	textures, err := discoverTextures(directory)
+	if err != nil {
+		return nil, err
+	}
+	source, err := sourceHash(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	layerSize := commonSize(textures)
+	manifest := &ArrayManifest{
+		File:        arrayName + ".png",
+		LayerWidth:  layerSize.X,
+		LayerHeight: layerSize.Y,
+		Layers:      map[string]int{},
+		Source:      source,
+		directory:   filepath.Dir(manifestFile),
+	}
+
+	layers := image.NewRGBA(image.Rect(0, 0, manifest.LayerWidth, manifest.LayerHeight*len(textures)))
+	for i, tex := range textures {
+		layer := image.Rect(0, i*manifest.LayerHeight, manifest.LayerWidth, (i+1)*manifest.LayerHeight)
+		scaleInto(layers, layer, tex.img)
+		manifest.Layers[tex.name] = i
+	}
+
+	if err = SavePNG(manifest.ArrayFile(), layers); err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(manifest, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return manifest, ioutil.WriteFile(manifestFile, data, 0644)
+}
+
+func LoadArrayManifest(file string) (*ArrayManifest, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &ArrayManifest{directory: filepath.Dir(file)}
+	if err = json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("%v: %v", file, err)
+	}
+	return manifest, nil
+}
+
+// commonSize returns the size most textures have, preferring the larger size
+// on a tie.
+func commonSize(textures []texture) image.Point {
+	counts := map[image.Point]int{}
+	best := image.ZP
+	for _, tex := range textures {
+		size := tex.img.Bounds().Size()
+		counts[size]++
+		if counts[size] > counts[best] || (counts[size] == counts[best] && size.X*size.Y > best.X*best.Y) {
+			best = size
+		}
+	}
+	return best
+}
+
+// scaleInto draws img over rect using nearest neighbour sampling.
+func scaleInto(dst *image.RGBA, rect image.Rectangle, img image.Image) {
+	bounds := img.Bounds()
+	if bounds.Size() == rect.Size() {
+		draw.Draw(dst, rect, img, bounds.Min, draw.Src)
+		return
+	}
+	for y := 0; y < rect.Dy(); y++ {
+		for x := 0; x < rect.Dx(); x++ {
+			sx := bounds.Min.X + ((x * bounds.Dx()) / rect.Dx())
+			sy := bounds.Min.Y + ((y * bounds.Dy()) / rect.Dy())
+			dst.Set(rect.Min.X+x, rect.Min.Y+y, img.At(sx, sy))
+		}
+	}
+}
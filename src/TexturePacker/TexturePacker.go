@@ -1,80 +1,279 @@
 package TexturePacker
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"image"
 	"image/draw"
 	"image/png"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 )
 
-func PackTextures() {
-	textureAtlas := image.NewRGBA(image.Rect(0, 0, 2048, 2048))
+const (
+	TextureDirectory string = "resource/texture"
+	ManifestFile     string = "resource/texture/textureAtlas.json"
+	atlasName        string = "textureAtlas"
+	minAtlasSize     int    = 256
+	maxAtlasSize     int    = 4096
+	// Every tile is surrounded by tilePadding extruded border pixels and
+	// placed on a multiple of it, so each of the mipLevels generated below
+	// the base level still has at least one pixel of padding per tile.
+	tilePadding int = 16
+	mipLevels   int = 4
+)
+
+// Manifest names every packed texture and the UV rectangle it occupies.
+// UVs are normalised with v running down the image, which is the order the
+// rows are uploaded to GL. Source is the hash of the textures it was packed
+// from.
+type Manifest struct {
+	Atlases   []Atlas                  `json:"atlases"`
+	Textures  map[string]TextureRegion `json:"textures"`
+	Source    string                   `json:"source"`
+	directory string
+}
+
+type Atlas struct {
+	File    string   `json:"file"`
+	Width   int      `json:"width"`
+	Height  int      `json:"height"`
+	Mipmaps []string `json:"mipmaps"`
+}
+
+type TextureRegion struct {
+	Atlas int     `json:"atlas"`
+	U0    float32 `json:"u0"`
+	V0    float32 `json:"v0"`
+	U1    float32 `json:"u1"`
+	V1    float32 `json:"v1"`
+}
+
+type texture struct {
+	name string
+	img  image.Image
+}
+
+// MapUV maps a coordinate in the unit square onto the region.
+func (region TextureRegion) MapUV(s, t float32) (float32, float32) {
+	return region.U0 + (s * (region.U1 - region.U0)), region.V0 + (t * (region.V1 - region.V0))
+}
+
+// AtlasFile returns the path of an atlas image, which is stored next to the
+// manifest.
+func (manifest *Manifest) AtlasFile(atlas int) string {
+	return filepath.Join(manifest.directory, manifest.Atlases[atlas].File)
+}
 
-	mr := image.Rectangle{image.Point{0, 0}, image.Point{512, 512}}
-	r := image.Rectangle{image.Point{0, 0}, image.Point{512, 512}}
-	img := loadPNG("SkyBox/top.png")
-	draw.DrawMask(textureAtlas, r, img, image.ZP, img, mr.Min, draw.Src)
+// MipmapFiles returns the base atlas image followed by each of its mip levels.
+func (manifest *Manifest) MipmapFiles(atlas int) []string {
+	files := []string{manifest.AtlasFile(atlas)}
+	for _, mipmap := range manifest.Atlases[atlas].Mipmaps {
+		files = append(files, filepath.Join(manifest.directory, mipmap))
+	}
+	return files
+}
+
+// AtlasUpToDate reports whether the atlas of manifestFile was packed from the
+// textures now in directory.
+func AtlasUpToDate(directory, manifestFile string) bool {
+	manifest, err := LoadManifest(manifestFile)
+	if err != nil {
+		return false
+	}
+	source, err := sourceHash(directory)
+	return err == nil && source == manifest.Source
+}
+
+func PackTextures() {
+	if _, err := PackDirectory(TextureDirectory, ManifestFile); err != nil {
+		panic(err)
+	}
+}
 
-	r = image.Rectangle{image.Point{512, 0}, image.Point{1024, 512}}
-	img = loadPNG("SkyBox/bottom.png")
-	draw.DrawMask(textureAtlas, r, img, image.ZP, img, mr.Min, draw.Src)
+// PackDirectory packs every PNG below directory into as few atlases as
+// possible and writes them, with their manifest, next to manifestFile.
+// Textures are named by their path relative to directory, lower cased and
+// without the extension, e.g. "skybox/top".
+func PackDirectory(directory, manifestFile string) (*Manifest, error) {
+	textures, err := discoverTextures(directory)
+	if err != nil {
+		return nil, err
+	}
+	source, err := sourceHash(directory)
+	if err != nil {
+		return nil, err
+	}
 
-	r = image.Rectangle{image.Point{0, 512}, image.Point{512, 1024}}
-	img = loadPNG("SkyBox/left.png")
-	draw.DrawMask(textureAtlas, r, img, image.ZP, img, mr.Min, draw.Src)
+	sizes := make([]image.Point, len(textures))
+	for i, tex := range textures {
+		sizes[i] = paddedSize(tex.img.Bounds().Size())
+	}
+	pages, placements, err := packRectangles(sizes)
+	if err != nil {
+		return nil, err
+	}
 
-	r = image.Rectangle{image.Point{512, 512}, image.Point{1024, 1024}}
-	img = loadPNG("SkyBox/right.png")
-	draw.DrawMask(textureAtlas, r, img, image.ZP, img, mr.Min, draw.Src)
+	outDirectory := filepath.Dir(manifestFile)
+	manifest := &Manifest{Textures: map[string]TextureRegion{}, Source: source, directory: outDirectory}
+	atlases := make([]*image.RGBA, len(pages))
+	for i, size := range pages {
+		atlases[i] = image.NewRGBA(image.Rectangle{image.ZP, size})
+		name := atlasName
+		if i > 0 {
+			name = fmt.Sprintf("%v%v", atlasName, i)
+		}
+		manifest.Atlases = append(manifest.Atlases, Atlas{File: name + ".png", Width: size.X, Height: size.Y})
+		for level := 1; level <= mipLevels; level++ {
+			manifest.Atlases[i].Mipmaps = append(manifest.Atlases[i].Mipmaps, fmt.Sprintf("%v.mip%v.png", name, level))
+		}
+	}
 
-	r = image.Rectangle{image.Point{0, 1024}, image.Point{512, 1536}}
-	img = loadPNG("SkyBox/front.png")
-	draw.DrawMask(textureAtlas, r, img, image.ZP, img, mr.Min, draw.Src)
+	for i, tex := range textures {
+		place := placements[i]
+		inner := image.Rectangle{place.rect.Min.Add(image.Point{tilePadding, tilePadding}), image.ZP}
+		inner.Max = inner.Min.Add(tex.img.Bounds().Size())
+		draw.Draw(atlases[place.page], inner, tex.img, tex.img.Bounds().Min, draw.Src)
+		extrude(atlases[place.page], place.rect, inner)
+		manifest.Textures[tex.name] = regionOf(placement{place.page, inner}, pages[place.page])
+	}
 
-	r = image.Rectangle{image.Point{512, 1024}, image.Point{1024, 1536}}
-	img = loadPNG("SkyBox/back.png")
-	draw.DrawMask(textureAtlas, r, img, image.ZP, img, mr.Min, draw.Src)
+	for i, atlas := range atlases {
+		if err = SavePNG(filepath.Join(outDirectory, manifest.Atlases[i].File), atlas); err != nil {
+			return nil, err
+		}
+		level := atlas
+		for _, mipmap := range manifest.Atlases[i].Mipmaps {
+			level = downsample(level)
+			if err = SavePNG(filepath.Join(outDirectory, mipmap), level); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return manifest, saveManifest(manifestFile, manifest)
+}
 
-	r = image.Rectangle{image.Point{1024, 0}, image.Point{1536, 512}}
-	img = loadPNG("CobbleStone/cobblestone.png")
-	draw.DrawMask(textureAtlas, r, img, image.ZP, img, mr.Min, draw.Src)
+func LoadManifest(file string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{directory: filepath.Dir(file)}
+	if err = json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("%v: %v", file, err)
+	}
+	return manifest, nil
+}
 
-	r = image.Rectangle{image.Point{1024, 512}, image.Point{1536, 1024}}
-	img = loadPNG("Dirt/dirt.png")
-	draw.DrawMask(textureAtlas, r, img, image.ZP, img, mr.Min, draw.Src)
+func saveManifest(file string, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, data, 0644)
+}
 
-	r = image.Rectangle{image.Point{1024, 1024}, image.Point{1536, 1536}}
-	img = loadPNG("Grass/grass.png")
-	draw.DrawMask(textureAtlas, r, img, image.ZP, img, mr.Min, draw.Src)
+func regionOf(place placement, page image.Point) TextureRegion {
+	return TextureRegion{
+		Atlas: place.page,
+		U0:    float32(place.rect.Min.X) / float32(page.X),
+		V0:    float32(place.rect.Min.Y) / float32(page.Y),
+		U1:    float32(place.rect.Max.X) / float32(page.X),
+		V1:    float32(place.rect.Max.Y) / float32(page.Y),
+	}
+}
 
-	r = image.Rectangle{image.Point{0, 1536}, image.Point{512, 2048}}
-	img = loadPNG("Gravel/gravel.png")
-	draw.DrawMask(textureAtlas, r, img, image.ZP, img, mr.Min, draw.Src)
+// isSourceTexture reports whether a file is a texture to pack rather than an
+// atlas or texture array this package writes next to them.
+func isSourceTexture(path string, info os.FileInfo) bool {
+	return !info.IsDir() && strings.ToLower(filepath.Ext(path)) == ".png" &&
+		!strings.HasPrefix(info.Name(), atlasName) && !strings.HasPrefix(info.Name(), arrayName)
+}
 
-	r = image.Rectangle{image.Point{512, 1536}, image.Point{1024, 2048}}
-	img = loadPNG("Stone/stone.png")
-	draw.DrawMask(textureAtlas, r, img, image.ZP, img, mr.Min, draw.Src)
+// sourceHash hashes the names and contents of the textures below directory,
+// so adding, removing or editing any of them changes it.
+func sourceHash(directory string) (string, error) {
+	hash := sha256.New()
+	// Walk visits files in lexical order, so the hash does not depend on
+	// the order the file system lists them in
+	err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !isSourceTexture(path, info) {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(directory, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "%v %v\n", filepath.ToSlash(relative), len(data))
+		hash.Write(data)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
 
-	pngFile, err := os.Create("resource/texture/textureAtlas.png")
+// discoverTextures loads every PNG below directory, skipping the atlases and
+// texture arrays this package writes there itself. The result is sorted by name.
+func discoverTextures(directory string) ([]texture, error) {
+	textures := []texture{}
+	err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !isSourceTexture(path, info) {
+			return nil
+		}
+		relative, err := filepath.Rel(directory, path)
+		if err != nil {
+			return err
+		}
+		img, err := decodePNG(path)
+		if err != nil {
+			return err
+		}
+		name := strings.ToLower(filepath.ToSlash(strings.TrimSuffix(relative, filepath.Ext(relative))))
+		textures = append(textures, texture{name, img})
+		return nil
+	})
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	png.Encode(pngFile, textureAtlas)
+	sort.Slice(textures, func(i, j int) bool { return textures[i].name < textures[j].name })
+	return textures, nil
 }
 
-func loadPNG(filePath string) image.Image {
+func SavePNG(filePath string, img image.Image) error {
+	pngFile, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	if err = png.Encode(pngFile, img); err != nil {
+		pngFile.Close()
+		return err
+	}
+	return pngFile.Close()
+}
 
-	var pngFile *os.File
-	var err error
-	var pngImg image.Image
-	pngFile, err = os.Open("resource/texture/" + filePath)
+func decodePNG(filePath string) (image.Image, error) {
+	pngFile, err := os.Open(filePath)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	defer pngFile.Close()
-	pngImg, err = png.Decode(pngFile)
+	pngImg, err := png.Decode(pngFile)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("%v: %v", filePath, err)
 	}
-	return pngImg
+	return pngImg, nil
 }
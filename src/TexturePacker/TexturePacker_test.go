@@ -0,0 +1,62 @@
+package TexturePacker
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTexture(t *testing.T, file string, fill color.RGBA) {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = fill.R, fill.G, fill.B, fill.A
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := SavePNG(file, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPackedTexturesGoStale(t *testing.T) {
+	directory := t.TempDir()
+	manifestFile := filepath.Join(directory, "textureAtlas.json")
+	arrayFile := filepath.Join(directory, "textureArray.json")
+	writeTexture(t, filepath.Join(directory, "dirt.png"), color.RGBA{120, 80, 40, 255})
+	writeTexture(t, filepath.Join(directory, "stone", "side.png"), color.RGBA{90, 90, 90, 255})
+
+	if AtlasUpToDate(directory, manifestFile) || ArrayUpToDate(directory, arrayFile) {
+		t.Fatal("up to date before anything was packed")
+	}
+	pack := func() {
+		if _, err := PackDirectory(directory, manifestFile); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := PackDirectoryLayers(directory, arrayFile); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pack()
+	if !AtlasUpToDate(directory, manifestFile) || !ArrayUpToDate(directory, arrayFile) {
+		t.Fatal("not up to date right after packing, the packed images must not count as sources")
+	}
+
+	changes := []struct {
+		name   string
+		change func()
+	}{
+		{"edited texture", func() { writeTexture(t, filepath.Join(directory, "dirt.png"), color.RGBA{130, 80, 40, 255}) }},
+		{"added texture", func() { writeTexture(t, filepath.Join(directory, "stone", "top.png"), color.RGBA{100, 100, 100, 255}) }},
+		{"removed texture", func() { os.Remove(filepath.Join(directory, "stone", "side.png")) }},
+	}
+	for _, test := range changes {
+		test.change()
+		if AtlasUpToDate(directory, manifestFile) || ArrayUpToDate(directory, arrayFile) {
+			t.Errorf("still up to date after an %v", test.name)
+		}
+		pack()
+	}
+}
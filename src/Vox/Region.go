@@ -0,0 +1,126 @@
+package Vox
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/allanks/Voxel-Engine/src/Block"
+)
+
+// Region is a box of block ids in world orientation, with y pointing up.
+type Region struct {
+	SizeX, SizeY, SizeZ int
+	Blocks              []uint8
+}
+
+// missingColor stands in for blocks that do not declare a colour.
+var missingColor = color.RGBA{0x80, 0x80, 0x80, 0xff}
+
+func CreateRegion(sizeX, sizeY, sizeZ int) *Region {
+	return &Region{sizeX, sizeY, sizeZ, make([]uint8, sizeX*sizeY*sizeZ)}
+}
+
+func (region *Region) At(x, y, z int) uint8 {
+	return region.Blocks[region.index(x, y, z)]
+}
+
+func (region *Region) Set(x, y, z int, block uint8) {
+	region.Blocks[region.index(x, y, z)] = block
+}
+
+func (region *Region) index(x, y, z int) int {
+	return x + (z * region.SizeX) + (y * region.SizeX * region.SizeZ)
+}
+
+// ToRegion turns one model into blocks using blocks to map colour indices
+// to block ids. MagicaVoxel is z up, so its (x, y, z) becomes
+// (x, z, SizeY-1-y) which keeps the model from being mirrored.
+func (vox *File) ToRegion(model int, blocks [256]uint8) (*Region, error) {
+	if model < 0 || model >= len(vox.Models) {
+		return nil, fmt.Errorf("vox file has no model %v", model)
+	}
+	source := vox.Models[model]
+	region := CreateRegion(source.SizeX, source.SizeZ, source.SizeY)
+	for _, voxel := range source.Voxels {
+		x, y, z := int(voxel.X), int(voxel.Z), source.SizeY-1-int(voxel.Y)
+		if x >= region.SizeX || y >= region.SizeY || z < 0 {
+			return nil, fmt.Errorf("voxel %v,%v,%v is outside the model", voxel.X, voxel.Y, voxel.Z)
+		}
+		region.Set(x, y, z, blocks[voxel.Color])
+	}
+	return region, nil
+}
+
+// FromRegion is the inverse of ToRegion, writing every block other than
+// Empty with the colour index colors gives it.
+func FromRegion(region *Region, palette [256]color.RGBA, colors map[uint8]uint8) (*File, error) {
+	model := Model{SizeX: region.SizeX, SizeY: region.SizeZ, SizeZ: region.SizeY}
+	for y := 0; y < region.SizeY; y++ {
+		for z := 0; z < region.SizeZ; z++ {
+			for x := 0; x < region.SizeX; x++ {
+				block := region.At(x, y, z)
+				if block == Block.Empty {
+					continue
+				}
+				index, ok := colors[block]
+				if !ok {
+					return nil, fmt.Errorf("block %v has no colour", block)
+				}
+				model.Voxels = append(model.Voxels, Voxel{uint8(x), uint8(region.SizeZ - 1 - z), uint8(y), index})
+			}
+		}
+	}
+	return &File{Models: []Model{model}, Palette: palette}, nil
+}
+
+// BlockPalette gives every block other than Empty a palette entry holding
+// its colour, returning the palette and the colour index of each block.
+func BlockPalette() ([256]color.RGBA, map[uint8]uint8) {
+	var palette [256]color.RGBA
+	colors := map[uint8]uint8{}
+	index := 1
+	for id := 0; id < Block.Count() && index < len(palette); id++ {
+		definition := Block.Get(id)
+		if definition == nil || definition.ID == Block.Empty {
+			continue
+		}
+		c, ok := definition.RGBA()
+		if !ok {
+			c = missingColor
+		}
+		palette[index] = c
+		colors[definition.ID] = uint8(index)
+		index++
+	}
+	return palette, colors
+}
+
+// PaletteBlocks maps every colour of a palette to the solid block with the
+// closest colour. Index 0 maps to Empty.
+func PaletteBlocks(palette [256]color.RGBA) [256]uint8 {
+	var blocks [256]uint8
+	for i := 1; i < len(palette); i++ {
+		best, bestDistance := Block.Empty, -1
+		for id := 0; id < Block.Count(); id++ {
+			definition := Block.Get(id)
+			if definition == nil || !definition.Solid {
+				continue
+			}
+			c, ok := definition.RGBA()
+			if !ok {
+				continue
+			}
+			distance := colorDistance(palette[i], c)
+			if bestDistance < 0 || distance < bestDistance {
+				best, bestDistance = definition.ID, distance
+			}
+		}
+		blocks[i] = best
+	}
+	return blocks
+}
+
+func colorDistance(a, b color.RGBA) int {
+	dr, dg, db := int(a.R)-int(b.R), int(a.G)-int(b.G), int(a.B)-int(b.B)
+	return (dr * dr) + (dg * dg) + (db * db)
+}
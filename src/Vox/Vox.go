@@ -0,0 +1,275 @@
+package Vox
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+)
+
+const (
+	voxVersion int32 = 150
+	maxSize    int   = 256
+)
+
+// File is the content of a MagicaVoxel .vox file. Palette[0] is unused, as
+// a colour index of 0 means no voxel.
+type File struct {
+	Models  []Model
+	Palette [256]color.RGBA
+}
+
+// Model is a grid of voxels in MagicaVoxel space, where z points up.
+type Model struct {
+	SizeX, SizeY, SizeZ int
+	Voxels              []Voxel
+}
+
+type Voxel struct {
+	X, Y, Z, Color uint8
+}
+
+type chunk struct {
+	id       string
+	content  []byte
+	children []byte
+}
+
+func LoadFile(file string) (*File, error) {
+	voxFile, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer voxFile.Close()
+	vox, err := Read(bufio.NewReader(voxFile))
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", file, err)
+	}
+	return vox, nil
+}
+
+func SaveFile(file string, vox *File) error {
+	voxFile, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err = Write(voxFile, vox); err != nil {
+		voxFile.Close()
+		return err
+	}
+	return voxFile.Close()
+}
+
+// Read parses the SIZE, XYZI and RGBA chunks of a .vox file. Scene graph,
+// material and layer chunks are skipped. Files without an RGBA chunk use the
+// MagicaVoxel default palette. Models larger than 256 on a side and voxels
+// outside their model are rejected.
+func Read(reader io.Reader) (*File, error) {
+	var header [4]byte
+	var version int32
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		return nil, err
+	}
+	if string(header[:]) != "VOX " {
+		return nil, fmt.Errorf("not a vox file")
+	}
+	if err := binary.Read(reader, binary.LittleEndian, &version); err != nil {
+		return nil, err
+	}
+
+	main, err := readChunk(reader)
+	if err != nil {
+		return nil, err
+	}
+	if main.id != "MAIN" {
+		return nil, fmt.Errorf("expected MAIN chunk, got %v", main.id)
+	}
+
+	vox := &File{Palette: DefaultPalette()}
+	children := bytes.NewReader(main.children)
+	var size *Model
+	for children.Len() > 0 {
+		child, err := readChunk(children)
+		if err != nil {
+			return nil, err
+		}
+		switch child.id {
+		case "SIZE":
+			if len(child.content) < 12 {
+				return nil, fmt.Errorf("short SIZE chunk")
+			}
+			size = &Model{
+				SizeX: int(int32(binary.LittleEndian.Uint32(child.content))),
+				SizeY: int(int32(binary.LittleEndian.Uint32(child.content[4:]))),
+				SizeZ: int(int32(binary.LittleEndian.Uint32(child.content[8:]))),
+			}
+			if err = CheckSize(size.SizeX, size.SizeY, size.SizeZ); err != nil {
+				return nil, err
+			}
+		case "XYZI":
+			if size == nil {
+				return nil, fmt.Errorf("XYZI chunk without SIZE")
+			}
+			if len(child.content) < 4 {
+				return nil, fmt.Errorf("short XYZI chunk")
+			}
+			count := binary.LittleEndian.Uint32(child.content)
+			if uint64(count) > uint64((len(child.content)-4)/4) {
+				return nil, fmt.Errorf("XYZI chunk holds fewer than %v voxels", count)
+			}
+			size.Voxels = make([]Voxel, count)
+			for i := range size.Voxels {
+				data := child.content[4+(i*4):]
+				voxel := Voxel{data[0], data[1], data[2], data[3]}
+				if int(voxel.X) >= size.SizeX || int(voxel.Y) >= size.SizeY || int(voxel.Z) >= size.SizeZ {
+					return nil, fmt.Errorf("voxel %v,%v,%v is outside the %vx%vx%v model", voxel.X, voxel.Y, voxel.Z, size.SizeX, size.SizeY, size.SizeZ)
+				}
+				size.Voxels[i] = voxel
+			}
+			vox.Models = append(vox.Models, *size)
+			size = nil
+		case "RGBA":
+			if len(child.content) < 256*4 {
+				return nil, fmt.Errorf("short RGBA chunk")
+			}
+			// The chunk stores colour indices 1 to 255 followed by one unused entry
+			for i := 0; i < 255; i++ {
+				data := child.content[i*4:]
+				vox.Palette[i+1] = color.RGBA{data[0], data[1], data[2], data[3]}
+			}
+		}
+	}
+	return vox, nil
+}
+
+func readChunk(reader io.Reader) (chunk, error) {
+	var header struct {
+		ID                        [4]byte
+		ContentSize, ChildrenSize int32
+	}
+	if err := binary.Read(reader, binary.LittleEndian, &header); err != nil {
+		return chunk{}, err
+	}
+	result := chunk{id: string(header.ID[:])}
+	if header.ContentSize < 0 || header.ChildrenSize < 0 {
+		return chunk{}, fmt.Errorf("chunk %v has a negative size", result.id)
+	}
+	var err error
+	if result.content, err = readBytes(reader, int64(header.ContentSize)); err != nil {
+		return chunk{}, fmt.Errorf("chunk %v: %v", result.id, err)
+	}
+	if result.children, err = readBytes(reader, int64(header.ChildrenSize)); err != nil {
+		return chunk{}, fmt.Errorf("chunk %v: %v", result.id, err)
+	}
+	return result, nil
+}
+
+// readBytes reads size bytes. The buffer grows with what is actually read,
+// so a size larger than the file does not allocate it up front.
+func readBytes(reader io.Reader, size int64) ([]byte, error) {
+	if sized, ok := reader.(interface{ Len() int }); ok && int64(sized.Len()) < size {
+		return nil, fmt.Errorf("declares %v bytes, only %v left", size, sized.Len())
+	}
+	data := &bytes.Buffer{}
+	read, err := io.CopyN(data, reader, size)
+	if read < size {
+		return nil, fmt.Errorf("declares %v bytes, only %v left", size, read)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data.Bytes(), nil
+}
+
+// CheckSize rejects models outside the 1 to 256 voxels a side .vox allows.
+func CheckSize(x, y, z int) error {
+	if x < 1 || y < 1 || z < 1 || x > maxSize || y > maxSize || z > maxSize {
+		return fmt.Errorf("model size %vx%vx%v is outside 1 to %v", x, y, z, maxSize)
+	}
+	return nil
+}
+
+// Write stores every model and the palette in the 150 version format.
+func Write(writer io.Writer, vox *File) error {
+	children := &bytes.Buffer{}
+	if len(vox.Models) > 1 {
+		writeChunk(children, "PACK", []int32{int32(len(vox.Models))})
+	}
+	for _, model := range vox.Models {
+		if err := CheckSize(model.SizeX, model.SizeY, model.SizeZ); err != nil {
+			return err
+		}
+		writeChunk(children, "SIZE", []int32{int32(model.SizeX), int32(model.SizeY), int32(model.SizeZ)})
+		xyzi := &bytes.Buffer{}
+		binary.Write(xyzi, binary.LittleEndian, int32(len(model.Voxels)))
+		for _, voxel := range model.Voxels {
+			xyzi.Write([]byte{voxel.X, voxel.Y, voxel.Z, voxel.Color})
+		}
+		writeChunk(children, "XYZI", xyzi.Bytes())
+	}
+	palette := make([]byte, 256*4)
+	for i := 0; i < 255; i++ {
+		c := vox.Palette[i+1]
+		copy(palette[i*4:], []byte{c.R, c.G, c.B, c.A})
+	}
+	writeChunk(children, "RGBA", palette)
+
+	file := &bytes.Buffer{}
+	file.WriteString("VOX ")
+	binary.Write(file, binary.LittleEndian, voxVersion)
+	file.WriteString("MAIN")
+	binary.Write(file, binary.LittleEndian, []int32{0, int32(children.Len())})
+	file.Write(children.Bytes())
+	_, err := writer.Write(file.Bytes())
+	return err
+}
+
+func writeChunk(buffer *bytes.Buffer, id string, content interface{}) {
+	data := &bytes.Buffer{}
+	binary.Write(data, binary.LittleEndian, content)
+	buffer.WriteString(id)
+	binary.Write(buffer, binary.LittleEndian, []int32{int32(data.Len()), 0})
+	buffer.Write(data.Bytes())
+}
+
+// DefaultPalette is the palette MagicaVoxel uses for files without one:
+// indices 1 to 215 step through a 6x6x6 colour cube from white, the rest are
+// red, green, blue and grey ramps.
+func DefaultPalette() [256]color.RGBA {
+	var palette [256]color.RGBA
+	steps := []uint8{0xff, 0xcc, 0x99, 0x66, 0x33, 0x00}
+	i := 1
+	for _, r := range steps {
+		for _, g := range steps {
+			for _, b := range steps {
+				if i < 216 {
+					palette[i] = color.RGBA{r, g, b, 0xff}
+				}
+				i++
+			}
+		}
+	}
+	ramp := []uint8{0xee, 0xdd, 0xbb, 0xaa, 0x88, 0x77, 0x55, 0x44, 0x22, 0x11}
+	i = 216
+	for channel := 0; channel < 4; channel++ {
+		for _, value := range ramp {
+			c := color.RGBA{A: 0xff}
+			switch channel {
+			case 0:
+				c.R = value
+			case 1:
+				c.G = value
+			case 2:
+				c.B = value
+			default:
+				c.R, c.G, c.B = value, value, value
+			}
+			palette[i] = c
+			i++
+		}
+	}
+	return palette
+}
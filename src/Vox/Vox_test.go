@@ -0,0 +1,130 @@
+package Vox
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// voxFile builds a .vox file from raw chunks, so tests can write what Write
+// never would.
+func voxFile(mainChildrenSize int32, chunks ...[]byte) []byte {
+	children := bytes.Join(chunks, nil)
+	if mainChildrenSize < 0 {
+		mainChildrenSize = int32(len(children))
+	}
+	file := &bytes.Buffer{}
+	file.WriteString("VOX ")
+	binary.Write(file, binary.LittleEndian, []int32{150})
+	file.WriteString("MAIN")
+	binary.Write(file, binary.LittleEndian, []int32{0, mainChildrenSize})
+	file.Write(children)
+	return file.Bytes()
+}
+
+// rawChunk writes a chunk declaring contentSize bytes of content, or the
+// size of content when contentSize is negative.
+func rawChunk(id string, contentSize int32, content ...interface{}) []byte {
+	data := &bytes.Buffer{}
+	for _, value := range content {
+		binary.Write(data, binary.LittleEndian, value)
+	}
+	if contentSize < 0 {
+		contentSize = int32(data.Len())
+	}
+	result := &bytes.Buffer{}
+	result.WriteString(id)
+	binary.Write(result, binary.LittleEndian, []int32{contentSize, 0})
+	result.Write(data.Bytes())
+	return result.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	vox := &File{Palette: DefaultPalette()}
+	vox.Palette[1] = color.RGBA{1, 2, 3, 255}
+	vox.Models = []Model{{SizeX: 3, SizeY: 2, SizeZ: 256, Voxels: []Voxel{{0, 0, 0, 1}, {2, 1, 255, 7}}}}
+
+	written := &bytes.Buffer{}
+	if err := Write(written, vox); err != nil {
+		t.Fatal(err)
+	}
+	read, err := Read(written)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Models) != 1 || read.Palette != vox.Palette {
+		t.Fatalf("read %v models, palette equal %v", len(read.Models), read.Palette == vox.Palette)
+	}
+	model := read.Models[0]
+	if model.SizeX != 3 || model.SizeY != 2 || model.SizeZ != 256 || len(model.Voxels) != 2 || model.Voxels[1] != vox.Models[0].Voxels[1] {
+		t.Errorf("read model %+v", model)
+	}
+}
+
+func TestWriteRejectsLargeModels(t *testing.T) {
+	vox := &File{Models: []Model{{SizeX: 257, SizeY: 1, SizeZ: 1}}}
+	if err := Write(&bytes.Buffer{}, vox); err == nil {
+		t.Error("wrote a model 257 voxels wide")
+	}
+}
+
+func TestReadRejectsMalformedFiles(t *testing.T) {
+	size := func(x, y, z int32) []byte { return rawChunk("SIZE", -1, []int32{x, y, z}) }
+	tests := []struct {
+		name, err string
+		file      []byte
+	}{
+		{"zero size", "outside 1 to 256", voxFile(-1, size(0, 4, 4))},
+		{"negative size", "outside 1 to 256", voxFile(-1, size(4, -4, 4))},
+		{"oversized", "outside 1 to 256", voxFile(-1, size(4, 4, 100000))},
+		{"voxel count past the chunk", "fewer than", voxFile(-1, size(4, 4, 4), rawChunk("XYZI", -1, uint32(0xffffffff), []byte{0, 0, 0, 1}))},
+		{"voxel outside the model", "outside the 4x4x4 model", voxFile(-1, size(4, 4, 4), rawChunk("XYZI", -1, uint32(1), []byte{1, 4, 1, 1}))},
+		{"chunk larger than the file", "only", voxFile(-1, size(4, 4, 4), rawChunk("XYZI", 0x7fffffff))},
+		{"main larger than the file", "only", voxFile(0x7fffffff, size(4, 4, 4))},
+		{"negative chunk size", "negative size", voxFile(-1, []byte("SIZE"), []byte{0, 0, 0, 0x80, 0, 0, 0, 0})},
+		{"voxels without a size", "without SIZE", voxFile(-1, rawChunk("XYZI", -1, uint32(0)))},
+		{"not a vox file", "not a vox file", []byte("PNG 1234")},
+	}
+	for _, test := range tests {
+		_, err := Read(bytes.NewReader(test.file))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%v: Read returned %v, want an error about %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestReadLimitsAllocationFromStreams(t *testing.T) {
+	// A reader without Len, like a file, must not get the declared size
+	// allocated before the data turns out to be missing
+	file := voxFile(0x7fffffff, rawChunk("SIZE", -1, []int32{1, 1, 1}))
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if _, err := Read(struct{ io.Reader }{bytes.NewReader(file)}); err == nil {
+		t.Error("read a truncated file")
+	}
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("allocated %v bytes reading a %v byte file", allocated, len(file))
+	}
+}
+
+func TestToRegion(t *testing.T) {
+	vox := &File{Models: []Model{{SizeX: 2, SizeY: 3, SizeZ: 4, Voxels: []Voxel{{1, 0, 3, 5}}}}}
+	var blocks [256]uint8
+	blocks[5] = 9
+	region, err := vox.ToRegion(0, blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if region.SizeX != 2 || region.SizeY != 4 || region.SizeZ != 3 {
+		t.Fatalf("region is %vx%vx%v, want 2x4x3", region.SizeX, region.SizeY, region.SizeZ)
+	}
+	// MagicaVoxel y 0 is the far side, z is up
+	if region.At(1, 3, 2) != 9 {
+		t.Error("voxel not placed at 1 3 2")
+	}
+}
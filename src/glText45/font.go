@@ -9,10 +9,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"image"
-	"image/png"
 	"io"
 	"io/ioutil"
-	"os"
 
 	"github.com/allanks/Voxel-Engine/src/Graphics"
 	"github.com/go-gl/glh"
@@ -34,32 +32,17 @@ type Font struct {
 //
 // The image should hold a sprite sheet, defining the graphical layout for
 // every glyph. The config describes font metadata.
-func loadFont(img *image.RGBA, config *FontConfig, vertexBuffer, textureDataStorageBlock uint32) (f *Font) {
+func loadFont(img *image.RGBA, config *FontConfig, control Graphics.TextureCreator, vertexBuffer, textureDataStorageBlock uint32) (f *Font) {
 	f = new(Font)
 	f.config = config
 
-	w, err := os.Create("resource/fonts/texture.png")
-	if err != nil {
-		panic(err)
-	}
-	png.Encode(w, img)
-
 	// Resize image to next power-of-two.
 	img = glh.Pow2Image(img).(*image.RGBA)
 	ib := img.Bounds()
 
-	f.texture, err = Graphics.NewTexture("resource/texture/textureAtlas.png")
-	//f.texture, err = Graphics.NewTexture("resource/fonts/texture.png")
-	if err != nil {
-		panic(err)
-	}
-
 	// Create the texture itself. It will contain all glyphs.
 	// Individual glyph-quads display a subset of this texture.
-	gl.GenTextures(1, &f.texture)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
-	gl.BindTexture(gl.TEXTURE_2D, f.texture)
+	f.texture = control.CreateImageTexture(img)
 
 	vertexData := []float32{}
 	uvData := []float32{}
@@ -87,7 +70,8 @@ func loadFont(img *image.RGBA, config *FontConfig, vertexBuffer, textureDataStor
 		tx2 := (float32(glyph.X) + vw) / texWidth
 		ty2 := (float32(glyph.Y) + vh) / texHeight
 
-		uvData = append(uvData, tx1, ty2, tx2, ty2, tx2, ty1, tx1, ty1)
+		// Screen y grows downwards, as image y does
+		uvData = append(uvData, tx1, ty1, tx2, ty1, tx2, ty2, tx1, ty2)
 	}
 	vertexData = []float32{0, 0, float32(f.maxGlyphWidth), 0, float32(f.maxGlyphWidth), float32(f.maxGlyphHeight), 0, float32(f.maxGlyphHeight)}
 
@@ -134,7 +118,7 @@ func (f *Font) DisplayString(x, y float32, objectBuffer uint32, fs string, argv
 	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
 	gl.BindTexture(gl.TEXTURE_2D, f.texture)
 
-	gl.DrawArraysInstanced(gl.TRIANGLE_STRIP, 0, 4, int32(len(indices)))
+	gl.DrawArraysInstanced(gl.TRIANGLE_FAN, 0, 4, int32(len(indices)))
 
 	//fmt.Printf("Instances %v\n", instances)
 
@@ -0,0 +1,86 @@
+package glText45
+
+import (
+	"io"
+
+	"github.com/allanks/Voxel-Engine/src/Graphics"
+	"github.com/go-gl/glow/gl-core/4.5/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// textureDataBinding is the storage block binding of the glyph texture
+// coordinates in titleShader.shad, clear of the bindings the game uses.
+const textureDataBinding uint32 = 2
+
+// Overlay draws text over a frame in screen pixels, the origin at the top
+// left. It has its own program and buffers so it can be drawn between the
+// draws of the game, only the depth test and blending are changed.
+type Overlay struct {
+	font                                 *Font
+	program, vao                         uint32
+	vertexBuffer, objectBuffer, uvBuffer uint32
+	color                                int32
+}
+
+// Line is text drawn at X, Y in Color.
+type Line struct {
+	X, Y  float32
+	Color mgl32.Vec4
+	Text  string
+}
+
+// CreateOverlay loads a truetype font for an overlay over a frame of width
+// by height pixels.
+func CreateOverlay(control Graphics.OpenGLControl, r io.Reader, scale int32, width, height int) (*Overlay, error) {
+	overlay := &Overlay{program: control.NewProgram("titleShader.shad", "titleFrag.frag")}
+	gl.GenVertexArrays(1, &overlay.vao)
+	gl.GenBuffers(1, &overlay.vertexBuffer)
+	gl.GenBuffers(1, &overlay.objectBuffer)
+	gl.GenBuffers(1, &overlay.uvBuffer)
+
+	font, err := LoadTruetype(r, scale, 32, 127, LeftToRight, control, overlay.vertexBuffer, overlay.uvBuffer)
+	if err != nil {
+		return nil, err
+	}
+	overlay.font = font
+
+	gl.BindVertexArray(overlay.vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, overlay.vertexBuffer)
+	gl.EnableVertexAttribArray(0)
+	gl.VertexAttribPointer(0, 2, gl.FLOAT, false, 0, gl.PtrOffset(0))
+	gl.BindBuffer(gl.ARRAY_BUFFER, overlay.objectBuffer)
+	gl.EnableVertexAttribArray(1)
+	gl.VertexAttribPointer(1, 4, gl.FLOAT, false, 0, gl.PtrOffset(0))
+	gl.VertexAttribDivisor(1, 1)
+
+	projection := mgl32.Ortho2D(0, float32(width), float32(height), 0)
+	gl.ProgramUniformMatrix4fv(overlay.program, gl.GetUniformLocation(overlay.program, gl.Str("projection\x00")), 1, false, &projection[0])
+	gl.ProgramUniform1i(overlay.program, gl.GetUniformLocation(overlay.program, gl.Str("tex\x00")), 0)
+	overlay.color = gl.GetUniformLocation(overlay.program, gl.Str("vColor\x00"))
+	return overlay, nil
+}
+
+// LineHeight is how far apart lines of text are drawn.
+func (overlay *Overlay) LineHeight() float32 {
+	_, height := overlay.font.GlyphBounds()
+	return float32(height)
+}
+
+// Draw draws the lines over what has been drawn so far.
+func (overlay *Overlay) Draw(lines []Line) {
+	gl.UseProgram(overlay.program)
+	gl.BindVertexArray(overlay.vao)
+	gl.BindBufferBase(gl.SHADER_STORAGE_BUFFER, textureDataBinding, overlay.uvBuffer)
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.Disable(gl.DEPTH_TEST)
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+
+	for _, line := range lines {
+		gl.ProgramUniform4f(overlay.program, overlay.color, line.Color[0], line.Color[1], line.Color[2], line.Color[3])
+		overlay.font.DisplayString(line.X, line.Y, overlay.objectBuffer, "%v", line.Text)
+	}
+
+	gl.Disable(gl.BLEND)
+	gl.Enable(gl.DEPTH_TEST)
+}
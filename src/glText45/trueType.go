@@ -13,6 +13,7 @@ import (
 
 	"code.google.com/p/freetype-go/freetype"
 	"code.google.com/p/freetype-go/freetype/truetype"
+	"github.com/allanks/Voxel-Engine/src/Graphics"
 	"github.com/go-gl/glh"
 )
 
@@ -30,7 +31,10 @@ func printBounds(b truetype.Bounds) {
 //
 // The dir value determines the orientation of the text we render
 // with this font. This should be any of the predefined Direction constants.
-func LoadTruetype(r io.Reader, scale int32, low, high rune, dir Direction, vertexDataStorageBlock, textureDataStorageBlock uint32) (*Font, error) {
+//
+// Glyphs are drawn white on a transparent background so the colour they
+// are drawn with tints them.
+func LoadTruetype(r io.Reader, scale int32, low, high rune, dir Direction, control Graphics.TextureCreator, vertexDataStorageBlock, textureDataStorageBlock uint32) (*Font, error) {
 	data, err := ioutil.ReadAll(r)
 	if err != nil {
 		panic(err)
@@ -68,7 +72,7 @@ func LoadTruetype(r io.Reader, scale int32, low, high rune, dir Direction, verte
 	ih := glh.Pow2(uint32(gh * glyphsPerCol))
 
 	// Initialize the context.
-	fg, bg := image.Black, image.White
+	fg, bg := image.White, image.Transparent
 	rgba := image.NewRGBA(image.Rect(0, 0, int(iw), int(ih)))
 	draw.Draw(rgba, rgba.Bounds(), bg, image.ZP, draw.Src)
 	c := freetype.NewContext()
@@ -110,5 +114,5 @@ func LoadTruetype(r io.Reader, scale int32, low, high rune, dir Direction, verte
 		gi++
 	}
 
-	return loadFont(rgba, &fc, vertexDataStorageBlock, textureDataStorageBlock), nil
+	return loadFont(rgba, &fc, control, vertexDataStorageBlock, textureDataStorageBlock), nil
 }
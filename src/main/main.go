@@ -1,16 +1,22 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"runtime"
 	"time"
 	"unsafe"
 
+	"github.com/allanks/Voxel-Engine/src/Block"
+	"github.com/allanks/Voxel-Engine/src/Camera"
+	"github.com/allanks/Voxel-Engine/src/Chat"
+	"github.com/allanks/Voxel-Engine/src/Entity"
 	"github.com/allanks/Voxel-Engine/src/Graphics"
 	gamegl "github.com/allanks/Voxel-Engine/src/Graphics/Game/OpenGL45"
 	controlgl "github.com/allanks/Voxel-Engine/src/Graphics/OpenGL45"
 	"github.com/allanks/Voxel-Engine/src/Model"
 	"github.com/allanks/Voxel-Engine/src/Player"
+	"github.com/allanks/Voxel-Engine/src/TexturePacker"
 	"github.com/go-gl/glfw/v3.1/glfw"
 	"github.com/go-gl/mathgl/mgl32"
 )
@@ -24,6 +30,12 @@ var (
 const WindowWidth = 800
 const WindowHeight = 600
 
+const (
+	fieldOfView float32 = 70.0
+	nearPlane   float32 = 0.1
+	farPlane    float32 = 100.0
+)
+
 func init() {
 	// GLFW event handling must run on the main OS thread
 	runtime.LockOSThread()
@@ -70,7 +82,8 @@ func initializeWindow() {
 	// Initialize Glow
 	openGLControl.Init()
 
-	window.SetKeyCallback(Player.OnKey)
+	window.SetKeyCallback(onKey)
+	window.SetCharCallback(Chat.OnChar)
 	window.SetCursorPosCallback(Player.OnCursor)
 	initOpenGLProgram(window)
 }
@@ -120,7 +133,7 @@ func titleScreenLoop(window *glfw.Window, drawTitle, drawGame *bool) {
 		panic(err)
 	}
 	fmt.Println("Generating Fonts")
-	font, err := glText45.LoadTruetype(ttf, 12, 32, 127, glText45.LeftToRight, vertexBuffer, textureDataStorageBlock)
+	font, err := glText45.LoadTruetype(ttf, 12, 32, 127, glText45.LeftToRight, openGLControl, vertexBuffer, textureDataStorageBlock)
 	if err != nil {
 		panic(err)
 	}
@@ -150,18 +163,18 @@ func mainGameLoop(window *glfw.Window, drawGame, drawTitle *bool) {
 	gameController.StartPrograms()
 	gameController.CreateUniforms()
 	gameController.CreateBuffers()
+	Chat.InitOverlay(openGLControl, WindowWidth, WindowHeight)
 
-	camera := Player.GetCameraMatrix()
+	camera := Camera.CreateCamera(fieldOfView, float32(WindowWidth)/float32(WindowHeight), nearPlane, farPlane)
+	Block.InitBlocks()
 	Model.InitGCubes()
 	Model.InitModels()
 
-	Player.GenPlayer(5, 68, 5)
-	xPos, _ := window.GetCursorPos()
-	window.SetCursorPos(xPos, -180)
-
-	//gopher := []float32{0, 63, 5, 0}
+	Entity.Listen()
+	Chat.Listen()
+	Player.GenPlayer(5, 68, 5, camera)
 
-	gameController.BindProjection(float32(WindowWidth), float32(WindowHeight))
+	gameController.BindProjection(camera.ProjectionMatrix())
 
 	fmt.Println("Starting Draw Loop")
 
@@ -174,36 +187,66 @@ func mainGameLoop(window *glfw.Window, drawGame, drawTitle *bool) {
 	}()
 
 	for !window.ShouldClose() && *drawGame {
-		openGLControl.Clear()
+		renderScene(camera)
 
-		camera = Player.GetCameraMatrix()
+		if screenshotScale > 0 {
+			takeScreenshot(camera, screenshotScale)
+			screenshotScale = 0
+		}
 
-		gameController.UpdateProjection(camera)
+		window.SwapBuffers()
+		glfw.PollEvents()
+	}
+}
 
-		x, y, z := Player.GetPosition()
-		position := []float32{float32(x), float32(y), float32(z), Model.SkyBox}
+func renderScene(camera *Camera.Camera) {
+	openGLControl.Clear()
 
-		openGLControl.DepthToggle(false)
+	gameController.UpdateProjection(camera.ViewMatrix())
 
-		Model.BindBuffers([]float32{-0.5, -0.5, -0.5}, Model.Cube)
-		Model.Render(position, Model.Cube)
+	position := []float32{camera.Position.X(), camera.Position.Y(), camera.Position.Z(), float32(Block.SkyBox)}
 
-		openGLControl.DepthToggle(true)
+	openGLControl.DepthToggle(false)
 
-		Model.BindBuffers([]float32{0.0, 0.0, 0.0}, Model.Cube)
-		Player.Render()
+	Model.BindBuffers([]float32{-0.5, -0.5, -0.5}, Model.Cube)
+	Model.Render(position, Model.Cube)
 
-		//gl.UseProgram(mobProgram)
-		//gl.BindVertexArray(vao)
-		//Model.BindBuffers(vertexBuffer, normalBuffer, textureDataStorageBlock, uvBuffer, scale, length, Model.Gopher)
-		//Model.Render(vao, gopher, Model.Gopher)
+	openGLControl.DepthToggle(true)
 
-		window.SwapBuffers()
-		glfw.PollEvents()
+	Model.BindBuffers([]float32{0.0, 0.0, 0.0}, Model.Cube)
+	Player.Render()
+
+	Entity.Render()
+
+	Chat.Render()
+}
+
+func onKey(window *glfw.Window, k glfw.Key, s int, action glfw.Action, mods glfw.ModifierKey) {
+	if k == glfw.KeyF2 && action == glfw.Press {
+		screenshotScale = 1
+		if mods&glfw.ModShift != 0 {
+			screenshotScale = supersampleScale
+		}
+		return
 	}
+	if Chat.OnKey(window, k, s, action, mods) {
+		return
+	}
+	Player.OnKey(window, k, s, action, mods)
 }
 
 func main() {
-	//Terrain.PackTextures()
+	flag.BoolVar(&Model.UseTextureArray, "texturearray", false, "draw blocks from a texture array instead of the atlas")
+	flag.StringVar(&Player.Name, "name", Player.Name, "the name other players see")
+	flag.Parse()
+
+	if !TexturePacker.AtlasUpToDate(TexturePacker.TextureDirectory, TexturePacker.ManifestFile) {
+		fmt.Println("Packing textures")
+		TexturePacker.PackTextures()
+	}
+	if Model.UseTextureArray && !TexturePacker.ArrayUpToDate(TexturePacker.TextureDirectory, TexturePacker.ArrayManifestFile) {
+		fmt.Println("Packing texture array")
+		TexturePacker.PackTextureArray()
+	}
 	initializeWindow()
 }
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/allanks/Voxel-Engine/src/Camera"
+	"github.com/allanks/Voxel-Engine/src/TexturePacker"
+)
+
+const (
+	screenshotDirectory       = "screenshots"
+	supersampleScale    int32 = 2
+)
+
+// screenshotScale is set by the key callback and consumed by the draw loop
+// once the frame has been rendered. Zero means no screenshot is pending.
+var screenshotScale int32
+
+// takeScreenshot reads back what the player sees. At a scale of one the
+// frame just drawn is read from the back buffer, larger scales render the
+// scene again into an offscreen framebuffer of the multiplied size.
+func takeScreenshot(camera *Camera.Camera, scale int32) {
+	width, height := int32(WindowWidth), int32(WindowHeight)
+
+	var img *image.RGBA
+	var err error
+	if scale > 1 {
+		width, height = width*scale, height*scale
+		openGLControl.BeginOffscreen(width, height)
+		renderScene(camera)
+		img, err = openGLControl.ReadFramebuffer(width, height)
+		openGLControl.EndOffscreen()
+	} else {
+		img, err = openGLControl.ReadFramebuffer(width, height)
+	}
+	if err != nil {
+		fmt.Printf("Screenshot failed %v\n", err)
+		return
+	}
+
+	go saveScreenshot(img)
+}
+
+func saveScreenshot(img *image.RGBA) {
+	if err := os.MkdirAll(screenshotDirectory, 0755); err != nil {
+		fmt.Printf("Screenshot failed %v\n", err)
+		return
+	}
+	// The readback keeps the framebuffer alpha, which is meaningless here
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+	file := filepath.Join(screenshotDirectory, time.Now().Format("2006-01-02_15.04.05.000")+".png")
+	if err := TexturePacker.SavePNG(file, img); err != nil {
+		fmt.Printf("Screenshot failed %v\n", err)
+		return
+	}
+	fmt.Printf("Saved screenshot %v\n", file)
+}